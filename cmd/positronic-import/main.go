@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/config"
+	"github.com/seriousben/positronic-blogger/internal/dryrun"
+	"github.com/seriousben/positronic-blogger/internal/importer"
+)
+
+func main() {
+	var (
+		configFile  = flag.String("config", os.Getenv(config.EnvConfigFile), "path to a TOML or YAML config file")
+		format      = flag.String("format", "", "format of the export, netscape, opml or csv, detected when empty")
+		folder      = flag.String("folder", "", "only import the bookmarks of this folder and its subfolders, such as Blog/Go")
+		since       = flag.String("since", "", "only import the bookmarks added on or after this date, such as 2020-01-01")
+		until       = flag.String("until", "", "only import the bookmarks added before this date")
+		tags        = flag.String("tag", "", "comma separated tags, only import the bookmarks having one of them")
+		contentPath = flag.String("content-path", "", "path of the imported posts, defaults to the configured content path")
+		batchSize   = flag.Int("batch-size", importer.DefaultBatchSize, "number of posts per commit")
+		dryRun      = flag.Bool("dry-run", false, "print the posts that would be imported instead of opening a pull request")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] bookmarks.html\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	file := flag.Arg(0)
+
+	filter := importer.Filter{Folder: *folder}
+	var err error
+	if filter.Since, err = parseDate(*since); err != nil {
+		log.Fatalf("malformed -since: %v", err)
+	}
+	if filter.Until, err = parseDate(*until); err != nil {
+		log.Fatalf("malformed -until: %v", err)
+	}
+	for _, t := range strings.Split(*tags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter.Tags = append(filter.Tags, t)
+		}
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := importer.DetectFormat(file, data)
+	if *format != "" {
+		f, err = importer.ParseFormat(*format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	entries, err := importer.Parse(f, data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var selected []importer.Entry
+	for _, e := range entries {
+		if filter.Match(e) {
+			selected = append(selected, e)
+		}
+	}
+	log.Printf("selected %d of the %d bookmarks of %s", len(selected), len(entries), file)
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("error loading config: %v", err)
+	}
+	if err := cfg.ValidateImport(); err != nil {
+		log.Fatal(err)
+	}
+	if *contentPath == "" {
+		*contentPath = cfg.ImportContentPath()
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	repo, err := cfg.OpenRepository(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *dryRun {
+		repo = dryrun.New(repo, dryrun.WithOutput(os.Stdout))
+	}
+	tmpl, err := cfg.LoadTemplate(ctx, repo)
+	if err != nil {
+		log.Fatalf("error loading template: %v", err)
+	}
+
+	im := &importer.Importer{
+		Repository:    repo,
+		ContentPath:   *contentPath,
+		LookupPaths:   cfg.ContentPaths(),
+		Template:      tmpl,
+		Canonicalizer: cfg.Canonicalizer(),
+		Identity:      cfg.CommitIdentity(),
+		BatchSize:     *batchSize,
+	}
+	plan, err := im.Plan(ctx, selected)
+	if err != nil {
+		log.Fatalf("error preparing import: %v", err)
+	}
+	log.Printf("importing %d posts, skipping %d already posted and %d without a date", len(plan.Files), plan.Duplicates, plan.Undated)
+
+	branch := fmt.Sprintf("%s-positronic-import", time.Now().Format("2006-01-02T150405"))
+	title := fmt.Sprintf("Import %d bookmarks from %s", len(plan.Files), filepath.Base(file))
+	if err := im.Import(ctx, branch, title, plan); err != nil {
+		log.Fatalf("error importing: %v", err)
+	}
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/archive"
+	"github.com/seriousben/positronic-blogger/internal/config"
+	"github.com/seriousben/positronic-blogger/internal/linkcheck"
+)
+
+func main() {
+	var (
+		configFile  = flag.String("config", os.Getenv(config.EnvConfigFile), "path to a TOML or YAML config file")
+		format      = flag.String("format", "text", "report format, text or json")
+		paths       = flag.String("path", "", "comma separated paths of the posts to check, defaults to the configured content paths")
+		fix         = flag.Bool("fix", false, "open a pull request rewriting permanent redirects and adding archive links to dead links")
+		concurrency = flag.Int("concurrency", linkcheck.DefaultConcurrency, "number of links checked at once")
+		hostDelay   = flag.Duration("host-delay", linkcheck.DefaultHostDelay, "minimum delay between requests to the same host")
+		retries     = flag.Int("retries", linkcheck.DefaultRetries, "retries of links that could not be checked")
+		timeout     = flag.Duration("timeout", linkcheck.DefaultTimeout, "timeout of each request")
+	)
+	flag.Parse()
+
+	if *format != "text" && *format != "json" {
+		log.Fatalf("unknown format %q, expected text or json", *format)
+	}
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("error loading config: %v", err)
+	}
+	if err := cfg.ValidateLinkCheck(); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	repo, err := cfg.OpenRepository(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dirs := cfg.ContentPaths()
+	if *paths != "" {
+		dirs = strings.Split(*paths, ",")
+	}
+	var posts []linkcheck.Post
+	for _, dir := range dirs {
+		p, err := linkcheck.Posts(ctx, repo, strings.TrimSpace(dir))
+		if err != nil {
+			log.Fatalf("error listing posts of %s: %v", dir, err)
+		}
+		posts = append(posts, p...)
+	}
+	log.Printf("checking the links of %d posts", len(posts))
+
+	checker := linkcheck.New(
+		linkcheck.WithConcurrency(*concurrency),
+		linkcheck.WithHostDelay(*hostDelay),
+		linkcheck.WithRetries(*retries),
+		linkcheck.WithTimeout(*timeout),
+	)
+	results := checker.CheckAll(ctx, posts)
+
+	report := linkcheck.NewReport(results)
+	if *format == "json" {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalf("error writing report: %v", err)
+	}
+
+	if !*fix {
+		return
+	}
+	fixer := &linkcheck.Fixer{
+		Repository: repo,
+		Archiver:   archive.New(archive.WithWayback(cfg.Archive.WaybackURL)),
+		Identity:   cfg.CommitIdentity(),
+	}
+	fixed, err := fixer.Fix(ctx, fmt.Sprintf("%s-positronic-linkcheck", time.Now().Format("2006-01-02T150405")), posts, results)
+	if err != nil {
+		log.Fatalf("error fixing links: %v", err)
+	}
+	log.Printf("fixed %d posts", len(fixed))
+}
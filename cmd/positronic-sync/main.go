@@ -2,81 +2,123 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
 
-	"github.com/seriousben/positronic-blogger/internal/github"
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"github.com/seriousben/positronic-blogger/internal/config"
+	"github.com/seriousben/positronic-blogger/internal/dryrun"
+	"github.com/seriousben/positronic-blogger/internal/linkpreview"
 	"github.com/seriousben/positronic-blogger/internal/newsblur"
 	"github.com/seriousben/positronic-blogger/internal/newsblurposter"
-)
-
-const (
-	envSkipMerge              = "POSITRONIC_SKIP_MERGE"
-	envNewsblurUsername       = "POSITRONIC_NEWSBLUR_USERNAME"
-	envNewsblurPassword       = "POSITRONIC_NEWSBLUR_PASSWORD"
-	envNewsblurContentPath    = "POSITRONIC_NEWSBLUR_CONTENT_PATH"
-	envNewsblurCheckpointPath = "POSITRONIC_NEWSBLUR_CHECKPOINT_PATH"
-	envGithubRepo             = "POSITRONIC_GITHUB_REPO"
-	envGithubToken            = "POSITRONIC_GITHUB_TOKEN"
+	"github.com/seriousben/positronic-blogger/internal/poster"
+	"github.com/seriousben/positronic-blogger/internal/scheduler"
+	"github.com/seriousben/positronic-blogger/internal/template"
 )
 
 func main() {
 	var (
-		ctx              = context.Background()
-		skipMerge        = os.Getenv(envSkipMerge) == "true"
-		nbUsername       = os.Getenv(envNewsblurUsername)
-		nbPassword       = os.Getenv(envNewsblurPassword)
-		nbContentPath    = os.Getenv(envNewsblurContentPath)
-		nbCheckpointPath = os.Getenv(envNewsblurCheckpointPath)
-		ghToken          = os.Getenv(envGithubToken)
-		ghRepoFull       = os.Getenv(envGithubRepo)
-		ghOwner          string
-		ghRepo           string
+		ctx        = context.Background()
+		configFile = flag.String("config", os.Getenv(config.EnvConfigFile), "path to a TOML or YAML config file")
 	)
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("error loading config: %v", err)
+	}
+
+	if err := cfg.ValidateSync(); err != nil {
+		log.Fatal(err)
+	}
 
-	if nbUsername == "" || nbPassword == "" {
-		log.Fatalf("missing %s or %s environment variables", envNewsblurUsername, envNewsblurPassword)
+	repo, err := cfg.OpenRepository(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Sync.DryRun {
+		repo = dryrun.New(repo, dryrun.WithOutput(os.Stdout), dryrun.WithDir(cfg.Sync.PreviewDir))
 	}
 
-	nbClient, err := newsblur.New(ctx, nbUsername, nbPassword)
+	tmpl, err := cfg.LoadTemplate(ctx, repo)
 	if err != nil {
-		log.Fatalf("error creating newsblur client: %v", err)
+		log.Fatalf("error loading template: %v", err)
 	}
 
-	if ghToken == "" || ghRepoFull == "" {
-		log.Fatalf("missing %s or %s", envGithubRepo, envGithubToken)
+	// The previewer and sources are shared by all runs for their caches to outlive them.
+	previewer := cfg.Previewer()
+	sources, err := cfg.SyncSources()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	if ghRepoFullSplit := strings.Split(ghRepoFull, "/"); len(ghRepoFullSplit) == 2 {
-		ghOwner = ghRepoFullSplit[0]
-		ghRepo = ghRepoFullSplit[1]
-	} else {
-		log.Fatalf("malformed %s (%s) - expected format to be owner/repo", envGithubRepo, ghRepoFull)
+	schedule := cfg.SyncSchedule()
+	if schedule == nil {
+		if err := run(ctx, cfg, repo, tmpl, previewer, sources); err != nil {
+			log.Fatalf("error running blogger: %v", err)
+		}
+		return
 	}
 
-	if nbContentPath == "" || nbCheckpointPath == "" {
-		log.Fatalf("missing %s or %s", envNewsblurContentPath, envNewsblurCheckpointPath)
+	// The scheduler waits for the current run after a first signal,
+	// signals are then no longer caught so that a second one kills a hung run.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	s := &scheduler.Scheduler{
+		Schedule:   schedule,
+		Jitter:     cfg.SyncJitter(),
+		RunOnStart: true,
+		Job: func(ctx context.Context) error {
+			return run(ctx, cfg, repo, tmpl, previewer, sources)
+		},
+	}
+	if err := s.Run(ctx); err != nil {
+		log.Fatal(err)
 	}
+}
 
-	ghClient, err := github.New(ctx, ghToken, ghOwner, ghRepo)
-	if err != nil {
-		log.Fatalf("error creating github client: %v", err)
+// run syncs the new NewsBlur shared stories and items of the other sources once.
+// NewsBlur is logged into on every run so that a daemon survives expired sessions.
+func run(ctx context.Context, cfg *config.Config, repo backend.Repository, tmpl *template.Template, previewer *linkpreview.Fetcher, others []poster.SourceConfig) error {
+	var sources []poster.SourceConfig
+	if cfg.NewsBlurEnabled() {
+		nbClient, err := newsblur.New(ctx, cfg.NewsBlur.Username, cfg.NewsBlur.Password, cfg.NewsBlurOptions()...)
+		if err != nil {
+			return fmt.Errorf("creating newsblur client: %w", err)
+		}
+		sources = append(sources, poster.SourceConfig{
+			Name:           "newsblur",
+			Source:         &newsblurposter.Source{Client: nbClient},
+			ContentPath:    cfg.NewsBlur.ContentPath,
+			CheckpointPath: cfg.NewsBlur.CheckpointPath,
+		})
 	}
+	sources = append(sources, others...)
 
-	poster, err := newsblurposter.New(newsblurposter.Config{
-		GithubClient:           ghClient,
-		NewsblurClient:         nbClient,
-		NewsblurContentPath:    nbContentPath,
-		NewsblurCheckpointPath: nbCheckpointPath,
-		SkipMerge:              skipMerge,
+	p, err := poster.New(poster.Config{
+		Repository:    repo,
+		Sources:       sources,
+		SkipMerge:     cfg.Sync.SkipMerge,
+		Batch:         cfg.Sync.Batch,
+		Template:      tmpl,
+		Identity:      cfg.CommitIdentity(),
+		Duplicates:    cfg.SyncDuplicates(),
+		LookupPaths:   cfg.ContentPaths(),
+		Canonicalizer: cfg.Canonicalizer(),
+		Previewer:     previewer,
+		Archiver:      cfg.Archiver(),
 	})
 	if err != nil {
-		log.Fatalf("error creating blogger: %v", err)
+		return fmt.Errorf("creating blogger: %w", err)
 	}
 
-	err = poster.Run(ctx)
-	if err != nil {
-		log.Fatalf("error running blogger: %v", err)
-	}
+	sum, err := p.RunWithSummary(ctx)
+	log.Printf("sync summary: %d posts, %d duplicates skipped, published: %t", sum.Posts, sum.Duplicates, sum.Published)
+	return err
 }
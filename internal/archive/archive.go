@@ -0,0 +1,340 @@
+// Package archive snapshots the pages posts link to, in the Wayback Machine
+// or as a readable copy stored alongside the posts, so that curated links do not rot.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"github.com/seriousben/positronic-blogger/internal/htmlmd"
+	"github.com/seriousben/positronic-blogger/internal/template"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	// DefaultWaybackURL is the URL of the Wayback Machine.
+	DefaultWaybackURL = "https://web.archive.org"
+	// DefaultTimeout bounds the time spent capturing or copying a page,
+	// the Wayback Machine can take a while to capture one.
+	DefaultTimeout = time.Minute
+	// maxPageSize bounds how much of a page is read to copy it.
+	maxPageSize = 5 << 20
+)
+
+// Archiver archives the pages posts link to.
+type Archiver struct {
+	waybackURL string
+	copyPath   string
+	client     *http.Client
+	timeout    time.Duration
+	now        func() time.Time
+}
+
+type Option func(*Archiver)
+
+// WithWayback requests a snapshot of each page from the Wayback Machine at endpoint,
+// DefaultWaybackURL when empty, and records it as the ArchiveURL of posts.
+func WithWayback(endpoint string) Option {
+	return func(a *Archiver) {
+		if endpoint == "" {
+			endpoint = DefaultWaybackURL
+		}
+		a.waybackURL = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// WithCopy stores a readable Markdown copy of each page under dir in the repository,
+// named after its post, and records it as the ArchivePath of posts.
+func WithCopy(dir string) Option {
+	return func(a *Archiver) {
+		a.copyPath = dir
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to request snapshots and fetch pages.
+func WithHTTPClient(client *http.Client) Option {
+	return func(a *Archiver) {
+		a.client = client
+	}
+}
+
+// WithTimeout replaces the DefaultTimeout of archiving a page.
+func WithTimeout(d time.Duration) Option {
+	return func(a *Archiver) {
+		a.timeout = d
+	}
+}
+
+func New(opts ...Option) *Archiver {
+	a := &Archiver{
+		client:  http.DefaultClient,
+		timeout: DefaultTimeout,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Archive archives the page p links to and records where in p.
+// The returned file is the copy of the page to commit along with the post, nil when there is none.
+//
+// Pages that cannot be archived are logged and skipped, a missing archive is never a reason
+// not to publish a post. Copies already in repo are kept as they are, they capture the page
+// as it was when first posted.
+func (a *Archiver) Archive(ctx context.Context, repo backend.Repository, p *template.Post, fileName string, format template.Format) (*backend.File, error) {
+	if a.waybackURL != "" {
+		snapshot, err := a.Snapshot(ctx, p.URL)
+		if err != nil {
+			log.Printf("archive: snapshotting %s: %v", p.URL, err)
+		} else {
+			p.ArchiveURL = snapshot
+		}
+	}
+
+	if a.copyPath == "" {
+		return nil, nil
+	}
+	copyPath := path.Join(a.copyPath, fileName)
+	_, _, err := repo.GetContent(ctx, copyPath)
+	if err == nil {
+		p.ArchivePath = copyPath
+		return nil, nil
+	}
+	if !errors.Is(err, backend.ErrFileNotFound) {
+		return nil, fmt.Errorf("looking up archive copy: %w", err)
+	}
+
+	content, err := a.Copy(ctx, *p, format)
+	if err != nil {
+		log.Printf("archive: copying %s: %v", p.URL, err)
+		return nil, nil
+	}
+	p.ArchivePath = copyPath
+	return &backend.File{Path: copyPath, Content: content}, nil
+}
+
+// Snapshot requests a snapshot of the page at rawURL and returns its URL.
+// When the capture fails, the most recent existing snapshot is returned instead.
+func (a *Archiver) Snapshot(ctx context.Context, rawURL string) (string, error) {
+	saveCtx, cancel := a.withTimeout(ctx)
+	snapshot, saveErr := a.save(saveCtx, rawURL)
+	cancel()
+	if saveErr == nil {
+		return snapshot, nil
+	}
+	// Captures most often fail by timing out, the lookup has a timeout of its own.
+	snapshot, err := a.Lookup(ctx, rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w, and looking up existing snapshots: %v", saveErr, err)
+	}
+	if snapshot == "" {
+		return "", saveErr
+	}
+	return snapshot, nil
+}
+
+// Lookup returns the most recent existing snapshot of the page at rawURL, empty when there is none.
+func (a *Archiver) Lookup(ctx context.Context, rawURL string) (string, error) {
+	ctx, cancel := a.withTimeout(ctx)
+	defer cancel()
+	return a.available(ctx, rawURL)
+}
+
+// save captures the page with the Save Page Now endpoint, which redirects to the snapshot
+// or points to it with its Content-Location header.
+func (a *Archiver) save(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.waybackURL+"/save/"+rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxPageSize))
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("saving: unexpected status %s", resp.Status)
+	}
+	if loc := resp.Header.Get("Content-Location"); strings.HasPrefix(loc, "/web/") {
+		return a.waybackURL + loc, nil
+	}
+	if strings.HasPrefix(resp.Request.URL.Path, "/web/") {
+		return resp.Request.URL.String(), nil
+	}
+	return "", errors.New("saving: no snapshot in response")
+}
+
+// available returns the closest existing snapshot of the page, if any.
+func (a *Archiver) available(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.waybackURL+"/wayback/available?url="+url.QueryEscape(rawURL), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var availability struct {
+		ArchivedSnapshots struct {
+			Closest struct {
+				Available bool   `json:"available"`
+				URL       string `json:"url"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&availability); err != nil {
+		return "", fmt.Errorf("decoding availability: %w", err)
+	}
+	if closest := availability.ArchivedSnapshots.Closest; closest.Available {
+		return closest.URL, nil
+	}
+	return "", nil
+}
+
+// Copy fetches the page p links to and returns a readable Markdown copy of its main content,
+// with front matter in format recording where and when it was copied from.
+func (a *Archiver) Copy(ctx context.Context, p template.Post, format template.Format) (string, error) {
+	ctx, cancel := a.withTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "html") {
+		return "", fmt.Errorf("cannot copy %s content", ct)
+	}
+
+	doc, err := html.Parse(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return "", fmt.Errorf("parsing page: %w", err)
+	}
+	body, err := readable(doc, resp.Request.URL)
+	if err != nil {
+		return "", err
+	}
+	md := htmlmd.Convert(body)
+	if md == "" {
+		return "", errors.New("page has no readable content")
+	}
+
+	fm, err := format.Encode([]template.Field{
+		{Key: "title", Value: p.Title},
+		// Not originalUrl, copies must not be mistaken for posts of the link.
+		{Key: "archivedFrom", Value: p.URL},
+		{Key: "archivedAt", Value: a.now().UTC()},
+	})
+	if err != nil {
+		return "", err
+	}
+	return fm + "\n\n" + md + "\n", nil
+}
+
+func (a *Archiver) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if a.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, a.timeout)
+}
+
+// clutter are the elements around the content of pages.
+var clutter = map[atom.Atom]bool{
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Form: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Iframe: true, atom.Button: true, atom.Svg: true,
+}
+
+// readable returns the HTML of the main content of a page, its first <article>, <main> or <body>,
+// without clutter and with its links and images made absolute.
+func readable(doc *html.Node, base *url.URL) (string, error) {
+	var content *html.Node
+	for _, a := range []atom.Atom{atom.Article, atom.Main, atom.Body} {
+		if content = find(doc, a); content != nil {
+			break
+		}
+	}
+	if content == nil {
+		return "", errors.New("page has no body")
+	}
+
+	var clean func(n *html.Node)
+	clean = func(n *html.Node) {
+		for ch := n.FirstChild; ch != nil; {
+			next := ch.NextSibling
+			if ch.Type == html.ElementNode && clutter[ch.DataAtom] {
+				n.RemoveChild(ch)
+			} else {
+				absolute(ch, base)
+				clean(ch)
+			}
+			ch = next
+		}
+	}
+	clean(content)
+
+	buf := new(bytes.Buffer)
+	for ch := content.FirstChild; ch != nil; ch = ch.NextSibling {
+		if err := html.Render(buf, ch); err != nil {
+			return "", fmt.Errorf("rendering content: %w", err)
+		}
+	}
+	return buf.String(), nil
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if found := find(ch, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// absolute resolves the href and src attributes of n against base.
+func absolute(n *html.Node, base *url.URL) {
+	if n.Type != html.ElementNode {
+		return
+	}
+	for i, attr := range n.Attr {
+		if attr.Key != "href" && attr.Key != "src" {
+			continue
+		}
+		if u, err := base.Parse(strings.TrimSpace(attr.Val)); err == nil {
+			n.Attr[i].Val = u.String()
+		}
+	}
+}
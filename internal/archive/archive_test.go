@@ -0,0 +1,178 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"github.com/seriousben/positronic-blogger/internal/template"
+	"gotest.tools/v3/assert"
+)
+
+type fakeRepository struct {
+	backend.Repository
+	files map[string]string
+}
+
+func (r *fakeRepository) GetContent(ctx context.Context, path string) (string, string, error) {
+	content, ok := r.files[path]
+	if !ok {
+		return "", "", backend.ErrFileNotFound
+	}
+	return content, "sha", nil
+}
+
+// newWayback starts a stand-in of the Wayback Machine.
+// Pages of the hosts "down.example" and "slow.example" cannot be captured, failing or never answering,
+// only the past snapshots of their /old page are available.
+func newWayback(t *testing.T) *httptest.Server {
+	t.Helper()
+	// Target URLs are in the path, which a ServeMux would clean.
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch uri := r.URL.RequestURI(); {
+		case strings.HasPrefix(uri, "/save/"):
+			target := strings.TrimPrefix(uri, "/save/")
+			if strings.Contains(target, "down.example") {
+				http.Error(w, "capture failed", http.StatusServiceUnavailable)
+				return
+			}
+			if strings.Contains(target, "slow.example") {
+				<-r.Context().Done()
+				return
+			}
+			// http.Redirect would clean the path too.
+			w.Header().Set("Location", "/web/20220305090000/"+target)
+			w.WriteHeader(http.StatusFound)
+		case strings.HasPrefix(uri, "/web/"):
+			fmt.Fprint(w, "snapshot")
+		case r.URL.Path == "/wayback/available":
+			target := r.URL.Query().Get("url")
+			if !strings.HasSuffix(target, ".example/old") {
+				fmt.Fprint(w, `{"url": "`+target+`", "archived_snapshots": {}}`)
+				return
+			}
+			fmt.Fprint(w, `{"archived_snapshots": {"closest": {"status": "200", "available": true,
+			"url": "http://web.archive.org/web/20200101000000/`+target+`", "timestamp": "20200101000000"}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+const articlePage = `<!DOCTYPE html>
+<html><head><title>Article</title><script>track()</script></head>
+<body>
+<nav><a href="/">Home</a></nav>
+<article>
+<h1>Go Generics in Practice</h1>
+<p>Type parameters &amp; <a href="/constraints">constraints</a>.</p>
+<img src="img/diagram.png" alt="diagram">
+<aside>Subscribe!</aside>
+</article>
+<footer>Copyright</footer>
+</body></html>`
+
+func newPages(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/posts/generics", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, articlePage)
+	})
+	mux.HandleFunc("/paper.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		fmt.Fprint(w, "%PDF-1.4")
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func Test_Snapshot(t *testing.T) {
+	wb := newWayback(t)
+	a := New(WithWayback(wb.URL + "/"))
+	ctx := context.Background()
+
+	snapshot, err := a.Snapshot(ctx, "https://example.com/article?id=1")
+	assert.NilError(t, err)
+	assert.Equal(t, snapshot, wb.URL+"/web/20220305090000/https://example.com/article?id=1")
+
+	// The capture failed, the existing snapshot is used.
+	snapshot, err = a.Snapshot(ctx, "https://down.example/old")
+	assert.NilError(t, err)
+	assert.Equal(t, snapshot, "http://web.archive.org/web/20200101000000/https://down.example/old")
+
+	_, err = a.Snapshot(ctx, "https://down.example/new")
+	assert.ErrorContains(t, err, "503")
+
+	// The capture timed out, the existing snapshot is still looked up.
+	a = New(WithWayback(wb.URL), WithTimeout(50*time.Millisecond))
+	snapshot, err = a.Snapshot(ctx, "https://slow.example/old")
+	assert.NilError(t, err)
+	assert.Equal(t, snapshot, "http://web.archive.org/web/20200101000000/https://slow.example/old")
+}
+
+func Test_Copy(t *testing.T) {
+	pages := newPages(t)
+	a := New(WithCopy("content/mirror"))
+	a.now = func() time.Time { return time.Date(2022, 3, 5, 9, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	content, err := a.Copy(ctx, template.Post{Title: "Generics", URL: pages.URL + "/posts/generics"}, template.FormatYAML)
+	assert.NilError(t, err)
+	assert.Equal(t, content, `---
+title: "Generics"
+archivedFrom: "`+pages.URL+`/posts/generics"
+archivedAt: "2022-03-05T09:00:00Z"
+---
+
+# Go Generics in Practice
+
+Type parameters & [constraints](`+pages.URL+`/constraints).
+
+![diagram](`+pages.URL+`/posts/img/diagram.png)
+`)
+
+	_, err = a.Copy(ctx, template.Post{URL: pages.URL + "/paper.pdf"}, template.FormatYAML)
+	assert.ErrorContains(t, err, "cannot copy application/pdf content")
+}
+
+func Test_Archive(t *testing.T) {
+	wb := newWayback(t)
+	pages := newPages(t)
+	a := New(WithWayback(wb.URL), WithCopy("content/mirror"))
+	ctx := context.Background()
+	repo := &fakeRepository{files: map[string]string{
+		"content/mirror/2022-03-04-kept.md": "copied before",
+	}}
+
+	p := template.Post{Title: "Generics", URL: pages.URL + "/posts/generics"}
+	f, err := a.Archive(ctx, repo, &p, "2022-03-05-generics.md", template.FormatTOML)
+	assert.NilError(t, err)
+	assert.Equal(t, p.ArchiveURL, wb.URL+"/web/20220305090000/"+p.URL)
+	assert.Equal(t, p.ArchivePath, "content/mirror/2022-03-05-generics.md")
+	assert.Equal(t, f.Path, p.ArchivePath)
+	assert.Assert(t, strings.Contains(f.Content, "# Go Generics in Practice"), f.Content)
+
+	// Existing copies are kept.
+	p = template.Post{Title: "Kept", URL: pages.URL + "/posts/generics"}
+	f, err = a.Archive(ctx, repo, &p, "2022-03-04-kept.md", template.FormatTOML)
+	assert.NilError(t, err)
+	assert.Assert(t, f == nil)
+	assert.Equal(t, p.ArchivePath, "content/mirror/2022-03-04-kept.md")
+
+	// Failures leave the post without archive.
+	p = template.Post{Title: "Gone", URL: "https://down.example/new"}
+	f, err = a.Archive(ctx, repo, &p, "2022-03-06-gone.md", template.FormatTOML)
+	assert.NilError(t, err)
+	assert.Assert(t, f == nil)
+	assert.Equal(t, p.ArchiveURL, "")
+	assert.Equal(t, p.ArchivePath, "")
+}
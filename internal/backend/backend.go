@@ -0,0 +1,42 @@
+package backend
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrFileNotFound = errors.New("file not found")
+
+// Repository is a place where posts are published, such as a GitHub repository
+// or a local git repository.
+type Repository interface {
+	// GetContent returns the content and blob SHA of the file at path on the base branch.
+	// It returns an error wrapping ErrFileNotFound when the file does not exist.
+	GetContent(ctx context.Context, path string) (content string, sha string, err error)
+	// ListFiles returns all files under dir, recursively, on the base branch.
+	// A missing dir has no files.
+	ListFiles(ctx context.Context, dir string) ([]File, error)
+	// OpenBranch starts, or reuses, a branch based on the base branch.
+	// Commits made on the branch are attributed to id.
+	OpenBranch(ctx context.Context, branchName string, id Identity) (Branch, error)
+}
+
+// File is the full content of a file at a path of the repository.
+type File struct {
+	Path    string
+	Content string
+	// SHA is the blob SHA of files read from the repository.
+	SHA string
+}
+
+// Branch is a work branch where changes are committed before being published.
+type Branch interface {
+	CreateFile(ctx context.Context, commitMsg, path, content string) error
+	UpdateFile(ctx context.Context, commitMsg, path, sha, content string) error
+	// CommitFiles creates or replaces all files atomically in a single commit.
+	CommitFiles(ctx context.Context, commitMsg string, files []File) error
+	// Publish proposes the branch changes for the base branch and,
+	// when merge is true, merges them.
+	Publish(ctx context.Context, title, body string, merge bool) error
+	DeleteBranch(ctx context.Context) error
+}
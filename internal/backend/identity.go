@@ -0,0 +1,72 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Signature identifies the author or committer of a commit.
+type Signature struct {
+	Name  string
+	Email string
+}
+
+func (s Signature) IsZero() bool {
+	return s.Name == "" && s.Email == ""
+}
+
+func (s Signature) String() string {
+	return fmt.Sprintf("%s <%s>", s.Name, s.Email)
+}
+
+// Trailer is a git trailer appended to commit messages, such as Co-authored-by.
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// ParseTrailer parses a "Key: value" trailer.
+func ParseTrailer(s string) (Trailer, error) {
+	key, value, ok := strings.Cut(s, ":")
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if !ok || key == "" || value == "" || strings.ContainsAny(key, " \t") {
+		return Trailer{}, fmt.Errorf("malformed trailer %q - expected format to be Key: value", s)
+	}
+	return Trailer{Key: key, Value: value}, nil
+}
+
+// CoAuthor returns a Co-authored-by trailer for s.
+func CoAuthor(s Signature) Trailer {
+	return Trailer{Key: "Co-authored-by", Value: s.String()}
+}
+
+// Identity is who commits of a branch are attributed to.
+// Zero signatures fallback on the repository defaults:
+// the committer defaults to the author and the author to the authenticated user or git config.
+type Identity struct {
+	Author    Signature
+	Committer Signature
+	Trailers  []Trailer
+}
+
+// EffectiveCommitter returns the committer, or the author when no committer is set.
+func (id Identity) EffectiveCommitter() Signature {
+	if id.Committer.IsZero() {
+		return id.Author
+	}
+	return id.Committer
+}
+
+// Message appends the identity trailers to commitMsg.
+func (id Identity) Message(commitMsg string) string {
+	if len(id.Trailers) == 0 {
+		return commitMsg
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(commitMsg, "\n"))
+	b.WriteString("\n\n")
+	for _, t := range id.Trailers {
+		fmt.Fprintf(&b, "%s: %s\n", t.Key, t.Value)
+	}
+	return b.String()
+}
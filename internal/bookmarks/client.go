@@ -0,0 +1,338 @@
+// Package bookmarks syncs the bookmarks of a Pinboard account, or of a Linkding instance,
+// that have a publishing tag.
+package bookmarks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/ratelimit"
+	"github.com/seriousben/positronic-blogger/internal/source"
+)
+
+// API is the bookmarking API spoken by a server.
+type API string
+
+const (
+	// APIPinboard is the Pinboard v1 API, also implemented by Pinboard-compatible servers.
+	APIPinboard API = "pinboard"
+	// APILinkding is the REST API of Linkding.
+	APILinkding API = "linkding"
+)
+
+// ParseAPI returns the API named s.
+func ParseAPI(s string) (API, error) {
+	switch a := API(strings.ToLower(s)); a {
+	case APIPinboard, APILinkding:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unknown bookmarks API %q, expected one of pinboard or linkding", s)
+	}
+}
+
+const (
+	// DefaultPinboardURL is the URL of the Pinboard API.
+	DefaultPinboardURL = "https://api.pinboard.in"
+	// pinboardRateLimit is the minimum delay between Pinboard API calls it asks clients for.
+	pinboardRateLimit  = 3 * time.Second
+	pinboardTimeFormat = "2006-01-02T15:04:05Z"
+	// linkdingPageSize is the number of bookmarks listed per Linkding API call.
+	linkdingPageSize = 100
+)
+
+// Bookmark is a saved link.
+type Bookmark struct {
+	ID          string
+	URL         string
+	Title       string
+	Description string
+	Tags        []string
+	Time        time.Time
+}
+
+// Client reads the bookmarks having a tag.
+type Client struct {
+	api       API
+	baseURL   string
+	token     string
+	tag       string
+	client    *http.Client
+	rateLimit time.Duration
+	limiter   *ratelimit.Limiter
+}
+
+type Option func(*Client)
+
+// WithBaseURL replaces the URL of the server, DefaultPinboardURL for Pinboard.
+func WithBaseURL(u string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(u, "/")
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to call the API.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+// WithRateLimit replaces the minimum delay between API calls.
+func WithRateLimit(d time.Duration) Option {
+	return func(c *Client) {
+		c.rateLimit = d
+	}
+}
+
+// New returns a client of the bookmarks tagged with tag, authenticated with token.
+// Pinboard tokens are of the form user:hex, as shown in its settings.
+func New(api API, token, tag string, opts ...Option) (*Client, error) {
+	c := &Client{
+		api:    api,
+		token:  token,
+		tag:    tag,
+		client: http.DefaultClient,
+	}
+	if api == APIPinboard {
+		c.baseURL = DefaultPinboardURL
+		c.rateLimit = pinboardRateLimit
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if _, err := ParseAPI(string(api)); err != nil {
+		return nil, err
+	}
+	if c.baseURL == "" {
+		return nil, fmt.Errorf("missing %s URL", api)
+	}
+	if token == "" {
+		return nil, errors.New("missing API token")
+	}
+	if tag == "" {
+		return nil, errors.New("missing publishing tag")
+	}
+	c.limiter = ratelimit.New(c.rateLimit)
+	return c, nil
+}
+
+// Page returns the bookmarks the server matches with the tag, newest first, starting at offset,
+// and whether there are more. Pinboard returns all the bookmarks newer than newerThan at once,
+// it only allows listing them every five minutes.
+func (c *Client) Page(ctx context.Context, newerThan time.Time, offset int) ([]Bookmark, bool, error) {
+	if c.api == APILinkding {
+		return c.linkdingPage(ctx, offset)
+	}
+	bookmarks, err := c.pinboardPage(ctx, newerThan)
+	return bookmarks, false, err
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) get(ctx context.Context, u string, header http.Header, v any) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("creating bookmarks request: %w", err)
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("getting bookmarks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("getting bookmarks: unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading bookmarks response body: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("unmarshaling bookmarks response body: %w", err)
+	}
+	return nil
+}
+
+type pinboardPost struct {
+	Href        string `json:"href"`
+	Description string `json:"description"`
+	Extended    string `json:"extended"`
+	Hash        string `json:"hash"`
+	Time        string `json:"time"`
+	Tags        string `json:"tags"`
+}
+
+// pinboardPage lists bookmarks with posts/all, the tag and checkpoint being filtered server side.
+func (c *Client) pinboardPage(ctx context.Context, newerThan time.Time) ([]Bookmark, error) {
+	q := url.Values{}
+	q.Set("auth_token", c.token)
+	q.Set("format", "json")
+	q.Set("tag", c.tag)
+	if !newerThan.IsZero() {
+		q.Set("fromdt", newerThan.UTC().Format(pinboardTimeFormat))
+	}
+
+	var posts []pinboardPost
+	if err := c.get(ctx, c.baseURL+"/v1/posts/all?"+q.Encode(), nil, &posts); err != nil {
+		return nil, err
+	}
+
+	bookmarks := make([]Bookmark, 0, len(posts))
+	for _, p := range posts {
+		t, err := time.Parse(time.RFC3339, p.Time)
+		if err != nil {
+			return nil, fmt.Errorf("parsing time of bookmark %s: %w", p.Href, err)
+		}
+		id := p.Hash
+		if id == "" {
+			id = p.Href
+		}
+		bookmarks = append(bookmarks, Bookmark{
+			ID:          id,
+			URL:         p.Href,
+			Title:       p.Description,
+			Description: p.Extended,
+			Tags:        strings.Fields(p.Tags),
+			Time:        t,
+		})
+	}
+	return bookmarks, nil
+}
+
+type linkdingPage struct {
+	Next    *string `json:"next"`
+	Results []struct {
+		ID           int      `json:"id"`
+		URL          string   `json:"url"`
+		Title        string   `json:"title"`
+		Description  string   `json:"description"`
+		Notes        string   `json:"notes"`
+		WebsiteTitle string   `json:"website_title"`
+		TagNames     []string `json:"tag_names"`
+		DateAdded    string   `json:"date_added"`
+	} `json:"results"`
+}
+
+// linkdingPage lists bookmarks with the search API, newest first, the tag being filtered server side.
+func (c *Client) linkdingPage(ctx context.Context, offset int) ([]Bookmark, bool, error) {
+	q := url.Values{}
+	q.Set("q", "#"+c.tag)
+	q.Set("limit", strconv.Itoa(linkdingPageSize))
+	q.Set("offset", strconv.Itoa(offset))
+
+	var page linkdingPage
+	header := http.Header{"Authorization": {"Token " + c.token}}
+	if err := c.get(ctx, c.baseURL+"/api/bookmarks/?"+q.Encode(), header, &page); err != nil {
+		return nil, false, err
+	}
+
+	bookmarks := make([]Bookmark, 0, len(page.Results))
+	for _, r := range page.Results {
+		t, err := time.Parse(time.RFC3339Nano, r.DateAdded)
+		if err != nil {
+			return nil, false, fmt.Errorf("parsing time of bookmark %s: %w", r.URL, err)
+		}
+		b := Bookmark{
+			ID:          strconv.Itoa(r.ID),
+			URL:         r.URL,
+			Title:       r.Title,
+			Description: r.Description,
+			Tags:        r.TagNames,
+			Time:        t,
+		}
+		// Linkding only fills the title when it was edited, keeping the one of the page on the side.
+		// Notes stand in for a missing description.
+		if b.Title == "" {
+			b.Title = r.WebsiteTitle
+		}
+		if b.Description == "" {
+			b.Description = r.Notes
+		}
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, page.Next != nil, nil
+}
+
+// Iterator returns the tagged bookmarks saved strictly after newerThan, newest first.
+// The publishing tag is not a tag of the items.
+func (c *Client) Iterator(ctx context.Context, newerThan time.Time) (source.Iterator, error) {
+	return &iterator{client: c, newerThan: newerThan}, nil
+}
+
+type iterator struct {
+	client     *Client
+	newerThan  time.Time
+	offset     int
+	page       []Bookmark
+	reachedEnd bool
+}
+
+func (it *iterator) Next(ctx context.Context) (*source.Item, error) {
+	for {
+		if len(it.page) == 0 {
+			if it.reachedEnd {
+				return nil, io.EOF
+			}
+			page, more, err := it.client.Page(ctx, it.newerThan, it.offset)
+			if err != nil {
+				return nil, fmt.Errorf("error getting bookmarks: %w", err)
+			}
+			it.offset += len(page)
+			it.page = page
+			it.reachedEnd = !more || len(page) == 0
+			continue
+		}
+
+		b := it.page[0]
+		it.page = it.page[1:]
+		if !b.Time.After(it.newerThan) {
+			it.reachedEnd = true
+			it.page = nil
+			return nil, io.EOF
+		}
+		// Servers may match tags loosely, such as by prefix or in titles.
+		if !hasTag(b.Tags, it.client.tag) {
+			continue
+		}
+		return it.client.toItem(b), nil
+	}
+}
+
+func (c *Client) toItem(b Bookmark) *source.Item {
+	var tags []string
+	for _, t := range b.Tags {
+		if !strings.EqualFold(t, c.tag) {
+			tags = append(tags, t)
+		}
+	}
+	return &source.Item{
+		ID:      b.ID,
+		Title:   strings.TrimSpace(b.Title),
+		URL:     b.URL,
+		Comment: strings.TrimSpace(b.Description),
+		Date:    b.Time,
+		Tags:    tags,
+	}
+}
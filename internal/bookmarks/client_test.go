@@ -0,0 +1,154 @@
+package bookmarks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/source"
+	"gotest.tools/v3/assert"
+)
+
+func collect(t *testing.T, c *Client, newerThan time.Time) []*source.Item {
+	t.Helper()
+	it, err := c.Iterator(context.Background(), newerThan)
+	assert.NilError(t, err)
+	var items []*source.Item
+	for {
+		item, err := it.Next(context.Background())
+		if err == io.EOF {
+			return items
+		}
+		assert.NilError(t, err)
+		items = append(items, item)
+	}
+}
+
+func Test_Pinboard(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Path, "/v1/posts/all")
+		q := r.URL.Query()
+		if q.Get("auth_token") != "user:secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		queries = append(queries, r.URL.RawQuery)
+		fmt.Fprint(w, `[
+			{"href": "https://example.com/new", "description": "New article", "extended": "Worth a read.",
+			 "hash": "abc", "time": "2024-03-05T10:00:00Z", "shared": "yes", "tags": "go blog"},
+			{"href": "https://example.com/blogging", "description": "Loosely matched", "extended": "",
+			 "hash": "def", "time": "2024-03-04T10:00:00Z", "shared": "yes", "tags": "blogging"},
+			{"href": "https://example.com/old", "description": "Old article", "extended": "",
+			 "hash": "ghi", "time": "2024-03-01T10:00:00Z", "shared": "yes", "tags": "blog"}
+		]`)
+	}))
+	defer srv.Close()
+
+	c, err := New(APIPinboard, "user:secret", "blog", WithBaseURL(srv.URL), WithHTTPClient(srv.Client()), WithRateLimit(0))
+	assert.NilError(t, err)
+
+	items := collect(t, c, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+	assert.DeepEqual(t, items, []*source.Item{{
+		ID:      "abc",
+		Title:   "New article",
+		URL:     "https://example.com/new",
+		Comment: "Worth a read.",
+		Date:    time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC),
+		Tags:    []string{"go"},
+	}})
+	assert.DeepEqual(t, queries, []string{"auth_token=user%3Asecret&format=json&fromdt=2024-03-02T00%3A00%3A00Z&tag=blog"})
+
+	c, err = New(APIPinboard, "user:wrong", "blog", WithBaseURL(srv.URL), WithHTTPClient(srv.Client()), WithRateLimit(0))
+	assert.NilError(t, err)
+	it, err := c.Iterator(context.Background(), time.Time{})
+	assert.NilError(t, err)
+	_, err = it.Next(context.Background())
+	assert.ErrorContains(t, err, "unexpected status 401 Unauthorized")
+}
+
+// linkdingBookmark is a bookmark as served by the Linkding API.
+type linkdingBookmark struct {
+	ID           int      `json:"id"`
+	URL          string   `json:"url"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	Notes        string   `json:"notes"`
+	WebsiteTitle string   `json:"website_title"`
+	TagNames     []string `json:"tag_names"`
+	DateAdded    string   `json:"date_added"`
+}
+
+func Test_Linkding(t *testing.T) {
+	var bookmarks []linkdingBookmark
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 150; i > 0; i-- {
+		bookmarks = append(bookmarks, linkdingBookmark{
+			ID:          i,
+			URL:         fmt.Sprintf("https://example.com/%d", i),
+			Title:       fmt.Sprintf("Bookmark %d", i),
+			Description: "thoughts",
+			TagNames:    []string{"Blog", "reading"},
+			DateAdded:   start.Add(time.Duration(i) * time.Hour).Format("2006-01-02T15:04:05.000000Z"),
+		})
+	}
+	bookmarks[0].Title = ""
+	bookmarks[0].WebsiteTitle = "Title of the page"
+	bookmarks[0].Description = ""
+	bookmarks[0].Notes = "my notes"
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Path, "/api/bookmarks/")
+		assert.Equal(t, r.Header.Get("Authorization"), "Token secret")
+		assert.Equal(t, r.URL.Query().Get("q"), "#blog")
+		calls++
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		end := min(offset+limit, len(bookmarks))
+		page := map[string]any{"count": len(bookmarks), "results": bookmarks[offset:end], "next": nil}
+		if end < len(bookmarks) {
+			page["next"] = fmt.Sprintf("%s/api/bookmarks/?offset=%d", r.Host, end)
+		}
+		assert.NilError(t, json.NewEncoder(w).Encode(page))
+	}))
+	defer srv.Close()
+
+	c, err := New(APILinkding, "secret", "blog", WithBaseURL(srv.URL+"/"), WithHTTPClient(srv.Client()))
+	assert.NilError(t, err)
+
+	items := collect(t, c, time.Time{})
+	assert.Equal(t, len(items), 150)
+	assert.Equal(t, calls, 2)
+	assert.DeepEqual(t, items[0], &source.Item{
+		ID:      "150",
+		Title:   "Title of the page",
+		URL:     "https://example.com/150",
+		Comment: "my notes",
+		Date:    start.Add(150 * time.Hour),
+		Tags:    []string{"reading"},
+	})
+
+	// Paging stops at the checkpoint.
+	calls = 0
+	items = collect(t, c, start.Add(140*time.Hour))
+	assert.Equal(t, len(items), 10)
+	assert.Equal(t, calls, 1)
+}
+
+func Test_New_Errors(t *testing.T) {
+	_, err := New(APILinkding, "secret", "blog")
+	assert.ErrorContains(t, err, "missing linkding URL")
+	_, err = New(APIPinboard, "", "blog")
+	assert.ErrorContains(t, err, "missing API token")
+	_, err = New(APIPinboard, "user:secret", "")
+	assert.ErrorContains(t, err, "missing publishing tag")
+	_, err = New("delicious", "secret", "blog", WithBaseURL("https://example.com"))
+	assert.ErrorContains(t, err, `unknown bookmarks API "delicious"`)
+}
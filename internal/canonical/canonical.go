@@ -0,0 +1,410 @@
+// Package canonical cleans up the links of posts before they are published.
+package canonical
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Rules match the query parameters to strip from links.
+// A rule ending with * matches every parameter starting with the rule's prefix.
+type Rules []string
+
+// DefaultRules strip the tracking parameters of common analytics and ad platforms.
+var DefaultRules = Rules{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"msclkid",
+	"yclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_hsenc",
+	"_hsmi",
+	"mkt_tok",
+	"ref",
+	"ref_src",
+	"ref_url",
+}
+
+// Match reports whether param is matched by one of the rules.
+func (r Rules) Match(param string) bool {
+	param = strings.ToLower(param)
+	for _, rule := range r {
+		rule = strings.ToLower(rule)
+		if prefix, ok := strings.CutSuffix(rule, "*"); ok {
+			if strings.HasPrefix(param, prefix) {
+				return true
+			}
+			continue
+		}
+		if param == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// Strip removes the matching parameters from the query of u,
+// leaving the order and encoding of the others untouched.
+func (r Rules) Strip(u *url.URL) {
+	if u.RawQuery == "" {
+		return
+	}
+	var kept []string
+	for _, kv := range strings.Split(u.RawQuery, "&") {
+		if kv == "" {
+			continue
+		}
+		k, _, _ := strings.Cut(kv, "=")
+		if name, err := url.QueryUnescape(k); err == nil {
+			k = name
+		}
+		if r.Match(k) {
+			continue
+		}
+		kept = append(kept, kv)
+	}
+	u.RawQuery = strings.Join(kept, "&")
+	u.ForceQuery = false
+}
+
+// DefaultRedirectors are the hosts of known link shorteners and tracking redirectors.
+var DefaultRedirectors = []string{
+	"bit.ly",
+	"buff.ly",
+	"dlvr.it",
+	"fb.me",
+	"feedproxy.google.com",
+	"goo.gl",
+	"lnkd.in",
+	"ow.ly",
+	"t.co",
+	"tinyurl.com",
+	"trib.al",
+}
+
+// wrappers are redirectors carrying their destination in a query parameter.
+var wrappers = map[string]string{
+	"www.google.com/url":    "q",
+	"l.facebook.com/l.php":  "u",
+	"lm.facebook.com/l.php": "u",
+	"t.umblr.com/redirect":  "z",
+	"l.messenger.com/l.php": "u",
+	"href.li/":              "",
+}
+
+// Resolver resolves a redirector link to its destination.
+type Resolver interface {
+	Resolve(ctx context.Context, u *url.URL) (*url.URL, error)
+}
+
+// HTTPResolver resolves links by following their HTTP redirects.
+type HTTPResolver struct {
+	Client *http.Client
+}
+
+// Resolve follows the redirects of a HEAD request,
+// or of a GET request for the shorteners rejecting HEAD requests.
+func (r HTTPResolver) Resolve(ctx context.Context, u *url.URL) (*url.URL, error) {
+	resp, err := r.do(ctx, http.MethodHead, u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		resp, err = r.do(ctx, http.MethodGet, u)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("resolving %s: unexpected status %s", u, resp.Status)
+	}
+	return resp.Request.URL, nil
+}
+
+// do sends a request to u, the body of the response is closed.
+func (r HTTPResolver) do(ctx context.Context, method string, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+func (r HTTPResolver) client() *http.Client {
+	if r.Client != nil {
+		return r.Client
+	}
+	return defaultClient
+}
+
+var defaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// maxPageSize bounds how much of a page is read looking for its canonical link.
+const maxPageSize = 1 << 20
+
+// Canonicalizer rewrites links to their canonical form.
+type Canonicalizer struct {
+	rules         Rules
+	redirectors   map[string]bool
+	resolver      Resolver
+	linkCanonical bool
+	client        *http.Client
+}
+
+type Option func(*Canonicalizer)
+
+// WithRules replaces the DefaultRules used to strip query parameters.
+func WithRules(rules Rules) Option {
+	return func(c *Canonicalizer) {
+		c.rules = rules
+	}
+}
+
+// WithRedirectors replaces the DefaultRedirectors resolved by the resolver.
+func WithRedirectors(hosts ...string) Option {
+	return func(c *Canonicalizer) {
+		c.redirectors = map[string]bool{}
+		for _, h := range hosts {
+			c.redirectors[strings.ToLower(h)] = true
+		}
+	}
+}
+
+// WithResolver resolves the links of redirectors using r.
+// Without a resolver, only redirectors carrying their destination in the link are unwrapped.
+func WithResolver(r Resolver) Option {
+	return func(c *Canonicalizer) {
+		c.resolver = r
+	}
+}
+
+// WithLinkCanonical fetches pages using client and prefers their <link rel=canonical>.
+// AMP pages are required to link to their canonical page.
+func WithLinkCanonical(client *http.Client) Option {
+	return func(c *Canonicalizer) {
+		c.linkCanonical = true
+		c.client = client
+		if c.client == nil {
+			c.client = defaultClient
+		}
+	}
+}
+
+func New(opts ...Option) *Canonicalizer {
+	c := &Canonicalizer{rules: DefaultRules}
+	WithRedirectors(DefaultRedirectors...)(c)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Canonicalize returns the canonical form of rawURL.
+// Links that cannot be resolved or fetched are logged and kept as they are,
+// a broken link is never a reason not to publish a post.
+func (c *Canonicalizer) Canonicalize(ctx context.Context, rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || !isHTTP(u) {
+		return rawURL
+	}
+
+	u = c.unwrap(u)
+	if c.resolver != nil && c.redirectors[strings.ToLower(u.Hostname())] {
+		resolved, err := c.resolver.Resolve(ctx, u)
+		if err != nil {
+			log.Printf("canonical: resolving %s: %v", u, err)
+		} else if isHTTP(resolved) {
+			u = c.unwrap(resolved)
+		}
+	}
+
+	u = c.clean(u)
+
+	if c.linkCanonical {
+		cu, err := c.fetchCanonical(ctx, u)
+		if err != nil {
+			log.Printf("canonical: fetching %s: %v", u, err)
+		} else if cu != nil {
+			u = c.clean(cu)
+		}
+	}
+
+	return u.String()
+}
+
+// unwrap returns the destination of redirectors and AMP caches
+// carrying it in their link.
+func (c *Canonicalizer) unwrap(u *url.URL) *url.URL {
+	// Bound the unwrapping of redirectors wrapping each other.
+	for range 5 {
+		next := unwrapOnce(u)
+		if next == nil {
+			return u
+		}
+		u = next
+	}
+	return u
+}
+
+func unwrapOnce(u *url.URL) *url.URL {
+	host := strings.ToLower(u.Hostname())
+
+	// https://www.google.com/amp/s/example.com/post and
+	// https://example-com.cdn.ampproject.org/c/s/example.com/post
+	var rest string
+	switch {
+	case (host == "www.google.com" || host == "google.com") && strings.HasPrefix(u.Path, "/amp/"):
+		rest = strings.TrimPrefix(u.Path, "/amp/")
+	case strings.HasSuffix(host, ".cdn.ampproject.org"):
+		_, rest, _ = strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	}
+	if rest != "" {
+		scheme := "http"
+		if after, ok := strings.CutPrefix(rest, "s/"); ok {
+			scheme, rest = "https", after
+		}
+		dest, err := url.Parse(scheme + "://" + rest)
+		if err != nil || dest.Host == "" {
+			return nil
+		}
+		dest.RawQuery = u.RawQuery
+		return dest
+	}
+
+	param, ok := wrappers[host+u.Path]
+	if !ok {
+		return nil
+	}
+	if param == "" {
+		// href.li puts the destination in the raw query.
+		dest, err := url.Parse(u.RawQuery)
+		if err != nil || !isHTTP(dest) {
+			return nil
+		}
+		return dest
+	}
+	dest, err := url.Parse(u.Query().Get(param))
+	if err != nil || !isHTTP(dest) {
+		return nil
+	}
+	return dest
+}
+
+// clean strips tracking parameters and normalizes the scheme, host and path of u.
+// Paths have no trailing slash, except the root path.
+func (c *Canonicalizer) clean(u *url.URL) *url.URL {
+	cu := *u
+	cu.Scheme = strings.ToLower(cu.Scheme)
+	host := strings.ToLower(cu.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	switch port := cu.Port(); {
+	case port == "", cu.Scheme == "http" && port == "80", cu.Scheme == "https" && port == "443":
+	default:
+		host += ":" + port
+	}
+	cu.Host = host
+	cu.Path = strings.TrimRight(cu.Path, "/")
+	cu.RawPath = strings.TrimRight(cu.RawPath, "/")
+	if cu.Path == "" {
+		cu.Path = "/"
+		cu.RawPath = ""
+	}
+	c.rules.Strip(&cu)
+	return &cu
+}
+
+// fetchCanonical returns the <link rel=canonical> of the page at u, if any.
+func (c *Canonicalizer) fetchCanonical(ctx context.Context, u *url.URL) (*url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "html") {
+		return nil, nil
+	}
+
+	href := findCanonical(io.LimitReader(resp.Body, maxPageSize))
+	if href == "" {
+		return nil, nil
+	}
+	cu, err := resp.Request.URL.Parse(href)
+	if err != nil || !isHTTP(cu) {
+		return nil, nil
+	}
+	return cu, nil
+}
+
+// findCanonical returns the href of the first <link rel=canonical> in the head of a page.
+func findCanonical(r io.Reader) string {
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				return ""
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) == "body" {
+				return ""
+			}
+			if string(name) != "link" || !hasAttr {
+				continue
+			}
+			var rel, href string
+			for {
+				k, v, more := z.TagAttr()
+				switch string(k) {
+				case "rel":
+					rel = string(v)
+				case "href":
+					href = string(v)
+				}
+				if !more {
+					break
+				}
+			}
+			for _, r := range strings.Fields(rel) {
+				if strings.EqualFold(r, "canonical") {
+					return strings.TrimSpace(href)
+				}
+			}
+		}
+	}
+}
+
+func isHTTP(u *url.URL) bool {
+	return u != nil && (strings.EqualFold(u.Scheme, "http") || strings.EqualFold(u.Scheme, "https")) && u.Host != ""
+}
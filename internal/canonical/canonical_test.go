@@ -0,0 +1,128 @@
+package canonical
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func Test_Canonicalize(t *testing.T) {
+	c := New()
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"https://example.com/post?utm_source=rss&utm_medium=feed", "https://example.com/post"},
+		{"https://example.com/post?id=3&fbclid=abc&ref=hn&b=%20x", "https://example.com/post?id=3&b=%20x"},
+		{" HTTPS://Example.COM:443 ", "https://example.com/"},
+		{"https://example.com/", "https://example.com/"},
+		{"https://example.com/a/b/?utm_source=rss", "https://example.com/a/b"},
+		{"https://example.com/a%2Fb//", "https://example.com/a%2Fb"},
+		{"http://example.com:8080/a#section", "http://example.com:8080/a#section"},
+		{"https://www.google.com/amp/s/example.com/post/amp", "https://example.com/post/amp"},
+		{"https://example-com.cdn.ampproject.org/c/s/example.com/post?utm_campaign=x", "https://example.com/post"},
+		{"https://www.google.com/url?q=https://example.com/a%3Fgclid%3D1&sa=D", "https://example.com/a"},
+		{"https://l.facebook.com/l.php?u=https%3A%2F%2Fexample.com%2Fb&h=AT0", "https://example.com/b"},
+		{"https://href.li/?https://example.com/c", "https://example.com/c"},
+		{"mailto:someone@example.com", "mailto:someone@example.com"},
+		{"not a url", "not a url"},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			assert.Equal(t, c.Canonicalize(ctx, tc.in), tc.want)
+		})
+	}
+}
+
+type resolverFunc func(ctx context.Context, u *url.URL) (*url.URL, error)
+
+func (f resolverFunc) Resolve(ctx context.Context, u *url.URL) (*url.URL, error) {
+	return f(ctx, u)
+}
+
+func Test_Canonicalize_Resolver(t *testing.T) {
+	var resolved []string
+	c := New(WithResolver(resolverFunc(func(ctx context.Context, u *url.URL) (*url.URL, error) {
+		resolved = append(resolved, u.String())
+		if u.Path == "/broken" {
+			return nil, errors.New("boom")
+		}
+		return url.Parse("https://example.com/article?utm_source=twitter")
+	})))
+	ctx := context.Background()
+
+	assert.Equal(t, c.Canonicalize(ctx, "https://t.co/abc"), "https://example.com/article")
+	assert.Equal(t, c.Canonicalize(ctx, "https://bit.ly/broken?utm_source=x"), "https://bit.ly/broken")
+	assert.Equal(t, c.Canonicalize(ctx, "https://example.org/abc"), "https://example.org/abc")
+	assert.DeepEqual(t, resolved, []string{"https://t.co/abc", "https://bit.ly/broken?utm_source=x"})
+}
+
+func Test_HTTPResolver(t *testing.T) {
+	var methods []string
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/short", func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		http.Redirect(w, r, "/article", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/gone", http.NotFound)
+
+	r := HTTPResolver{Client: srv.Client()}
+	ctx := context.Background()
+
+	u, _ := url.Parse(srv.URL + "/short")
+	resolved, err := r.Resolve(ctx, u)
+	assert.NilError(t, err)
+	assert.Equal(t, resolved.String(), srv.URL+"/article")
+	assert.DeepEqual(t, methods, []string{http.MethodHead, http.MethodGet})
+
+	u, _ = url.Parse(srv.URL + "/gone")
+	_, err = r.Resolve(ctx, u)
+	assert.ErrorContains(t, err, "unexpected status 404")
+}
+
+func Test_Canonicalize_LinkCanonical(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/amp/post", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<!doctype html><html><head><title>x</title><link rel="canonical" href="/post?utm_source=amp"></head><body></body></html>`)
+	})
+	mux.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head></head><body><link rel="canonical" href="/ignored"></body></html>`)
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+
+	c := New(WithLinkCanonical(srv.Client()))
+	ctx := context.Background()
+
+	assert.Equal(t, c.Canonicalize(ctx, srv.URL+"/amp/post"), srv.URL+"/post")
+	assert.Equal(t, c.Canonicalize(ctx, srv.URL+"/other"), srv.URL+"/other")
+	assert.Equal(t, c.Canonicalize(ctx, srv.URL+"/missing?fbclid=1"), srv.URL+"/missing")
+}
+
+func Test_Rules(t *testing.T) {
+	r := Rules{"utm_*", "ref"}
+	assert.Assert(t, r.Match("UTM_Source"))
+	assert.Assert(t, r.Match("ref"))
+	assert.Assert(t, !r.Match("referrer"))
+
+	u, err := url.Parse("https://example.com/?ref=a&q=b&utm_x")
+	assert.NilError(t, err)
+	r.Strip(u)
+	assert.Equal(t, u.String(), "https://example.com/?q=b")
+}
@@ -0,0 +1,1085 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/seriousben/positronic-blogger/internal/archive"
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"github.com/seriousben/positronic-blogger/internal/bookmarks"
+	"github.com/seriousben/positronic-blogger/internal/canonical"
+	"github.com/seriousben/positronic-blogger/internal/dedup"
+	"github.com/seriousben/positronic-blogger/internal/favorites"
+	"github.com/seriousben/positronic-blogger/internal/feed"
+	"github.com/seriousben/positronic-blogger/internal/github"
+	"github.com/seriousben/positronic-blogger/internal/linkpreview"
+	"github.com/seriousben/positronic-blogger/internal/localgit"
+	"github.com/seriousben/positronic-blogger/internal/newsblur"
+	"github.com/seriousben/positronic-blogger/internal/poster"
+	"github.com/seriousben/positronic-blogger/internal/scheduler"
+	"github.com/seriousben/positronic-blogger/internal/template"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvConfigFile is the environment variable holding the path of the config file.
+const EnvConfigFile = "POSITRONIC_CONFIG"
+
+const (
+	defaultServerContentPath = "content/links"
+	defaultServerPostURL     = "https://seriousben.com/links/"
+	// defaultDiscordAllowedUser is the only user allowed to post before allowed users were configurable.
+	defaultDiscordAllowedUser = "905167870403702884"
+)
+
+type GitHub struct {
+	Repo       string `toml:"repo" yaml:"repo"`
+	Token      string `toml:"token" yaml:"token"`
+	BaseBranch string `toml:"base_branch" yaml:"base_branch"`
+}
+
+type Git struct {
+	Dir        string `toml:"dir" yaml:"dir"`
+	BaseBranch string `toml:"base_branch" yaml:"base_branch"`
+}
+
+type Identity struct {
+	AuthorName     string   `toml:"author_name" yaml:"author_name"`
+	AuthorEmail    string   `toml:"author_email" yaml:"author_email"`
+	CommitterName  string   `toml:"committer_name" yaml:"committer_name"`
+	CommitterEmail string   `toml:"committer_email" yaml:"committer_email"`
+	Trailers       []string `toml:"trailers" yaml:"trailers"`
+}
+
+// Person is a git identity.
+type Person struct {
+	Name  string `toml:"name" yaml:"name"`
+	Email string `toml:"email" yaml:"email"`
+}
+
+type Template struct {
+	File        string `toml:"file" yaml:"file"`
+	RepoFile    string `toml:"repo_file" yaml:"repo_file"`
+	FrontMatter string `toml:"front_matter" yaml:"front_matter"`
+	Generator   string `toml:"generator" yaml:"generator"`
+}
+
+// Canonical configures how post links are cleaned up.
+type Canonical struct {
+	Disabled bool `toml:"disabled" yaml:"disabled"`
+	// StripParams replaces the default tracking parameters stripped from links.
+	StripParams      []string `toml:"strip_params" yaml:"strip_params"`
+	ResolveRedirects bool     `toml:"resolve_redirects" yaml:"resolve_redirects"`
+	// Redirectors replaces the default shortener hosts resolved when ResolveRedirects is set.
+	Redirectors   []string `toml:"redirectors" yaml:"redirectors"`
+	LinkCanonical bool     `toml:"link_canonical" yaml:"link_canonical"`
+}
+
+// Preview configures the enrichment of posts with the metadata of the pages they link to.
+type Preview struct {
+	Enabled bool `toml:"enabled" yaml:"enabled"`
+	// Timeout bounds the time spent fetching a page, such as 5s.
+	Timeout string `toml:"timeout" yaml:"timeout"`
+	// CacheTTL is how long the metadata of a page is kept, such as 24h.
+	CacheTTL string `toml:"cache_ttl" yaml:"cache_ttl"`
+}
+
+// Archive configures the archival of the pages posts link to.
+type Archive struct {
+	// Wayback requests a snapshot of each page from the Wayback Machine.
+	Wayback bool `toml:"wayback" yaml:"wayback"`
+	// WaybackURL replaces the URL of the Wayback Machine, such as a local stand-in.
+	WaybackURL string `toml:"wayback_url" yaml:"wayback_url"`
+	// CopyPath stores a readable copy of each page under this path of the repository.
+	CopyPath string `toml:"copy_path" yaml:"copy_path"`
+	Timeout  string `toml:"timeout" yaml:"timeout"`
+}
+
+type NewsBlur struct {
+	URL            string `toml:"url" yaml:"url"`
+	Username       string `toml:"username" yaml:"username"`
+	Password       string `toml:"password" yaml:"password"`
+	ContentPath    string `toml:"content_path" yaml:"content_path"`
+	CheckpointPath string `toml:"checkpoint_path" yaml:"checkpoint_path"`
+}
+
+// Feed is an RSS, Atom or JSON Feed feed new posts are synced from,
+// such as the starred items of a feed reader.
+type Feed struct {
+	// Name identifies the feed in logs and errors, it defaults to the host of its URL.
+	Name string `toml:"name" yaml:"name"`
+	// URL is an http or https URL, or the path of a feed written to disk by another tool.
+	URL string `toml:"url" yaml:"url"`
+	// ContentPath defaults to newsblur.content_path.
+	ContentPath    string `toml:"content_path" yaml:"content_path"`
+	CheckpointPath string `toml:"checkpoint_path" yaml:"checkpoint_path"`
+}
+
+// Bookmarks configures a Pinboard or Linkding account as a source of posts.
+type Bookmarks struct {
+	// API is pinboard, for Pinboard and compatible servers, or linkding.
+	API string `toml:"api" yaml:"api"`
+	// URL replaces the URL of the Pinboard API, it is required for Linkding.
+	URL   string `toml:"url" yaml:"url"`
+	Token string `toml:"token" yaml:"token"`
+	// Tag is the tag of the bookmarks to publish, such as blog.
+	Tag string `toml:"tag" yaml:"tag"`
+	// ContentPath defaults to newsblur.content_path.
+	ContentPath    string `toml:"content_path" yaml:"content_path"`
+	CheckpointPath string `toml:"checkpoint_path" yaml:"checkpoint_path"`
+}
+
+// HackerNews configures the public favorites of a Hacker News user as a source of posts.
+type HackerNews struct {
+	User string `toml:"user" yaml:"user"`
+	// URL and APIURL replace the URLs of the site and of its API, such as a local stub.
+	URL            string `toml:"url" yaml:"url"`
+	APIURL         string `toml:"api_url" yaml:"api_url"`
+	ContentPath    string `toml:"content_path" yaml:"content_path"`
+	CheckpointPath string `toml:"checkpoint_path" yaml:"checkpoint_path"`
+}
+
+// Lobsters configures the saved stories of a Lobsters user as a source of posts.
+type Lobsters struct {
+	// Session is the value of the lobster_trap cookie of a logged in browser.
+	Session string `toml:"session" yaml:"session"`
+	// URL replaces the URL of Lobsters, such as another instance or a local stub.
+	URL            string `toml:"url" yaml:"url"`
+	ContentPath    string `toml:"content_path" yaml:"content_path"`
+	CheckpointPath string `toml:"checkpoint_path" yaml:"checkpoint_path"`
+}
+
+// Mastodon configures the bookmarked or favourited statuses of a Mastodon user as a source of posts.
+type Mastodon struct {
+	// URL is the URL of the instance, such as https://mastodon.social.
+	URL string `toml:"url" yaml:"url"`
+	// Token is the access token of an application with the read:bookmarks or read:favourites scope.
+	Token string `toml:"token" yaml:"token"`
+	// List is bookmarks, the default, or favourites.
+	List           string `toml:"list" yaml:"list"`
+	ContentPath    string `toml:"content_path" yaml:"content_path"`
+	CheckpointPath string `toml:"checkpoint_path" yaml:"checkpoint_path"`
+}
+
+type Sync struct {
+	SkipMerge  bool   `toml:"skip_merge" yaml:"skip_merge"`
+	Batch      bool   `toml:"batch" yaml:"batch"`
+	Duplicates string `toml:"duplicates" yaml:"duplicates"`
+	// Schedule runs positronic-sync as a daemon, on an interval such as 15m
+	// or a cron expression such as */15 * * * *.
+	Schedule string `toml:"schedule" yaml:"schedule"`
+	// Jitter delays each scheduled run by a random duration up to Jitter.
+	Jitter string `toml:"jitter" yaml:"jitter"`
+	// DryRun prints the diff of what would be committed instead of touching the repository.
+	DryRun bool `toml:"dry_run" yaml:"dry_run"`
+	// PreviewDir is where a dry run writes the files it would commit.
+	PreviewDir string `toml:"preview_dir" yaml:"preview_dir"`
+}
+
+type Discord struct {
+	Token        string   `toml:"token" yaml:"token"`
+	AppID        string   `toml:"app_id" yaml:"app_id"`
+	GuildID      string   `toml:"guild_id" yaml:"guild_id"`
+	AllowedUsers []string `toml:"allowed_users" yaml:"allowed_users"`
+	// Identities maps Discord user IDs to the git identity their posts are authored by.
+	Identities map[string]Person `toml:"identities" yaml:"identities"`
+}
+
+type Server struct {
+	DryRun      bool    `toml:"dry_run" yaml:"dry_run"`
+	ContentPath string  `toml:"content_path" yaml:"content_path"`
+	PostURL     string  `toml:"post_url" yaml:"post_url"`
+	Duplicates  string  `toml:"duplicates" yaml:"duplicates"`
+	Discord     Discord `toml:"discord" yaml:"discord"`
+}
+
+// Config is the configuration shared by positronic-sync and positronic-server.
+type Config struct {
+	GitHub     GitHub     `toml:"github" yaml:"github"`
+	Git        Git        `toml:"git" yaml:"git"`
+	Identity   Identity   `toml:"identity" yaml:"identity"`
+	Template   Template   `toml:"template" yaml:"template"`
+	Canonical  Canonical  `toml:"canonical" yaml:"canonical"`
+	Preview    Preview    `toml:"preview" yaml:"preview"`
+	Archive    Archive    `toml:"archive" yaml:"archive"`
+	NewsBlur   NewsBlur   `toml:"newsblur" yaml:"newsblur"`
+	Feeds      []Feed     `toml:"feeds" yaml:"feeds"`
+	Bookmarks  Bookmarks  `toml:"bookmarks" yaml:"bookmarks"`
+	HackerNews HackerNews `toml:"hackernews" yaml:"hackernews"`
+	Lobsters   Lobsters   `toml:"lobsters" yaml:"lobsters"`
+	Mastodon   Mastodon   `toml:"mastodon" yaml:"mastodon"`
+	Sync       Sync       `toml:"sync" yaml:"sync"`
+	Server     Server     `toml:"server" yaml:"server"`
+
+	// loadErrs are the problems found decoding the file and the environment,
+	// reported along with the validation errors.
+	loadErrs []error
+}
+
+// envVar binds an environment variable to a config field.
+type envVar struct {
+	name  string
+	field string
+	value any
+}
+
+func (c *Config) envVars() []envVar {
+	return []envVar{
+		{"POSITRONIC_GITHUB_REPO", "github.repo", &c.GitHub.Repo},
+		{"POSITRONIC_GITHUB_TOKEN", "github.token", &c.GitHub.Token},
+		{"POSITRONIC_GITHUB_BASE_BRANCH", "github.base_branch", &c.GitHub.BaseBranch},
+		{"POSITRONIC_GIT_DIR", "git.dir", &c.Git.Dir},
+		{"POSITRONIC_GIT_BASE_BRANCH", "git.base_branch", &c.Git.BaseBranch},
+		{"POSITRONIC_AUTHOR_NAME", "identity.author_name", &c.Identity.AuthorName},
+		{"POSITRONIC_AUTHOR_EMAIL", "identity.author_email", &c.Identity.AuthorEmail},
+		{"POSITRONIC_COMMITTER_NAME", "identity.committer_name", &c.Identity.CommitterName},
+		{"POSITRONIC_COMMITTER_EMAIL", "identity.committer_email", &c.Identity.CommitterEmail},
+		{"POSITRONIC_COMMIT_TRAILERS", "identity.trailers", &c.Identity.Trailers},
+		{"POSITRONIC_TEMPLATE_FILE", "template.file", &c.Template.File},
+		{"POSITRONIC_TEMPLATE_REPO_FILE", "template.repo_file", &c.Template.RepoFile},
+		{"POSITRONIC_FRONT_MATTER", "template.front_matter", &c.Template.FrontMatter},
+		{"POSITRONIC_GENERATOR", "template.generator", &c.Template.Generator},
+		{"POSITRONIC_CANONICAL_DISABLED", "canonical.disabled", &c.Canonical.Disabled},
+		{"POSITRONIC_CANONICAL_STRIP_PARAMS", "canonical.strip_params", &c.Canonical.StripParams},
+		{"POSITRONIC_CANONICAL_RESOLVE_REDIRECTS", "canonical.resolve_redirects", &c.Canonical.ResolveRedirects},
+		{"POSITRONIC_CANONICAL_REDIRECTORS", "canonical.redirectors", &c.Canonical.Redirectors},
+		{"POSITRONIC_CANONICAL_LINK", "canonical.link_canonical", &c.Canonical.LinkCanonical},
+		{"POSITRONIC_PREVIEW", "preview.enabled", &c.Preview.Enabled},
+		{"POSITRONIC_PREVIEW_TIMEOUT", "preview.timeout", &c.Preview.Timeout},
+		{"POSITRONIC_PREVIEW_CACHE_TTL", "preview.cache_ttl", &c.Preview.CacheTTL},
+		{"POSITRONIC_ARCHIVE_WAYBACK", "archive.wayback", &c.Archive.Wayback},
+		{"POSITRONIC_ARCHIVE_WAYBACK_URL", "archive.wayback_url", &c.Archive.WaybackURL},
+		{"POSITRONIC_ARCHIVE_COPY_PATH", "archive.copy_path", &c.Archive.CopyPath},
+		{"POSITRONIC_ARCHIVE_TIMEOUT", "archive.timeout", &c.Archive.Timeout},
+		{"POSITRONIC_NEWSBLUR_URL", "newsblur.url", &c.NewsBlur.URL},
+		{"POSITRONIC_NEWSBLUR_USERNAME", "newsblur.username", &c.NewsBlur.Username},
+		{"POSITRONIC_NEWSBLUR_PASSWORD", "newsblur.password", &c.NewsBlur.Password},
+		{"POSITRONIC_NEWSBLUR_CONTENT_PATH", "newsblur.content_path", &c.NewsBlur.ContentPath},
+		{"POSITRONIC_NEWSBLUR_CHECKPOINT_PATH", "newsblur.checkpoint_path", &c.NewsBlur.CheckpointPath},
+		{"POSITRONIC_BOOKMARKS_API", "bookmarks.api", &c.Bookmarks.API},
+		{"POSITRONIC_BOOKMARKS_URL", "bookmarks.url", &c.Bookmarks.URL},
+		{"POSITRONIC_BOOKMARKS_TOKEN", "bookmarks.token", &c.Bookmarks.Token},
+		{"POSITRONIC_BOOKMARKS_TAG", "bookmarks.tag", &c.Bookmarks.Tag},
+		{"POSITRONIC_BOOKMARKS_CONTENT_PATH", "bookmarks.content_path", &c.Bookmarks.ContentPath},
+		{"POSITRONIC_BOOKMARKS_CHECKPOINT_PATH", "bookmarks.checkpoint_path", &c.Bookmarks.CheckpointPath},
+		{"POSITRONIC_HACKERNEWS_USER", "hackernews.user", &c.HackerNews.User},
+		{"POSITRONIC_HACKERNEWS_URL", "hackernews.url", &c.HackerNews.URL},
+		{"POSITRONIC_HACKERNEWS_API_URL", "hackernews.api_url", &c.HackerNews.APIURL},
+		{"POSITRONIC_HACKERNEWS_CONTENT_PATH", "hackernews.content_path", &c.HackerNews.ContentPath},
+		{"POSITRONIC_HACKERNEWS_CHECKPOINT_PATH", "hackernews.checkpoint_path", &c.HackerNews.CheckpointPath},
+		{"POSITRONIC_LOBSTERS_SESSION", "lobsters.session", &c.Lobsters.Session},
+		{"POSITRONIC_LOBSTERS_URL", "lobsters.url", &c.Lobsters.URL},
+		{"POSITRONIC_LOBSTERS_CONTENT_PATH", "lobsters.content_path", &c.Lobsters.ContentPath},
+		{"POSITRONIC_LOBSTERS_CHECKPOINT_PATH", "lobsters.checkpoint_path", &c.Lobsters.CheckpointPath},
+		{"POSITRONIC_MASTODON_URL", "mastodon.url", &c.Mastodon.URL},
+		{"POSITRONIC_MASTODON_TOKEN", "mastodon.token", &c.Mastodon.Token},
+		{"POSITRONIC_MASTODON_LIST", "mastodon.list", &c.Mastodon.List},
+		{"POSITRONIC_MASTODON_CONTENT_PATH", "mastodon.content_path", &c.Mastodon.ContentPath},
+		{"POSITRONIC_MASTODON_CHECKPOINT_PATH", "mastodon.checkpoint_path", &c.Mastodon.CheckpointPath},
+		{"POSITRONIC_SKIP_MERGE", "sync.skip_merge", &c.Sync.SkipMerge},
+		{"POSITRONIC_BATCH", "sync.batch", &c.Sync.Batch},
+		{"POSITRONIC_SYNC_DUPLICATES", "sync.duplicates", &c.Sync.Duplicates},
+		{"POSITRONIC_SYNC_SCHEDULE", "sync.schedule", &c.Sync.Schedule},
+		{"POSITRONIC_SYNC_JITTER", "sync.jitter", &c.Sync.Jitter},
+		{"POSITRONIC_SYNC_DRY_RUN", "sync.dry_run", &c.Sync.DryRun},
+		{"POSITRONIC_SYNC_PREVIEW_DIR", "sync.preview_dir", &c.Sync.PreviewDir},
+		{"POSITRONIC_DRY_RUN", "server.dry_run", &c.Server.DryRun},
+		{"POSITRONIC_BLOG_CONTENT_PATH", "server.content_path", &c.Server.ContentPath},
+		{"POSITRONIC_BLOG_POST_URL", "server.post_url", &c.Server.PostURL},
+		{"POSITRONIC_SERVER_DUPLICATES", "server.duplicates", &c.Server.Duplicates},
+		{"POSITRONIC_DISCORD_TOKEN", "server.discord.token", &c.Server.Discord.Token},
+		{"POSITRONIC_DISCORD_APPID", "server.discord.app_id", &c.Server.Discord.AppID},
+		{"POSITRONIC_DISCORD_GUILDID", "server.discord.guild_id", &c.Server.Discord.GuildID},
+		{"POSITRONIC_DISCORD_ALLOWED_USERS", "server.discord.allowed_users", &c.Server.Discord.AllowedUsers},
+	}
+}
+
+// envName returns the environment variable overriding field.
+func (c *Config) envName(field string) string {
+	for _, ev := range c.envVars() {
+		if ev.field == field {
+			return ev.name
+		}
+	}
+	return ""
+}
+
+// Load reads the config file at path, when path is not empty,
+// and overrides its values with the POSITRONIC_* environment variables.
+// Only a file that cannot be read is an error, malformed fields are reported
+// at once with the other problems of the config by the Validate methods.
+func Load(path string) (*Config, error) {
+	var c Config
+
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		c.loadErrs = append(c.loadErrs, c.decodeFile(path, b)...)
+	}
+
+	c.loadErrs = append(c.loadErrs, c.applyEnv(os.LookupEnv)...)
+
+	return &c, nil
+}
+
+func (c *Config) decodeFile(path string, b []byte) []error {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		md, err := toml.Decode(string(b), c)
+		if err != nil {
+			return []error{fmt.Errorf("decoding config file %s: %w", path, err)}
+		}
+		var errs []error
+		for _, k := range md.Undecoded() {
+			errs = append(errs, fmt.Errorf("%s: unknown field", k))
+		}
+		return errs
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && err != io.EOF {
+			return []error{fmt.Errorf("decoding config file %s: %w", path, err)}
+		}
+		return nil
+	default:
+		return []error{fmt.Errorf("unknown config file extension %q, expected .toml, .yaml or .yml", ext)}
+	}
+}
+
+func (c *Config) applyEnv(lookup func(string) (string, bool)) []error {
+	var errs []error
+	for _, ev := range c.envVars() {
+		v, ok := lookup(ev.name)
+		if !ok || v == "" {
+			continue
+		}
+		switch p := ev.value.(type) {
+		case *string:
+			*p = v
+		case *bool:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s (%s): malformed boolean %q", ev.field, ev.name, v))
+				continue
+			}
+			*p = b
+		case *[]string:
+			*p = nil
+			for _, s := range strings.Split(v, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					*p = append(*p, s)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// validator accumulates every problem found in a config.
+type validator struct {
+	c    *Config
+	errs []error
+}
+
+// newValidator returns a validator of c starting with the problems found loading it.
+func newValidator(c *Config) *validator {
+	return &validator{c: c, errs: slices.Clone(c.loadErrs)}
+}
+
+func (v *validator) errorf(field, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if env := v.c.envName(field); env != "" {
+		v.errs = append(v.errs, fmt.Errorf("%s (%s): %s", field, env, msg))
+		return
+	}
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", field, msg))
+}
+
+func (v *validator) required(field, value string) {
+	if value == "" {
+		v.errorf(field, "is required")
+	}
+}
+
+// signature checks that a name and email are either both set or both empty.
+func (v *validator) signature(field, name, email string) {
+	if name == "" && email != "" {
+		v.errorf(field+"_name", "is required when %s_email is set", field)
+	}
+	if name != "" && email == "" {
+		v.errorf(field+"_email", "is required when %s_name is set", field)
+	}
+}
+
+// duration checks that value is empty or a positive duration.
+func (v *validator) duration(field, value string) {
+	if value == "" {
+		return
+	}
+	if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+		v.errorf(field, "malformed duration %q", value)
+	}
+}
+
+func (v *validator) policy(field, value string) {
+	if _, err := dedup.ParsePolicy(value); err != nil {
+		v.errorf(field, "%v", err)
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n%w", errors.Join(v.errs...))
+}
+
+func (c *Config) validateCommon(v *validator) {
+	if c.Git.Dir == "" {
+		v.required("github.repo", c.GitHub.Repo)
+		v.required("github.token", c.GitHub.Token)
+		if c.GitHub.Repo != "" {
+			if _, _, err := c.GitHub.ownerRepo(); err != nil {
+				v.errorf("github.repo", "%v", err)
+			}
+		}
+	}
+
+	v.signature("identity.author", c.Identity.AuthorName, c.Identity.AuthorEmail)
+	v.signature("identity.committer", c.Identity.CommitterName, c.Identity.CommitterEmail)
+	for _, t := range c.Identity.Trailers {
+		if _, err := backend.ParseTrailer(t); err != nil {
+			v.errorf("identity.trailers", "%v", err)
+		}
+	}
+
+	if c.Template.File != "" && c.Template.RepoFile != "" {
+		v.errorf("template.file", "cannot be set along with template.repo_file")
+	}
+	if c.Template.FrontMatter != "" {
+		if _, err := template.ParseFormat(c.Template.FrontMatter); err != nil {
+			v.errorf("template.front_matter", "%v", err)
+		}
+	}
+	if c.Template.Generator != "" {
+		if _, err := template.LookupGenerator(c.Template.Generator); err != nil {
+			v.errorf("template.generator", "%v", err)
+		}
+	}
+	v.duration("preview.timeout", c.Preview.Timeout)
+	v.duration("preview.cache_ttl", c.Preview.CacheTTL)
+	v.duration("archive.timeout", c.Archive.Timeout)
+	if c.Archive.WaybackURL != "" && !c.Archive.Wayback {
+		v.errorf("archive.wayback_url", "requires archive.wayback")
+	}
+}
+
+// validateArchive checks that archive copies are stored apart from the posts,
+// a copy is named after its post and would replace it. It must be called once content paths are defaulted.
+func (c *Config) validateArchive(v *validator) {
+	if c.Archive.CopyPath == "" {
+		return
+	}
+	copyPath := cleanDir(c.Archive.CopyPath)
+	if copyPath == "" {
+		v.errorf("archive.copy_path", "must not be the root of the repository")
+		return
+	}
+	for _, p := range c.ContentPaths() {
+		p = cleanDir(p)
+		if p == "" || copyPath == p || strings.HasPrefix(copyPath, p+"/") || strings.HasPrefix(p, copyPath+"/") {
+			v.errorf("archive.copy_path", "must not overlap the content path %s", p)
+		}
+	}
+}
+
+// cleanDir returns the slash separated, relative form of dir, empty for the root of the repository.
+func cleanDir(dir string) string {
+	return strings.Trim(path.Clean("/"+filepath.ToSlash(dir)), "/")
+}
+
+// generatorContentPath returns the content path of the configured generator, if any.
+func (c *Config) generatorContentPath() string {
+	if c.Template.Generator == "" {
+		return ""
+	}
+	g, err := template.LookupGenerator(c.Template.Generator)
+	if err != nil {
+		return ""
+	}
+	return g.ContentPath
+}
+
+// ValidateSync checks the configuration needed by positronic-sync and fills in defaults.
+func (c *Config) ValidateSync() error {
+	v := newValidator(c)
+	c.validateCommon(v)
+
+	if c.NewsBlur.ContentPath == "" {
+		c.NewsBlur.ContentPath = c.generatorContentPath()
+	}
+
+	if c.NewsBlurEnabled() {
+		v.required("newsblur.username", c.NewsBlur.Username)
+		v.required("newsblur.password", c.NewsBlur.Password)
+		v.required("newsblur.content_path", c.NewsBlur.ContentPath)
+		v.required("newsblur.checkpoint_path", c.NewsBlur.CheckpointPath)
+	}
+	c.validateSources(v)
+	v.policy("sync.duplicates", c.Sync.Duplicates)
+	if c.Sync.Schedule != "" {
+		if _, err := scheduler.Parse(c.Sync.Schedule); err != nil {
+			v.errorf("sync.schedule", "%v", err)
+		}
+	}
+	if c.Sync.Jitter != "" {
+		if d, err := time.ParseDuration(c.Sync.Jitter); err != nil || d < 0 {
+			v.errorf("sync.jitter", "malformed duration %q", c.Sync.Jitter)
+		}
+	}
+	if c.Sync.PreviewDir != "" && !c.Sync.DryRun {
+		v.errorf("sync.preview_dir", "requires sync.dry_run")
+	}
+	c.validateArchive(v)
+
+	return v.err()
+}
+
+// validateSources checks the sources other than NewsBlur and that no two sources share a checkpoint.
+func (c *Config) validateSources(v *validator) {
+	checkpoints := map[string]string{}
+	if c.NewsBlurEnabled() && c.NewsBlur.CheckpointPath != "" {
+		checkpoints[c.NewsBlur.CheckpointPath] = "newsblur"
+	}
+	checkpoint := func(field, path string) {
+		if path == "" {
+			return
+		}
+		if other, ok := checkpoints[path]; ok {
+			v.errorf(field, "is already the checkpoint of %s", other)
+			return
+		}
+		checkpoints[path] = strings.TrimSuffix(field, ".checkpoint_path")
+	}
+
+	for i := range c.Feeds {
+		f := &c.Feeds[i]
+		field := fmt.Sprintf("feeds[%d]", i)
+		if f.ContentPath == "" {
+			f.ContentPath = c.NewsBlur.ContentPath
+		}
+		if f.Name == "" {
+			f.Name = f.URL
+			if u, err := url.Parse(f.URL); err == nil && u.Host != "" {
+				f.Name = u.Host
+			}
+		}
+
+		v.required(field+".url", f.URL)
+		v.required(field+".content_path", f.ContentPath)
+		v.required(field+".checkpoint_path", f.CheckpointPath)
+		if u, err := url.Parse(f.URL); err != nil {
+			v.errorf(field+".url", "malformed URL %q", f.URL)
+		} else if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file" {
+			v.errorf(field+".url", "unsupported scheme %q, expected http, https or file", u.Scheme)
+		}
+		checkpoint(field+".checkpoint_path", f.CheckpointPath)
+	}
+
+	if c.bookmarksEnabled() {
+		if c.Bookmarks.ContentPath == "" {
+			c.Bookmarks.ContentPath = c.NewsBlur.ContentPath
+		}
+		if c.Bookmarks.API == "" {
+			c.Bookmarks.API = string(bookmarks.APIPinboard)
+		}
+		if _, err := bookmarks.ParseAPI(c.Bookmarks.API); err != nil {
+			v.errorf("bookmarks.api", "%v", err)
+		}
+		if c.Bookmarks.URL == "" && c.Bookmarks.API == string(bookmarks.APILinkding) {
+			v.errorf("bookmarks.url", "is required for linkding")
+		}
+		v.required("bookmarks.token", c.Bookmarks.Token)
+		v.required("bookmarks.tag", c.Bookmarks.Tag)
+		v.required("bookmarks.content_path", c.Bookmarks.ContentPath)
+		v.required("bookmarks.checkpoint_path", c.Bookmarks.CheckpointPath)
+		checkpoint("bookmarks.checkpoint_path", c.Bookmarks.CheckpointPath)
+	}
+
+	if c.HackerNews.User != "" {
+		if c.HackerNews.ContentPath == "" {
+			c.HackerNews.ContentPath = c.NewsBlur.ContentPath
+		}
+		v.required("hackernews.content_path", c.HackerNews.ContentPath)
+		v.required("hackernews.checkpoint_path", c.HackerNews.CheckpointPath)
+		checkpoint("hackernews.checkpoint_path", c.HackerNews.CheckpointPath)
+	}
+
+	if c.Lobsters.Session != "" {
+		if c.Lobsters.ContentPath == "" {
+			c.Lobsters.ContentPath = c.NewsBlur.ContentPath
+		}
+		v.required("lobsters.content_path", c.Lobsters.ContentPath)
+		v.required("lobsters.checkpoint_path", c.Lobsters.CheckpointPath)
+		checkpoint("lobsters.checkpoint_path", c.Lobsters.CheckpointPath)
+	}
+
+	if c.mastodonEnabled() {
+		if c.Mastodon.ContentPath == "" {
+			c.Mastodon.ContentPath = c.NewsBlur.ContentPath
+		}
+		list, err := favorites.ParseMastodonList(c.Mastodon.List)
+		if err != nil {
+			v.errorf("mastodon.list", "%v", err)
+		} else {
+			c.Mastodon.List = string(list)
+		}
+		v.required("mastodon.url", c.Mastodon.URL)
+		v.required("mastodon.token", c.Mastodon.Token)
+		v.required("mastodon.content_path", c.Mastodon.ContentPath)
+		v.required("mastodon.checkpoint_path", c.Mastodon.CheckpointPath)
+		checkpoint("mastodon.checkpoint_path", c.Mastodon.CheckpointPath)
+	}
+}
+
+// ValidateServer checks the configuration needed by positronic-server and fills in defaults.
+func (c *Config) ValidateServer() error {
+	v := newValidator(c)
+	c.validateCommon(v)
+
+	if c.Server.ContentPath == "" {
+		c.Server.ContentPath = c.generatorContentPath()
+	}
+	if c.Server.ContentPath == "" {
+		c.Server.ContentPath = defaultServerContentPath
+	}
+	if c.Server.PostURL == "" {
+		c.Server.PostURL = defaultServerPostURL
+	}
+
+	if len(c.Server.Discord.AllowedUsers) == 0 {
+		c.Server.Discord.AllowedUsers = []string{defaultDiscordAllowedUser}
+	}
+
+	v.required("server.discord.token", c.Server.Discord.Token)
+	v.required("server.discord.app_id", c.Server.Discord.AppID)
+	v.policy("server.duplicates", c.Server.Duplicates)
+	for id, p := range c.Server.Discord.Identities {
+		if p.Name == "" || p.Email == "" {
+			v.errorf("server.discord.identities."+id, "name and email are required")
+		}
+	}
+	c.validateArchive(v)
+
+	return v.err()
+}
+
+// ValidateLinkCheck checks the configuration needed by positronic-linkcheck and fills in defaults.
+func (c *Config) ValidateLinkCheck() error {
+	v := newValidator(c)
+	c.validateCommon(v)
+
+	if c.NewsBlur.ContentPath == "" {
+		c.NewsBlur.ContentPath = c.generatorContentPath()
+	}
+	if c.Server.ContentPath == "" {
+		c.Server.ContentPath = c.generatorContentPath()
+	}
+	if c.Server.ContentPath == "" {
+		c.Server.ContentPath = defaultServerContentPath
+	}
+	for i := range c.Feeds {
+		if c.Feeds[i].ContentPath == "" {
+			c.Feeds[i].ContentPath = c.NewsBlur.ContentPath
+		}
+	}
+	if c.Bookmarks.ContentPath == "" {
+		c.Bookmarks.ContentPath = c.NewsBlur.ContentPath
+	}
+	if c.HackerNews.ContentPath == "" {
+		c.HackerNews.ContentPath = c.NewsBlur.ContentPath
+	}
+	if c.Lobsters.ContentPath == "" {
+		c.Lobsters.ContentPath = c.NewsBlur.ContentPath
+	}
+	if c.Mastodon.ContentPath == "" {
+		c.Mastodon.ContentPath = c.NewsBlur.ContentPath
+	}
+
+	return v.err()
+}
+
+// ValidateImport checks the configuration needed by positronic-import and fills in defaults.
+func (c *Config) ValidateImport() error {
+	v := newValidator(c)
+	c.validateCommon(v)
+
+	if c.NewsBlur.ContentPath == "" {
+		c.NewsBlur.ContentPath = c.generatorContentPath()
+	}
+	if c.Server.ContentPath == "" {
+		c.Server.ContentPath = defaultServerContentPath
+	}
+
+	return v.err()
+}
+
+// ImportContentPath returns where imported posts are created by default:
+// the content path of synced posts, or else of the posts of positronic-server.
+// It must only be called on a validated config.
+func (c *Config) ImportContentPath() string {
+	if c.NewsBlur.ContentPath != "" {
+		return c.NewsBlur.ContentPath
+	}
+	return c.Server.ContentPath
+}
+
+// ContentPaths returns the distinct paths posts are published under.
+// It must only be called on a validated config.
+func (c *Config) ContentPaths() []string {
+	var paths []string
+	candidates := []string{c.NewsBlur.ContentPath, c.Server.ContentPath}
+	for _, f := range c.Feeds {
+		candidates = append(candidates, f.ContentPath)
+	}
+	if c.bookmarksEnabled() {
+		candidates = append(candidates, c.Bookmarks.ContentPath)
+	}
+	if c.HackerNews.User != "" {
+		candidates = append(candidates, c.HackerNews.ContentPath)
+	}
+	if c.Lobsters.Session != "" {
+		candidates = append(candidates, c.Lobsters.ContentPath)
+	}
+	if c.mastodonEnabled() {
+		candidates = append(candidates, c.Mastodon.ContentPath)
+	}
+	for _, p := range candidates {
+		if p != "" && !slices.Contains(paths, p) {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+func (g GitHub) ownerRepo() (string, string, error) {
+	if split := strings.Split(g.Repo, "/"); len(split) == 2 && split[0] != "" && split[1] != "" {
+		return split[0], split[1], nil
+	}
+	return "", "", fmt.Errorf("malformed %q - expected format to be owner/repo", g.Repo)
+}
+
+// NewsBlurEnabled reports whether positronic-sync syncs NewsBlur shared stories.
+// NewsBlur is optional when other sources are configured.
+func (c *Config) NewsBlurEnabled() bool {
+	hasOtherSources := len(c.Feeds) > 0 || c.bookmarksEnabled() || c.HackerNews.User != "" || c.Lobsters.Session != "" ||
+		c.mastodonEnabled()
+	return !hasOtherSources || c.NewsBlur.Username != "" || c.NewsBlur.Password != ""
+}
+
+func (c *Config) bookmarksEnabled() bool {
+	return c.Bookmarks.API != "" || c.Bookmarks.Token != ""
+}
+
+func (c *Config) mastodonEnabled() bool {
+	return c.Mastodon.URL != "" || c.Mastodon.Token != ""
+}
+
+// SyncSources returns the configured sources of positronic-sync other than NewsBlur,
+// which is logged into on every run.
+// Feeds keep the validators of their last fetch, the sources must be reused across runs
+// for feeds to only be downloaded when they changed.
+// It must only be called on a validated config.
+func (c *Config) SyncSources() ([]poster.SourceConfig, error) {
+	var sources []poster.SourceConfig
+	for _, f := range c.Feeds {
+		sources = append(sources, poster.SourceConfig{
+			Name:           "feed " + f.Name,
+			Source:         feed.New(f.URL),
+			ContentPath:    f.ContentPath,
+			CheckpointPath: f.CheckpointPath,
+		})
+	}
+
+	if c.bookmarksEnabled() {
+		var opts []bookmarks.Option
+		if c.Bookmarks.URL != "" {
+			opts = append(opts, bookmarks.WithBaseURL(c.Bookmarks.URL))
+		}
+		client, err := bookmarks.New(bookmarks.API(c.Bookmarks.API), c.Bookmarks.Token, c.Bookmarks.Tag, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating bookmarks client: %w", err)
+		}
+		sources = append(sources, poster.SourceConfig{
+			Name:           c.Bookmarks.API,
+			Source:         client,
+			ContentPath:    c.Bookmarks.ContentPath,
+			CheckpointPath: c.Bookmarks.CheckpointPath,
+		})
+	}
+
+	if c.HackerNews.User != "" {
+		var opts []favorites.Option
+		if c.HackerNews.URL != "" {
+			opts = append(opts, favorites.WithBaseURL(c.HackerNews.URL))
+		}
+		if c.HackerNews.APIURL != "" {
+			opts = append(opts, favorites.WithAPIURL(c.HackerNews.APIURL))
+		}
+		client, err := favorites.NewHackerNews(c.HackerNews.User, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating Hacker News client: %w", err)
+		}
+		sources = append(sources, poster.SourceConfig{
+			Name:           "hackernews",
+			Source:         client,
+			ContentPath:    c.HackerNews.ContentPath,
+			CheckpointPath: c.HackerNews.CheckpointPath,
+		})
+	}
+
+	if c.Lobsters.Session != "" {
+		var opts []favorites.Option
+		if c.Lobsters.URL != "" {
+			opts = append(opts, favorites.WithBaseURL(c.Lobsters.URL))
+		}
+		client, err := favorites.NewLobsters(c.Lobsters.Session, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating Lobsters client: %w", err)
+		}
+		sources = append(sources, poster.SourceConfig{
+			Name:           "lobsters",
+			Source:         client,
+			ContentPath:    c.Lobsters.ContentPath,
+			CheckpointPath: c.Lobsters.CheckpointPath,
+		})
+	}
+
+	if c.mastodonEnabled() {
+		client, err := favorites.NewMastodon(favorites.MastodonList(c.Mastodon.List), c.Mastodon.Token, favorites.WithBaseURL(c.Mastodon.URL))
+		if err != nil {
+			return nil, fmt.Errorf("creating Mastodon client: %w", err)
+		}
+		sources = append(sources, poster.SourceConfig{
+			Name:           "mastodon " + c.Mastodon.List,
+			Source:         client,
+			ContentPath:    c.Mastodon.ContentPath,
+			CheckpointPath: c.Mastodon.CheckpointPath,
+		})
+	}
+	return sources, nil
+}
+
+// SyncDuplicates returns the duplicates policy of positronic-sync.
+// It must only be called on a validated config.
+func (c *Config) SyncDuplicates() dedup.Policy {
+	p, _ := dedup.ParsePolicy(c.Sync.Duplicates)
+	return p
+}
+
+// SyncSchedule returns the schedule of positronic-sync, nil when it runs once.
+// It must only be called on a validated config.
+func (c *Config) SyncSchedule() scheduler.Schedule {
+	if c.Sync.Schedule == "" {
+		return nil
+	}
+	s, _ := scheduler.Parse(c.Sync.Schedule)
+	return s
+}
+
+// SyncJitter returns the jitter of the scheduled runs of positronic-sync.
+// It must only be called on a validated config.
+func (c *Config) SyncJitter() time.Duration {
+	d, _ := time.ParseDuration(c.Sync.Jitter)
+	return d
+}
+
+// ServerDuplicates returns the duplicates policy of positronic-server.
+// It must only be called on a validated config.
+func (c *Config) ServerDuplicates() dedup.Policy {
+	p, _ := dedup.ParsePolicy(c.Server.Duplicates)
+	return p
+}
+
+// Canonicalizer returns the canonicalizer of post links, nil when disabled.
+func (c *Config) Canonicalizer() *canonical.Canonicalizer {
+	if c.Canonical.Disabled {
+		return nil
+	}
+	var opts []canonical.Option
+	if len(c.Canonical.StripParams) > 0 {
+		opts = append(opts, canonical.WithRules(canonical.Rules(c.Canonical.StripParams)))
+	}
+	if len(c.Canonical.Redirectors) > 0 {
+		opts = append(opts, canonical.WithRedirectors(c.Canonical.Redirectors...))
+	}
+	if c.Canonical.ResolveRedirects {
+		opts = append(opts, canonical.WithResolver(canonical.HTTPResolver{}))
+	}
+	if c.Canonical.LinkCanonical {
+		opts = append(opts, canonical.WithLinkCanonical(nil))
+	}
+	return canonical.New(opts...)
+}
+
+// Previewer returns the fetcher of link previews, nil when disabled.
+// It must only be called on a validated config.
+func (c *Config) Previewer() *linkpreview.Fetcher {
+	if !c.Preview.Enabled {
+		return nil
+	}
+	var opts []linkpreview.Option
+	if c.Preview.Timeout != "" {
+		d, _ := time.ParseDuration(c.Preview.Timeout)
+		opts = append(opts, linkpreview.WithTimeout(d))
+	}
+	if c.Preview.CacheTTL != "" {
+		d, _ := time.ParseDuration(c.Preview.CacheTTL)
+		opts = append(opts, linkpreview.WithCache(linkpreview.NewMemoryCache(d)))
+	}
+	return linkpreview.New(opts...)
+}
+
+// Archiver returns the archiver of the pages posts link to, nil when disabled.
+// It must only be called on a validated config.
+func (c *Config) Archiver() *archive.Archiver {
+	if !c.Archive.Wayback && c.Archive.CopyPath == "" {
+		return nil
+	}
+	var opts []archive.Option
+	if c.Archive.Wayback {
+		opts = append(opts, archive.WithWayback(c.Archive.WaybackURL))
+	}
+	if c.Archive.CopyPath != "" {
+		opts = append(opts, archive.WithCopy(c.Archive.CopyPath))
+	}
+	if c.Archive.Timeout != "" {
+		d, _ := time.ParseDuration(c.Archive.Timeout)
+		opts = append(opts, archive.WithTimeout(d))
+	}
+	return archive.New(opts...)
+}
+
+// CommitIdentity returns who commits are attributed to.
+// It must only be called on a validated config.
+func (c *Config) CommitIdentity() backend.Identity {
+	id := backend.Identity{
+		Author:    backend.Signature{Name: c.Identity.AuthorName, Email: c.Identity.AuthorEmail},
+		Committer: backend.Signature{Name: c.Identity.CommitterName, Email: c.Identity.CommitterEmail},
+	}
+	for _, s := range c.Identity.Trailers {
+		t, err := backend.ParseTrailer(s)
+		if err != nil {
+			continue
+		}
+		id.Trailers = append(id.Trailers, t)
+	}
+	return id
+}
+
+// DiscordIdentity returns the identity of the Discord user with userID.
+// Mapped users author the commits while the configured identity commits them.
+func (c *Config) DiscordIdentity(userID string) backend.Identity {
+	id := c.CommitIdentity()
+	p, ok := c.Server.Discord.Identities[userID]
+	if !ok {
+		return id
+	}
+	id.Committer = id.EffectiveCommitter()
+	id.Author = backend.Signature{Name: p.Name, Email: p.Email}
+	return id
+}
+
+// NewsBlurOptions returns the options of the NewsBlur client.
+func (c *Config) NewsBlurOptions() []newsblur.Option {
+	var opts []newsblur.Option
+	if c.NewsBlur.URL != "" {
+		opts = append(opts, newsblur.WithBaseURL(c.NewsBlur.URL))
+	}
+	return opts
+}
+
+// OpenRepository returns the configured local git or GitHub repository.
+func (c *Config) OpenRepository(ctx context.Context) (backend.Repository, error) {
+	if c.Git.Dir != "" {
+		var opts []localgit.Option
+		if c.Git.BaseBranch != "" {
+			opts = append(opts, localgit.WithBaseBranch(c.Git.BaseBranch))
+		}
+		repo, err := localgit.Open(ctx, c.Git.Dir, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("error opening git repository: %w", err)
+		}
+		return repo, nil
+	}
+
+	owner, repo, err := c.GitHub.ownerRepo()
+	if err != nil {
+		return nil, err
+	}
+	var opts []github.Option
+	if c.GitHub.BaseBranch != "" {
+		opts = append(opts, github.WithBaseBranch(c.GitHub.BaseBranch))
+	}
+	ghClient, err := github.New(ctx, c.GitHub.Token, owner, repo, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating github client: %w", err)
+	}
+	return ghClient, nil
+}
+
+// LoadTemplate returns the configured post template.
+// A template stored in the blog repository is fetched from repo.
+func (c *Config) LoadTemplate(ctx context.Context, repo backend.Repository) (*template.Template, error) {
+	var (
+		tmpl = template.Default
+		err  error
+	)
+
+	switch {
+	case c.Template.File != "":
+		tmpl, err = template.Load(c.Template.File)
+	case c.Template.RepoFile != "":
+		tmpl, err = template.Fetch(ctx, repo, c.Template.RepoFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Template.Generator != "" {
+		g, err := template.LookupGenerator(c.Template.Generator)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err = tmpl.WithGenerator(g)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Template.FrontMatter != "" {
+		f, err := template.ParseFormat(c.Template.FrontMatter)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err = tmpl.WithFormat(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tmpl, nil
+}
@@ -0,0 +1,400 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"gotest.tools/v3/assert"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	assert.NilError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func Test_Load_TOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[github]
+repo = "owner/blog"
+token = "file-token"
+
+[newsblur]
+username = "user"
+password = "pass"
+checkpoint_path = "content/links/checkpoint"
+
+[template]
+generator = "jekyll"
+
+[sync]
+batch = true
+
+[identity]
+author_name = "Blog Bot"
+author_email = "bot@example.com"
+trailers = ["Curated-by: positronic-blogger"]
+`)
+	t.Setenv("POSITRONIC_GITHUB_TOKEN", "env-token")
+
+	cfg, err := Load(path)
+	assert.NilError(t, err)
+	assert.NilError(t, cfg.ValidateSync())
+
+	assert.Equal(t, cfg.GitHub.Token, "env-token")
+	assert.Equal(t, cfg.NewsBlur.ContentPath, "_posts")
+	assert.Equal(t, cfg.Sync.Batch, true)
+	assert.DeepEqual(t, cfg.CommitIdentity(), backend.Identity{
+		Author:   backend.Signature{Name: "Blog Bot", Email: "bot@example.com"},
+		Trailers: []backend.Trailer{{Key: "Curated-by", Value: "positronic-blogger"}},
+	})
+}
+
+func Test_Load_YAML(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+git:
+  dir: /srv/blog.git
+server:
+  discord:
+    token: discord-token
+    app_id: "1234"
+    allowed_users: ["42"]
+    identities:
+      "42":
+        name: Curator
+        email: curator@example.com
+identity:
+  committer_name: Blog Bot
+  committer_email: bot@example.com
+`)
+	t.Setenv("POSITRONIC_DISCORD_ALLOWED_USERS", "42, 43")
+
+	cfg, err := Load(path)
+	assert.NilError(t, err)
+	assert.NilError(t, cfg.ValidateServer())
+
+	assert.DeepEqual(t, cfg.Server.Discord.AllowedUsers, []string{"42", "43"})
+	assert.Equal(t, cfg.Server.ContentPath, "content/links")
+
+	assert.DeepEqual(t, cfg.DiscordIdentity("42"), backend.Identity{
+		Author:    backend.Signature{Name: "Curator", Email: "curator@example.com"},
+		Committer: backend.Signature{Name: "Blog Bot", Email: "bot@example.com"},
+	})
+	assert.DeepEqual(t, cfg.DiscordIdentity("43"), backend.Identity{
+		Committer: backend.Signature{Name: "Blog Bot", Email: "bot@example.com"},
+	})
+}
+
+func Test_ValidateSync_Feeds(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[git]
+dir = "/blog"
+
+[template]
+generator = "hugo"
+
+[[feeds]]
+url = "https://reader.example/starred.rss"
+checkpoint_path = "content/links/starred-checkpoint"
+
+[[feeds]]
+name = "linkblog"
+url = "/var/lib/feeds/linkblog.json"
+content_path = "content/elsewhere"
+checkpoint_path = "content/elsewhere/checkpoint"
+`)
+	cfg, err := Load(path)
+	assert.NilError(t, err)
+	assert.NilError(t, cfg.ValidateSync())
+
+	assert.Assert(t, !cfg.NewsBlurEnabled())
+	sources, err := cfg.SyncSources()
+	assert.NilError(t, err)
+	assert.Equal(t, len(sources), 2)
+	assert.Equal(t, sources[0].Name, "feed reader.example")
+	assert.Equal(t, sources[0].ContentPath, "content/links")
+	assert.Equal(t, sources[1].Name, "feed linkblog")
+	assert.Equal(t, sources[1].CheckpointPath, "content/elsewhere/checkpoint")
+
+	cfg = &Config{
+		Git:      Git{Dir: "/blog"},
+		NewsBlur: NewsBlur{Username: "user", Password: "pass", ContentPath: "_posts", CheckpointPath: "_posts/checkpoint"},
+		Feeds: []Feed{
+			{URL: "ftp://example.com/feed", CheckpointPath: "_posts/checkpoint"},
+			{CheckpointPath: "_posts/feed-checkpoint"},
+		},
+	}
+	err = cfg.ValidateSync()
+	for _, msg := range []string{
+		`feeds[0].url: unsupported scheme "ftp"`,
+		"feeds[0].checkpoint_path: is already the checkpoint of newsblur",
+		"feeds[1].url: is required",
+	} {
+		assert.ErrorContains(t, err, msg)
+	}
+}
+
+func Test_ValidateSync_Bookmarks(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+git:
+  dir: /blog
+newsblur:
+  content_path: content/links
+bookmarks:
+  api: linkding
+  url: https://links.example
+  tag: blog
+  checkpoint_path: content/links/linkding-checkpoint
+`)
+	t.Setenv("POSITRONIC_BOOKMARKS_TOKEN", "secret")
+
+	cfg, err := Load(path)
+	assert.NilError(t, err)
+	assert.NilError(t, cfg.ValidateSync())
+	assert.Assert(t, !cfg.NewsBlurEnabled())
+
+	sources, err := cfg.SyncSources()
+	assert.NilError(t, err)
+	assert.Equal(t, len(sources), 1)
+	assert.Equal(t, sources[0].Name, "linkding")
+	assert.Equal(t, sources[0].ContentPath, "content/links")
+
+	cfg = &Config{
+		Git:       Git{Dir: "/blog"},
+		Feeds:     []Feed{{URL: "https://example.com/feed", ContentPath: "_posts", CheckpointPath: "_posts/checkpoint"}},
+		Bookmarks: Bookmarks{API: "linkding", Token: "secret", CheckpointPath: "_posts/checkpoint"},
+	}
+	err = cfg.ValidateSync()
+	for _, msg := range []string{
+		"bookmarks.url (POSITRONIC_BOOKMARKS_URL): is required for linkding",
+		"bookmarks.tag (POSITRONIC_BOOKMARKS_TAG): is required",
+		"bookmarks.checkpoint_path (POSITRONIC_BOOKMARKS_CHECKPOINT_PATH): is already the checkpoint of feeds[0]",
+	} {
+		assert.ErrorContains(t, err, msg)
+	}
+}
+
+func Test_ValidateSync_Favorites(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[git]
+dir = "/blog"
+
+[newsblur]
+content_path = "content/links"
+
+[hackernews]
+user = "pg"
+api_url = "http://localhost:8080"
+checkpoint_path = "content/links/hackernews-checkpoint"
+
+[lobsters]
+content_path = "content/lobsters"
+checkpoint_path = "content/lobsters/checkpoint"
+`)
+	t.Setenv("POSITRONIC_LOBSTERS_SESSION", "session")
+
+	cfg, err := Load(path)
+	assert.NilError(t, err)
+	assert.NilError(t, cfg.ValidateSync())
+	assert.Assert(t, !cfg.NewsBlurEnabled())
+
+	sources, err := cfg.SyncSources()
+	assert.NilError(t, err)
+	assert.Equal(t, len(sources), 2)
+	assert.Equal(t, sources[0].Name, "hackernews")
+	assert.Equal(t, sources[0].ContentPath, "content/links")
+	assert.Equal(t, sources[1].Name, "lobsters")
+	assert.Equal(t, sources[1].ContentPath, "content/lobsters")
+
+	cfg = &Config{
+		Git:        Git{Dir: "/blog"},
+		HackerNews: HackerNews{User: "pg", ContentPath: "_posts", CheckpointPath: "_posts/checkpoint"},
+		Lobsters:   Lobsters{Session: "session", CheckpointPath: "_posts/checkpoint"},
+	}
+	err = cfg.ValidateSync()
+	for _, msg := range []string{
+		"lobsters.content_path (POSITRONIC_LOBSTERS_CONTENT_PATH): is required",
+		"lobsters.checkpoint_path (POSITRONIC_LOBSTERS_CHECKPOINT_PATH): is already the checkpoint of hackernews",
+	} {
+		assert.ErrorContains(t, err, msg)
+	}
+}
+
+func Test_ValidateSync_Mastodon(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+git:
+  dir: /blog
+newsblur:
+  content_path: content/links
+mastodon:
+  url: https://example.social
+  checkpoint_path: content/links/mastodon-checkpoint
+`)
+	t.Setenv("POSITRONIC_MASTODON_TOKEN", "token")
+
+	cfg, err := Load(path)
+	assert.NilError(t, err)
+	assert.NilError(t, cfg.ValidateSync())
+	assert.Assert(t, !cfg.NewsBlurEnabled())
+	assert.Equal(t, cfg.Mastodon.List, "bookmarks")
+
+	sources, err := cfg.SyncSources()
+	assert.NilError(t, err)
+	assert.Equal(t, len(sources), 1)
+	assert.Equal(t, sources[0].Name, "mastodon bookmarks")
+	assert.Equal(t, sources[0].ContentPath, "content/links")
+
+	cfg = &Config{
+		Git:      Git{Dir: "/blog"},
+		Mastodon: Mastodon{Token: "token", List: "boosts", ContentPath: "_posts", CheckpointPath: "_posts/checkpoint"},
+	}
+	err = cfg.ValidateSync()
+	for _, msg := range []string{
+		"mastodon.url (POSITRONIC_MASTODON_URL): is required",
+		`mastodon.list (POSITRONIC_MASTODON_LIST): unknown Mastodon list "boosts"`,
+	} {
+		assert.ErrorContains(t, err, msg)
+	}
+}
+
+func Test_ValidateLinkCheck(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[git]
+dir = "/blog"
+
+[template]
+generator = "jekyll"
+
+[server]
+content_path = "_links"
+`)
+	cfg, err := Load(path)
+	assert.NilError(t, err)
+	assert.NilError(t, cfg.ValidateLinkCheck())
+	assert.DeepEqual(t, cfg.ContentPaths(), []string{"_posts", "_links"})
+
+	cfg = &Config{Git: Git{Dir: "/blog"}}
+	assert.NilError(t, cfg.ValidateLinkCheck())
+	assert.DeepEqual(t, cfg.ContentPaths(), []string{"content/links"})
+}
+
+func Test_ValidateImport(t *testing.T) {
+	cfg := &Config{Git: Git{Dir: "/blog"}, Template: Template{Generator: "jekyll"}}
+	assert.NilError(t, cfg.ValidateImport())
+	assert.Equal(t, cfg.ImportContentPath(), "_posts")
+
+	cfg = &Config{Git: Git{Dir: "/blog"}}
+	assert.NilError(t, cfg.ValidateImport())
+	assert.Equal(t, cfg.ImportContentPath(), "content/links")
+
+	cfg = &Config{GitHub: GitHub{Repo: "owner/blog"}}
+	assert.ErrorContains(t, cfg.ValidateImport(), "github.token")
+}
+
+func Test_Load_Errors(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[github]
+repo = "owner/blog"
+tokn = "typo"
+`)
+	cfg, err := Load(path)
+	assert.NilError(t, err)
+	err = cfg.ValidateSync()
+	assert.ErrorContains(t, err, "github.tokn: unknown field")
+	assert.ErrorContains(t, err, "github.token (POSITRONIC_GITHUB_TOKEN): is required")
+
+	path = writeFile(t, "config.yaml", `
+github:
+  repo: "not-a-repo"
+template:
+  front_matter: xml
+identity:
+  author_name: Someone
+  trailers: ["not a trailer"]
+sync:
+  schedule: every day
+  jitter: soon
+preview:
+  enabled: true
+  timeout: -5s
+archive:
+  wayback_url: http://localhost:8080
+`)
+	t.Setenv("POSITRONIC_SKIP_MERGE", "maybe")
+	t.Setenv("POSITRONIC_PREVIEW", "often")
+	cfg, err = Load(path)
+	assert.NilError(t, err)
+
+	err = cfg.ValidateSync()
+	for _, msg := range []string{
+		"sync.skip_merge (POSITRONIC_SKIP_MERGE): malformed boolean \"maybe\"",
+		"preview.enabled (POSITRONIC_PREVIEW): malformed boolean \"often\"",
+		"github.repo (POSITRONIC_GITHUB_REPO): malformed",
+		"github.token (POSITRONIC_GITHUB_TOKEN): is required",
+		"template.front_matter (POSITRONIC_FRONT_MATTER): unknown front matter format",
+		"newsblur.username (POSITRONIC_NEWSBLUR_USERNAME): is required",
+		"identity.author_email (POSITRONIC_AUTHOR_EMAIL): is required when identity.author_name is set",
+		"identity.trailers (POSITRONIC_COMMIT_TRAILERS): malformed trailer",
+		"newsblur.checkpoint_path (POSITRONIC_NEWSBLUR_CHECKPOINT_PATH): is required",
+		"sync.schedule (POSITRONIC_SYNC_SCHEDULE): schedule \"every day\" is neither a duration nor a cron expression",
+		"sync.jitter (POSITRONIC_SYNC_JITTER): malformed duration \"soon\"",
+		"preview.timeout (POSITRONIC_PREVIEW_TIMEOUT): malformed duration \"-5s\"",
+		"archive.wayback_url (POSITRONIC_ARCHIVE_WAYBACK_URL): requires archive.wayback",
+	} {
+		assert.ErrorContains(t, err, msg)
+	}
+
+	cfg, err = Load(writeFile(t, "config.ini", ""))
+	assert.NilError(t, err)
+	assert.ErrorContains(t, cfg.ValidateSync(), "unknown config file extension")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.toml"))
+	assert.ErrorContains(t, err, "reading config file")
+}
+
+func Test_ValidateServer_Defaults(t *testing.T) {
+	t.Setenv("POSITRONIC_GITHUB_REPO", "owner/blog")
+	t.Setenv("POSITRONIC_GITHUB_TOKEN", "token")
+	t.Setenv("POSITRONIC_DISCORD_TOKEN", "discord-token")
+	t.Setenv("POSITRONIC_DISCORD_APPID", "1234")
+
+	cfg, err := Load("")
+	assert.NilError(t, err)
+	assert.NilError(t, cfg.ValidateServer())
+	assert.DeepEqual(t, cfg.Server.Discord.AllowedUsers, []string{defaultDiscordAllowedUser})
+}
+
+func Test_Validate_ArchiveCopyPath(t *testing.T) {
+	t.Setenv("POSITRONIC_GIT_DIR", "/srv/blog.git")
+	t.Setenv("POSITRONIC_NEWSBLUR_USERNAME", "user")
+	t.Setenv("POSITRONIC_NEWSBLUR_PASSWORD", "pass")
+	t.Setenv("POSITRONIC_NEWSBLUR_CONTENT_PATH", "content/links")
+	t.Setenv("POSITRONIC_NEWSBLUR_CHECKPOINT_PATH", "content/links/checkpoint")
+
+	for _, tc := range []struct {
+		copyPath string
+		err      string
+	}{
+		{copyPath: "content/mirror"},
+		{copyPath: "content/links-mirror"},
+		{copyPath: "content/links/", err: "must not overlap the content path content/links"},
+		{copyPath: "content/links/mirror", err: "must not overlap the content path content/links"},
+		{copyPath: "./content", err: "must not overlap the content path content/links"},
+		{copyPath: "/", err: "must not be the root of the repository"},
+	} {
+		t.Run(tc.copyPath, func(t *testing.T) {
+			t.Setenv("POSITRONIC_ARCHIVE_COPY_PATH", tc.copyPath)
+			cfg, err := Load("")
+			assert.NilError(t, err)
+			err = cfg.ValidateSync()
+			if tc.err == "" {
+				assert.NilError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, "archive.copy_path (POSITRONIC_ARCHIVE_COPY_PATH): "+tc.err)
+		})
+	}
+}
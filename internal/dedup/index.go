@@ -0,0 +1,135 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"github.com/seriousben/positronic-blogger/internal/canonical"
+	"github.com/seriousben/positronic-blogger/internal/template"
+)
+
+// Policy is what to do with an item whose URL was already posted.
+type Policy string
+
+const (
+	// PolicyOff disables deduplication.
+	PolicyOff Policy = "off"
+	// PolicySkip ignores items already posted.
+	PolicySkip Policy = "skip"
+	// PolicyUpdate rewrites the existing post with the new item.
+	PolicyUpdate Policy = "update"
+)
+
+// ParsePolicy returns the policy named s, an empty s disables deduplication.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(strings.ToLower(s)); p {
+	case "", PolicyOff:
+		return PolicyOff, nil
+	case PolicySkip, PolicyUpdate:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown duplicates policy %q, expected one of off, skip or update", s)
+	}
+}
+
+// Entry is an existing post.
+type Entry struct {
+	Path string
+	// SHA is the blob SHA of the post on the base branch,
+	// it is empty for posts created during the current run.
+	SHA string
+}
+
+// Index finds posts by URL.
+type Index struct {
+	entries map[string]Entry
+}
+
+func New() *Index {
+	return &Index{entries: map[string]Entry{}}
+}
+
+// Load indexes the posts under dir by the originalUrl of their front matter.
+func Load(ctx context.Context, repo backend.Repository, dir string) (*Index, error) {
+	files, err := repo.ListFiles(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing posts: %w", err)
+	}
+
+	idx := New()
+	idx.AddFiles(files)
+
+	log.Printf("dedup: indexed %d posts under %s", len(idx.entries), dir)
+	return idx, nil
+}
+
+// LoadAll indexes the posts under all dirs, such as the content paths of every source.
+func LoadAll(ctx context.Context, repo backend.Repository, dirs []string) (*Index, error) {
+	idx := New()
+	for _, dir := range dirs {
+		files, err := repo.ListFiles(ctx, dir)
+		if err != nil {
+			return nil, fmt.Errorf("listing posts: %w", err)
+		}
+		idx.AddFiles(files)
+	}
+
+	log.Printf("dedup: indexed %d posts under %s", len(idx.entries), strings.Join(dirs, ", "))
+	return idx, nil
+}
+
+// AddFiles indexes the posts among files by the originalUrl of their front matter.
+func (i *Index) AddFiles(files []backend.File) {
+	for _, f := range files {
+		switch path.Ext(f.Path) {
+		case ".md", ".markdown", ".html":
+		default:
+			continue
+		}
+		_, fm, err := template.ParseFrontMatter(f.Content)
+		if err != nil {
+			log.Printf("dedup: skipping %s: %v", f.Path, err)
+			continue
+		}
+		u, ok := fm["originalUrl"].(string)
+		if !ok || u == "" {
+			continue
+		}
+		i.Add(u, Entry{Path: f.Path, SHA: f.SHA})
+	}
+}
+
+func (i *Index) Lookup(rawURL string) (Entry, bool) {
+	e, ok := i.entries[Key(rawURL)]
+	return e, ok
+}
+
+func (i *Index) Add(rawURL string, e Entry) {
+	i.entries[Key(rawURL)] = e
+}
+
+// Key returns the comparison key of a URL.
+// Scheme, host case, fragment, trailing slash and tracking parameter
+// differences are ignored.
+func Key(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	canonical.DefaultRules.Strip(u)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	key := host + strings.TrimRight(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
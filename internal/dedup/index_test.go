@@ -0,0 +1,50 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"gotest.tools/v3/assert"
+)
+
+type fakeRepository struct {
+	backend.Repository
+	files []backend.File
+}
+
+func (r *fakeRepository) ListFiles(ctx context.Context, dir string) ([]backend.File, error) {
+	return r.files, nil
+}
+
+func Test_Index(t *testing.T) {
+	repo := &fakeRepository{files: []backend.File{
+		{Path: "content/links/a.md", SHA: "a", Content: "+++\noriginalUrl = \"https://Example.com/a/#top\"\n+++\n"},
+		{Path: "content/links/b.md", SHA: "b", Content: "---\noriginalUrl: \"http://example.com/b?id=1\"\n---\n"},
+		{Path: "content/links/c.md", SHA: "c", Content: "no front matter"},
+		{Path: "content/links/checkpoint", SHA: "d", Content: `"2022-01-01T00:00:00Z"`},
+	}}
+
+	idx, err := Load(context.Background(), repo, "content/links")
+	assert.NilError(t, err)
+
+	e, ok := idx.Lookup("https://example.com/a")
+	assert.Assert(t, ok)
+	assert.Equal(t, e, Entry{Path: "content/links/a.md", SHA: "a"})
+
+	_, ok = idx.Lookup("https://example.com/b/?id=1")
+	assert.Assert(t, ok)
+
+	_, ok = idx.Lookup("https://example.com/b?id=2")
+	assert.Assert(t, !ok)
+
+	idx.Add("https://example.com/new", Entry{Path: "content/links/new.md"})
+	_, ok = idx.Lookup("https://example.com/new/")
+	assert.Assert(t, ok)
+
+	p, err := ParsePolicy("")
+	assert.NilError(t, err)
+	assert.Equal(t, p, PolicyOff)
+	_, err = ParsePolicy("merge")
+	assert.ErrorContains(t, err, "unknown duplicates policy")
+}
@@ -0,0 +1,147 @@
+package dryrun
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the edit script turning a into b, using their longest common subsequence.
+func diffLines(a, b []string) []op {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{opEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{opDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, op{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{opDelete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{opInsert, b[j]})
+	}
+	return ops
+}
+
+// UnifiedDiff returns the unified diff between the old and new content of a file,
+// or an empty string when they are equal.
+func UnifiedDiff(oldName, newName, oldContent, newContent string) string {
+	ops := diffLines(splitLines(oldContent), splitLines(newContent))
+
+	var changed []int
+	for i, o := range ops {
+		if o.kind != opEqual {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "--- %s\n+++ %s\n", oldName, newName)
+
+	// Group changes closer than twice the context into hunks.
+	for h := 0; h < len(changed); {
+		start := max(changed[h]-diffContext, 0)
+		end := changed[h]
+		for h < len(changed) && changed[h] <= end+2*diffContext {
+			end = changed[h]
+			h++
+		}
+		end = min(end+diffContext, len(ops)-1)
+
+		// Line numbers are 1-based, the start of an empty range is the line before it.
+		oldStart, newStart := 1, 1
+		for _, o := range ops[:start] {
+			if o.kind != opInsert {
+				oldStart++
+			}
+			if o.kind != opDelete {
+				newStart++
+			}
+		}
+		oldLines, newLines := 0, 0
+		for _, o := range ops[start : end+1] {
+			if o.kind != opInsert {
+				oldLines++
+			}
+			if o.kind != opDelete {
+				newLines++
+			}
+		}
+		if oldLines == 0 {
+			oldStart--
+		}
+		if newLines == 0 {
+			newStart--
+		}
+
+		fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(oldStart, oldLines), hunkRange(newStart, newLines))
+		for _, o := range ops[start : end+1] {
+			b.WriteByte(byte(o.kind))
+			b.WriteString(o.line)
+			if !strings.HasSuffix(o.line, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
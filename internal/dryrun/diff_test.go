@@ -0,0 +1,38 @@
+package dryrun
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func Test_UnifiedDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn"
+
+	assert.Equal(t, UnifiedDiff("a/f", "b/f", old, new), `--- a/f
++++ b/f
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -11,3 +11,4 @@
+ k
+ l
+ m
++n
+\ No newline at end of file
+`)
+
+	assert.Equal(t, UnifiedDiff("/dev/null", "b/f", "", "x\ny\n"), `--- /dev/null
++++ b/f
+@@ -0,0 +1,2 @@
++x
++y
+`)
+
+	assert.Equal(t, UnifiedDiff("a/f", "b/f", old, old), "")
+}
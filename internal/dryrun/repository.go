@@ -0,0 +1,177 @@
+// Package dryrun previews what would be published to a repository without changing it.
+package dryrun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/seriousben/positronic-blogger/internal/backend"
+)
+
+// Change is a file that would be created or updated.
+type Change struct {
+	Branch string
+	Path   string
+	// Old is the content of the file on the base branch, empty for new files.
+	Old     string
+	New     string
+	Created bool
+}
+
+// Diff returns the unified diff of the change.
+func (c Change) Diff() string {
+	oldName := "a/" + c.Path
+	if c.Created {
+		oldName = "/dev/null"
+	}
+	return UnifiedDiff(oldName, "b/"+c.Path, c.Old, c.New)
+}
+
+// Repository reads from a repository but only records the writes made to it.
+type Repository struct {
+	backend.Repository
+
+	out io.Writer
+	dir string
+
+	mu      sync.Mutex
+	changes []Change
+}
+
+// Option configures a Repository.
+type Option func(*Repository)
+
+// WithOutput prints the diff of the changes of each branch to w when it is published.
+func WithOutput(w io.Writer) Option {
+	return func(r *Repository) {
+		r.out = w
+	}
+}
+
+// WithDir writes the files of each branch under dir when it is published.
+func WithDir(dir string) Option {
+	return func(r *Repository) {
+		r.dir = dir
+	}
+}
+
+func New(repo backend.Repository, opts ...Option) *Repository {
+	r := &Repository{Repository: repo}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Changes returns the changes of every published branch.
+func (r *Repository) Changes() []Change {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Change(nil), r.changes...)
+}
+
+// OpenBranch returns a branch recording its changes, nothing is created in the repository.
+func (r *Repository) OpenBranch(ctx context.Context, branchName string, id backend.Identity) (backend.Branch, error) {
+	return &Branch{
+		repo:  r,
+		name:  branchName,
+		files: map[string]*Change{},
+	}, nil
+}
+
+// Branch records the files written to a branch.
+type Branch struct {
+	repo  *Repository
+	name  string
+	files map[string]*Change
+}
+
+var (
+	_ backend.Repository = (*Repository)(nil)
+	_ backend.Branch     = (*Branch)(nil)
+)
+
+func (b *Branch) write(ctx context.Context, path, content string) error {
+	if c, ok := b.files[path]; ok {
+		c.New = content
+		return nil
+	}
+	old, _, err := b.repo.GetContent(ctx, path)
+	if err != nil && !errors.Is(err, backend.ErrFileNotFound) {
+		return err
+	}
+	b.files[path] = &Change{
+		Branch:  b.name,
+		Path:    path,
+		Old:     old,
+		New:     content,
+		Created: err != nil,
+	}
+	return nil
+}
+
+func (b *Branch) CreateFile(ctx context.Context, commitMsg, path, content string) error {
+	return b.write(ctx, path, content)
+}
+
+func (b *Branch) UpdateFile(ctx context.Context, commitMsg, path, sha, content string) error {
+	return b.write(ctx, path, content)
+}
+
+func (b *Branch) CommitFiles(ctx context.Context, commitMsg string, files []backend.File) error {
+	for _, f := range files {
+		if err := b.write(ctx, f.Path, f.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Publish reports the changes of the branch instead of publishing it.
+func (b *Branch) Publish(ctx context.Context, title, body string, merge bool) error {
+	paths := make([]string, 0, len(b.files))
+	for p := range b.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	r := b.repo
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.out != nil {
+		fmt.Fprintf(r.out, "dry run: branch %s would publish %d files (%s)\n", b.name, len(paths), title)
+	}
+	for _, p := range paths {
+		c := *b.files[p]
+		r.changes = append(r.changes, c)
+
+		if r.out != nil {
+			if d := c.Diff(); d != "" {
+				fmt.Fprint(r.out, d)
+			} else {
+				fmt.Fprintf(r.out, "%s is unchanged\n", c.Path)
+			}
+		}
+		if r.dir != "" {
+			name := filepath.Join(r.dir, filepath.FromSlash(c.Path))
+			if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+				return fmt.Errorf("creating preview directory: %w", err)
+			}
+			if err := os.WriteFile(name, []byte(c.New), 0o644); err != nil {
+				return fmt.Errorf("writing preview file: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Branch) DeleteBranch(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,63 @@
+package dryrun
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"gotest.tools/v3/assert"
+)
+
+type fakeRepository struct {
+	backend.Repository
+	files map[string]string
+}
+
+func (r *fakeRepository) GetContent(ctx context.Context, path string) (string, string, error) {
+	content, ok := r.files[path]
+	if !ok {
+		return "", "", backend.ErrFileNotFound
+	}
+	return content, "sha", nil
+}
+
+func Test_Repository(t *testing.T) {
+	ctx := context.Background()
+	out := new(bytes.Buffer)
+	dir := t.TempDir()
+	repo := New(&fakeRepository{files: map[string]string{
+		"content/links/checkpoint": "\"2022-01-01T00:00:00Z\"\n",
+	}}, WithOutput(out), WithDir(dir))
+
+	brc, err := repo.OpenBranch(ctx, "preview", backend.Identity{})
+	assert.NilError(t, err)
+	assert.NilError(t, brc.CreateFile(ctx, "new", "content/links/post.md", "post\n"))
+	assert.NilError(t, brc.CommitFiles(ctx, "batch", []backend.File{
+		{Path: "content/links/checkpoint", Content: "\"2022-02-01T00:00:00Z\"\n"},
+	}))
+	assert.NilError(t, brc.Publish(ctx, "title", "body", true))
+
+	assert.DeepEqual(t, repo.Changes(), []Change{
+		{Branch: "preview", Path: "content/links/checkpoint", Old: "\"2022-01-01T00:00:00Z\"\n", New: "\"2022-02-01T00:00:00Z\"\n"},
+		{Branch: "preview", Path: "content/links/post.md", New: "post\n", Created: true},
+	})
+
+	assert.Equal(t, out.String(), `dry run: branch preview would publish 2 files (title)
+--- a/content/links/checkpoint
++++ b/content/links/checkpoint
+@@ -1 +1 @@
+-"2022-01-01T00:00:00Z"
++"2022-02-01T00:00:00Z"
+--- /dev/null
++++ b/content/links/post.md
+@@ -0,0 +1 @@
++post
+`)
+
+	b, err := os.ReadFile(filepath.Join(dir, "content", "links", "post.md"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), "post\n")
+}
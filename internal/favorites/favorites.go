@@ -0,0 +1,271 @@
+// Package favorites syncs the stories a user favorited on Hacker News, or saved on Lobsters,
+// and the statuses a Mastodon user bookmarked or favourited.
+//
+// Favorites are listed by when they were favorited, which none of the sites tells,
+// so stories and statuses are dated by when they were posted. The IDs of the newest favorites
+// are stored with the checkpoint, and paging stops at the first of them still listed:
+// the favorites listed before it are new, however long after they were posted they were favorited.
+// When none of them is listed anymore, the whole list is read and only the stories newer than
+// the checkpoint are new. Until IDs are stored, such as on the first run, paging stops at the first
+// page without stories newer than the checkpoint.
+package favorites
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/ratelimit"
+	"github.com/seriousben/positronic-blogger/internal/source"
+)
+
+const (
+	// DefaultHackerNewsURL is the URL of the Hacker News site, where favorites are listed.
+	DefaultHackerNewsURL = "https://news.ycombinator.com"
+	// DefaultHackerNewsAPIURL is the URL of the Hacker News API, where stories are read.
+	DefaultHackerNewsAPIURL = "https://hacker-news.firebaseio.com"
+	// DefaultLobstersURL is the URL of Lobsters.
+	DefaultLobstersURL = "https://lobste.rs"
+
+	apiRequestRateLimit = 720 * time.Millisecond
+)
+
+type options struct {
+	baseURL   string
+	apiURL    string
+	client    *http.Client
+	rateLimit time.Duration
+}
+
+// Option configures a client.
+type Option func(*options)
+
+// WithBaseURL replaces the URL of the site, such as a local stub.
+// It is the URL of the instance for Mastodon.
+func WithBaseURL(u string) Option {
+	return func(o *options) {
+		o.baseURL = strings.TrimSuffix(u, "/")
+	}
+}
+
+// WithAPIURL replaces the URL of the Hacker News API, it is ignored by Lobsters.
+func WithAPIURL(u string) Option {
+	return func(o *options) {
+		o.apiURL = strings.TrimSuffix(u, "/")
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithRateLimit sets the minimum delay between requests.
+func WithRateLimit(d time.Duration) Option {
+	return func(o *options) {
+		o.rateLimit = d
+	}
+}
+
+func newOptions(baseURL string, opts []Option) *options {
+	o := &options{
+		baseURL:   baseURL,
+		apiURL:    DefaultHackerNewsAPIURL,
+		client:    http.DefaultClient,
+		rateLimit: apiRequestRateLimit,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// get reads the body and header of the response of u once the limiter allows another request.
+func get(ctx context.Context, client *http.Client, lim *ratelimit.Limiter, u string, header http.Header) ([]byte, http.Header, error) {
+	if err := lim.Wait(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating favorites request: %w", err)
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting favorites: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("getting %s: unexpected status %s", req.URL.Path, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading favorites response body: %w", err)
+	}
+	return data, resp.Header, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, lim *ratelimit.Limiter, u string, header http.Header, v any) (http.Header, error) {
+	data, respHeader, err := get(ctx, client, lim, u, header)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("unmarshaling favorites response body: %w", err)
+	}
+	return respHeader, nil
+}
+
+// maxMarkers is the number of newest favorites whose IDs are stored,
+// paging stops at the first of them still listed, the others being unfavorited.
+const maxMarkers = 20
+
+// markers is the state of a client: the IDs of the newest favorites listed by the last iteration,
+// followed by the ones stored by the previous run.
+type markers struct {
+	mu     sync.Mutex
+	stored []string
+	listed []string
+}
+
+type markersState struct {
+	Newest []string `json:"newest"`
+}
+
+// SetState restores the IDs of the newest favorites listed by a previous run.
+func (m *markers) SetState(raw json.RawMessage) error {
+	var st markersState
+	if raw != nil {
+		if err := json.Unmarshal(raw, &st); err != nil {
+			return fmt.Errorf("parsing favorites state: %w", err)
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stored, m.listed = st.Newest, nil
+	return nil
+}
+
+// State returns the IDs of the newest favorites listed by the last iteration and by the previous run.
+func (m *markers) State() (json.RawMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := m.listed
+	if len(ids) == 0 {
+		ids = m.stored
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(markersState{Newest: ids})
+}
+
+// cursor tells the new favorites of an iteration apart.
+type cursor struct {
+	newerThan time.Time
+	stored    map[string]bool
+	listed    []string
+	reached   bool
+}
+
+func (m *markers) cursor(newerThan time.Time) *cursor {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &cursor{newerThan: newerThan, stored: map[string]bool{}}
+	for _, id := range m.stored {
+		c.stored[id] = true
+	}
+	return c
+}
+
+// done records the favorite id, listed next, and reports whether it was listed by the previous run:
+// it and the favorites listed after it are not new.
+func (c *cursor) done(id string) bool {
+	if len(c.listed) < maxMarkers {
+		c.listed = append(c.listed, id)
+	}
+	if c.stored[id] {
+		c.reached = true
+	}
+	return c.reached
+}
+
+// byDate reports whether new favorites are the ones posted after newerThan,
+// when the favorites listed by the previous run are not known.
+func (c *cursor) byDate() bool {
+	return len(c.stored) == 0
+}
+
+// more reports whether to read the next page, if any: by date, when the page had stories
+// newer than newerThan, otherwise until the favorites listed by the previous run.
+func (c *cursor) more(newer bool) bool {
+	if c.byDate() {
+		return newer
+	}
+	return !c.reached
+}
+
+// pageFunc returns the new stories of page n, and whether the next page may have more.
+type pageFunc func(ctx context.Context, n int, cur *cursor) ([]*source.Item, bool, error)
+
+// collect pages through favorites until the page function tells there are no more new stories,
+// and returns the stories newest first. When paging ends without reaching any favorite listed by
+// the previous run, only the stories newer than newerThan are returned.
+func collect(ctx context.Context, m *markers, newerThan time.Time, page pageFunc) (source.Iterator, error) {
+	cur := m.cursor(newerThan)
+	var items []*source.Item
+	for n := 1; ; n++ {
+		stories, more, err := page(ctx, n, cur)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, stories...)
+		if !more {
+			break
+		}
+	}
+
+	if !cur.byDate() && !cur.reached {
+		// The favorites listed by the previous run were all unfavorited since,
+		// the checkpoint tells the new favorites apart instead.
+		items = slices.DeleteFunc(items, func(it *source.Item) bool { return !it.Date.After(newerThan) })
+	}
+
+	m.mu.Lock()
+	m.listed = nil
+	for _, id := range append(cur.listed, m.stored...) {
+		if len(m.listed) < maxMarkers && !slices.Contains(m.listed, id) {
+			m.listed = append(m.listed, id)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Date.After(items[j].Date) })
+	return &iterator{items: items}, nil
+}
+
+type iterator struct {
+	items []*source.Item
+}
+
+func (i *iterator) Next(ctx context.Context) (*source.Item, error) {
+	if len(i.items) == 0 {
+		return nil, io.EOF
+	}
+	it := i.items[0]
+	i.items = i.items[1:]
+	return it, nil
+}
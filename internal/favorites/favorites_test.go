@@ -0,0 +1,368 @@
+package favorites
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/source"
+	"gotest.tools/v3/assert"
+)
+
+func collectItems(t *testing.T, src source.Source, newerThan time.Time) []*source.Item {
+	t.Helper()
+	it, err := src.Iterator(context.Background(), newerThan)
+	assert.NilError(t, err)
+	var items []*source.Item
+	for {
+		item, err := it.Next(context.Background())
+		if err == io.EOF {
+			return items
+		}
+		assert.NilError(t, err)
+		items = append(items, item)
+	}
+}
+
+// hnRow renders a story of a favorites page.
+func hnRow(id int, submitted time.Time) string {
+	return fmt.Sprintf(`
+<tr class="athing submission" id="%d"><td class="title"><span class="titleline"><a href="https://example.com/%d">Story %d</a></span></td></tr>
+<tr><td class="subtext"><span class="subline"><span class="age" title="%s %d"><a href="item?id=%d">1 hour ago</a></span></span></td></tr>`,
+		id, id, id, submitted.UTC().Format("2006-01-02T15:04:05"), submitted.Unix(), id)
+}
+
+func Test_HackerNews(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	submitted := func(id int) time.Time { return start.Add(time.Duration(id) * time.Hour) }
+	pages := map[string][]int{
+		"1": {5, 4, 7},
+		"2": {3, 2},
+		"3": {1},
+		"4": {6},
+	}
+
+	var itemCalls, pageCalls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/favorites":
+			assert.Equal(t, r.URL.Query().Get("id"), "pg")
+			p := r.URL.Query().Get("p")
+			pageCalls = append(pageCalls, p)
+			fmt.Fprint(w, `<html><body><table>`)
+			for _, id := range pages[p] {
+				fmt.Fprint(w, hnRow(id, submitted(id)))
+			}
+			if _, ok := pages[fmt.Sprint(len(pageCalls)+1)]; ok {
+				fmt.Fprintf(w, `<tr><td><a href="favorites?id=pg&amp;p=%d" class="morelink" rel="next">More</a></td></tr>`, len(pageCalls)+1)
+			}
+			fmt.Fprint(w, `</table></body></html>`)
+		case strings.HasPrefix(r.URL.Path, "/v0/item/"):
+			id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v0/item/"), ".json")
+			itemCalls = append(itemCalls, id)
+			n, _ := strconv.Atoi(id)
+			switch n {
+			case 4:
+				// Ask HN stories have no link.
+				fmt.Fprintf(w, `{"id": 4, "type": "story", "title": "Ask HN: Story 4", "time": %d, "by": "someone"}`, submitted(n).Unix())
+			case 7:
+				fmt.Fprintf(w, `{"id": 7, "type": "story", "title": "Dead", "url": "https://example.com/7", "time": %d, "dead": true}`, submitted(n).Unix())
+			default:
+				fmt.Fprintf(w, `{"id": %d, "type": "story", "title": "Story %d", "url": "https://example.com/%d", "time": %d}`, n, n, n, submitted(n).Unix())
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	hn, err := NewHackerNews("pg", WithBaseURL(srv.URL), WithAPIURL(srv.URL+"/"), WithHTTPClient(srv.Client()), WithRateLimit(time.Millisecond))
+	assert.NilError(t, err)
+
+	items := collectItems(t, hn, submitted(2))
+	assert.DeepEqual(t, items, []*source.Item{
+		{
+			ID:            "hn-5",
+			Title:         "Story 5",
+			URL:           "https://example.com/5",
+			Date:          submitted(5),
+			DiscussionURL: srv.URL + "/item?id=5",
+		},
+		{
+			ID:            "hn-4",
+			Title:         "Ask HN: Story 4",
+			URL:           srv.URL + "/item?id=4",
+			Date:          submitted(4),
+			DiscussionURL: srv.URL + "/item?id=4",
+		},
+		{
+			ID:            "hn-3",
+			Title:         "Story 3",
+			URL:           "https://example.com/3",
+			Date:          submitted(3),
+			DiscussionURL: srv.URL + "/item?id=3",
+		},
+	})
+	// Paging stops at the first page without new stories, later pages are not read.
+	// Only the stories submitted after the checkpoint are read from the API.
+	assert.DeepEqual(t, pageCalls, []string{"1", "2", "3"})
+	assert.DeepEqual(t, itemCalls, []string{"5", "4", "7", "3"})
+
+	// Once the favorites listed by a run are stored, paging stops at them, whenever the new favorites were submitted.
+	state, err := hn.State()
+	assert.NilError(t, err)
+	assert.Equal(t, string(state), `{"newest":["5","4","7","3","2","1"]}`)
+	pages["1"] = []int{8, 5, 4, 7}
+	pageCalls, itemCalls = nil, nil
+	hn, err = NewHackerNews("pg", WithBaseURL(srv.URL), WithAPIURL(srv.URL), WithHTTPClient(srv.Client()), WithRateLimit(time.Millisecond))
+	assert.NilError(t, err)
+	assert.NilError(t, hn.SetState(state))
+	items = collectItems(t, hn, submitted(9))
+	assert.Equal(t, len(items), 1)
+	assert.Equal(t, items[0].ID, "hn-8")
+	assert.DeepEqual(t, pageCalls, []string{"1"})
+	assert.DeepEqual(t, itemCalls, []string{"8"})
+	// The stored favorites are kept after the new ones.
+	state, err = hn.State()
+	assert.NilError(t, err)
+	assert.Equal(t, string(state), `{"newest":["8","5","4","7","3","2","1"]}`)
+
+	// Once the stored favorites are all unfavorited, the checkpoint tells the new favorites apart.
+	pageCalls, itemCalls = nil, nil
+	hn, err = NewHackerNews("pg", WithBaseURL(srv.URL), WithAPIURL(srv.URL), WithHTTPClient(srv.Client()), WithRateLimit(time.Millisecond))
+	assert.NilError(t, err)
+	assert.NilError(t, hn.SetState(json.RawMessage(`{"newest":["50","51"]}`)))
+	items = collectItems(t, hn, submitted(5))
+	assert.Equal(t, len(items), 2)
+	assert.Equal(t, items[0].ID, "hn-8")
+	assert.Equal(t, items[1].ID, "hn-6")
+	assert.DeepEqual(t, pageCalls, []string{"1", "2", "3", "4"})
+	state, err = hn.State()
+	assert.NilError(t, err)
+	assert.Equal(t, string(state), `{"newest":["8","5","4","7","3","2","1","6","50","51"]}`)
+
+	_, err = NewHackerNews("")
+	assert.ErrorContains(t, err, "missing Hacker News user")
+}
+
+func Test_parseHNAge(t *testing.T) {
+	assert.Equal(t, parseHNAge("2024-03-05T10:00:00 1709632800"), time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC))
+	assert.Equal(t, parseHNAge("2024-03-05T10:00:00"), time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC))
+	assert.Assert(t, parseHNAge("").IsZero())
+}
+
+func Test_Lobsters(t *testing.T) {
+	var (
+		paths  []string
+		newest string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("lobster_trap")
+		if err != nil || c.Value != "session" {
+			http.Error(w, "login required", http.StatusForbidden)
+			return
+		}
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/saved.json":
+			fmt.Fprintf(w, `[%[1]s
+				{"short_id": "abc", "short_id_url": "%[2]s/s/abc", "created_at": "2024-03-05T04:00:00.000-06:00",
+				 "title": "A story", "url": "https://example.com/story", "comments_url": "%[2]s/s/abc/a_story",
+				 "submitter_user": "alice", "tags": ["go", "practices"]},
+				{"short_id": "def", "short_id_url": "%[2]s/s/def", "created_at": "2024-03-04T10:00:00.000-06:00",
+				 "title": "Ask: a question", "url": "", "comments_url": "%[2]s/s/def/ask_a_question",
+				 "submitter_user": {"username": "bob"}, "tags": ["ask"]}
+			]`, newest, "https://lobste.rs")
+		case "/saved/page/2.json":
+			fmt.Fprint(w, `[{"short_id": "old", "created_at": "2024-01-01T00:00:00.000-06:00", "title": "Old", "url": "https://example.com/old"}]`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	defer srv.Close()
+
+	l, err := NewLobsters("session", WithBaseURL(srv.URL), WithHTTPClient(srv.Client()), WithRateLimit(time.Millisecond))
+	assert.NilError(t, err)
+
+	items := collectItems(t, l, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, len(items), 2)
+	assert.DeepEqual(t, items[0], &source.Item{
+		ID:            "lobsters-abc",
+		Title:         "A story",
+		URL:           "https://example.com/story",
+		Date:          time.Date(2024, 3, 5, 4, 0, 0, 0, time.FixedZone("", -6*3600)),
+		Tags:          []string{"go", "practices"},
+		DiscussionURL: "https://lobste.rs/s/abc/a_story",
+	})
+	assert.Equal(t, items[1].URL, "https://lobste.rs/s/def/ask_a_question")
+	assert.DeepEqual(t, paths, []string{"/saved.json", "/saved/page/2.json"})
+
+	// Once the stories listed by a run are stored, paging stops at them, whenever the new stories were submitted.
+	state, err := l.State()
+	assert.NilError(t, err)
+	assert.Equal(t, string(state), `{"newest":["abc","def","old"]}`)
+	newest = `{"short_id": "older", "created_at": "2023-01-01T00:00:00.000-06:00", "title": "Older", "url": "https://example.com/older"},`
+	paths = nil
+	l, err = NewLobsters("session", WithBaseURL(srv.URL), WithHTTPClient(srv.Client()), WithRateLimit(time.Millisecond))
+	assert.NilError(t, err)
+	assert.NilError(t, l.SetState(state))
+	items = collectItems(t, l, time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC))
+	assert.Equal(t, len(items), 1)
+	assert.Equal(t, items[0].ID, "lobsters-older")
+	assert.DeepEqual(t, paths, []string{"/saved.json"})
+
+	// Once the stored stories are all unsaved, the checkpoint tells the new stories apart.
+	paths = nil
+	l, err = NewLobsters("session", WithBaseURL(srv.URL), WithHTTPClient(srv.Client()), WithRateLimit(time.Millisecond))
+	assert.NilError(t, err)
+	assert.NilError(t, l.SetState(json.RawMessage(`{"newest":["gone"]}`)))
+	items = collectItems(t, l, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, len(items), 2)
+	assert.Equal(t, items[0].ID, "lobsters-abc")
+	assert.Equal(t, items[1].ID, "lobsters-def")
+	assert.DeepEqual(t, paths, []string{"/saved.json", "/saved/page/2.json", "/saved/page/3.json"})
+
+	l, err = NewLobsters("expired", WithBaseURL(srv.URL), WithHTTPClient(srv.Client()), WithRateLimit(time.Millisecond))
+	assert.NilError(t, err)
+	_, err = l.Iterator(context.Background(), time.Time{})
+	assert.ErrorContains(t, err, "unexpected status 403 Forbidden")
+}
+
+func Test_Mastodon(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	status := func(id int, card bool) map[string]any {
+		st := map[string]any{
+			"id":         strconv.Itoa(id),
+			"created_at": start.Add(time.Duration(id) * time.Hour).Format(time.RFC3339),
+			"url":        fmt.Sprintf("https://example.social/@alice/%d", id),
+			"content":    fmt.Sprintf(`<p>Status <strong>%d</strong> <a href="https://example.com/%d" rel="nofollow noopener" target="_blank">example.com/%d</a></p>`, id, id, id),
+			"tags":       []map[string]string{{"name": "golang"}},
+			"card":       nil,
+			"reblog":     nil,
+		}
+		if card {
+			st["card"] = map[string]any{
+				"url":           fmt.Sprintf("https://example.com/%d", id),
+				"title":         fmt.Sprintf("Article %d", id),
+				"author_name":   "Jane Doe",
+				"provider_name": "Example",
+				"provider_url":  "https://example.com/",
+				"image":         "https://example.com/card.png",
+			}
+		}
+		return st
+	}
+	boost := status(100, false)
+	boost["reblog"] = status(6, true)
+	pages := [][]map[string]any{
+		{boost, status(5, true), status(7, false)},
+		{status(4, true), status(2, true)},
+		{status(1, true)},
+	}
+
+	var (
+		requests []string
+		nextHost string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, `{"error": "The access token is invalid"}`, http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, r.URL.Path, "/api/v1/favourites")
+		requests = append(requests, r.URL.RawQuery)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page+1 < len(pages) {
+			host := r.Host
+			if nextHost != "" {
+				host = nextHost
+			}
+			w.Header().Add("Link", fmt.Sprintf(`<http://%s/api/v1/favourites?limit=40&page=%d>; rel="next", <http://%s/api/v1/favourites?min_id=1>; rel="prev"`, host, page+1, r.Host))
+		}
+		assert.NilError(t, json.NewEncoder(w).Encode(pages[page]))
+	}))
+	defer srv.Close()
+
+	m, err := NewMastodon(MastodonFavourites, "token", WithBaseURL(srv.URL+"/"), WithHTTPClient(srv.Client()), WithRateLimit(time.Millisecond))
+	assert.NilError(t, err)
+
+	items := collectItems(t, m, start.Add(2*time.Hour))
+	assert.Equal(t, len(items), 3)
+	assert.DeepEqual(t, items[0], &source.Item{
+		ID:            "mastodon-6",
+		Title:         "Article 6",
+		URL:           "https://example.com/6",
+		Comment:       "Status **6** https://example.com/6",
+		Date:          start.Add(6 * time.Hour),
+		Author:        "Jane Doe",
+		Site:          "Example",
+		SiteURL:       "https://example.com/",
+		Tags:          []string{"golang"},
+		Image:         "https://example.com/card.png",
+		DiscussionURL: "https://example.social/@alice/6",
+	})
+	assert.Equal(t, items[1].ID, "mastodon-5")
+	assert.Equal(t, items[2].ID, "mastodon-4")
+	// The link of the next page is followed until a page has no new statuses.
+	assert.DeepEqual(t, requests, []string{"limit=40", "limit=40&page=1", "limit=40&page=2"})
+
+	// Once the statuses listed by a run are stored, paging stops at them, whenever the new statuses were posted.
+	state, err := m.State()
+	assert.NilError(t, err)
+	assert.Equal(t, string(state), `{"newest":["100","5","7","4","2","1"]}`)
+	pages[0] = append([]map[string]any{status(8, true)}, pages[0]...)
+	requests = nil
+	m, err = NewMastodon(MastodonFavourites, "token", WithBaseURL(srv.URL), WithHTTPClient(srv.Client()), WithRateLimit(time.Millisecond))
+	assert.NilError(t, err)
+	assert.NilError(t, m.SetState(state))
+	items = collectItems(t, m, start.Add(9*time.Hour))
+	assert.Equal(t, len(items), 1)
+	assert.Equal(t, items[0].ID, "mastodon-8")
+	assert.DeepEqual(t, requests, []string{"limit=40"})
+	state, err = m.State()
+	assert.NilError(t, err)
+	assert.Equal(t, string(state), `{"newest":["8","100","5","7","4","2","1"]}`)
+
+	// Once the stored statuses are all removed from the list, the checkpoint tells the new statuses apart.
+	requests = nil
+	m, err = NewMastodon(MastodonFavourites, "token", WithBaseURL(srv.URL), WithHTTPClient(srv.Client()), WithRateLimit(time.Millisecond))
+	assert.NilError(t, err)
+	assert.NilError(t, m.SetState(json.RawMessage(`{"newest":["99","98"]}`)))
+	items = collectItems(t, m, start.Add(5*time.Hour))
+	assert.Equal(t, len(items), 2)
+	assert.Equal(t, items[0].ID, "mastodon-8")
+	assert.Equal(t, items[1].ID, "mastodon-6")
+	assert.DeepEqual(t, requests, []string{"limit=40", "limit=40&page=1", "limit=40&page=2"})
+
+	// The access token is not sent to other hosts.
+	nextHost = "other.example"
+	m, err = NewMastodon(MastodonFavourites, "token", WithBaseURL(srv.URL), WithHTTPClient(srv.Client()), WithRateLimit(time.Millisecond))
+	assert.NilError(t, err)
+	_, err = m.Iterator(context.Background(), time.Time{})
+	assert.ErrorContains(t, err, "next page http://other.example/api/v1/favourites?limit=40&page=1 is not on the instance")
+	nextHost = ""
+
+	m, err = NewMastodon(MastodonBookmarks, "expired", WithBaseURL(srv.URL), WithHTTPClient(srv.Client()), WithRateLimit(time.Millisecond))
+	assert.NilError(t, err)
+	_, err = m.Iterator(context.Background(), time.Time{})
+	assert.ErrorContains(t, err, "unexpected status 401 Unauthorized")
+
+	_, err = NewMastodon(MastodonBookmarks, "token")
+	assert.ErrorContains(t, err, "missing Mastodon instance URL")
+	_, err = NewMastodon("boosts", "token", WithBaseURL(srv.URL))
+	assert.ErrorContains(t, err, `unknown Mastodon list "boosts"`)
+}
+
+func Test_nextLink(t *testing.T) {
+	assert.Equal(t, nextLink([]string{`<https://example.social/api/v1/bookmarks?max_id=7>; rel="next", <https://example.social/api/v1/bookmarks?min_id=9>; rel="prev"`}),
+		"https://example.social/api/v1/bookmarks?max_id=7")
+	assert.Equal(t, nextLink([]string{`<https://example.social/api/v1/bookmarks?min_id=9>; rel="prev"`}), "")
+	assert.Equal(t, nextLink(nil), "")
+}
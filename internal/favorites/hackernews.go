@@ -0,0 +1,195 @@
+package favorites
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/ratelimit"
+	"github.com/seriousben/positronic-blogger/internal/source"
+	"golang.org/x/net/html"
+)
+
+// HackerNews reads the public favorite stories of a Hacker News user.
+// Favorites are only listed on the site, the stories are read from the API.
+type HackerNews struct {
+	markers
+	user string
+	opts *options
+	// limiter spaces out requests to the site and the API alike.
+	limiter *ratelimit.Limiter
+}
+
+// NewHackerNews returns a client of the favorite stories of user.
+func NewHackerNews(user string, opts ...Option) (*HackerNews, error) {
+	if user == "" {
+		return nil, errors.New("missing Hacker News user")
+	}
+	o := newOptions(DefaultHackerNewsURL, opts)
+	return &HackerNews{user: user, opts: o, limiter: ratelimit.New(o.rateLimit)}, nil
+}
+
+// hnFavorite is a story of a favorites page.
+type hnFavorite struct {
+	id string
+	// submitted is zero when the page does not tell.
+	submitted time.Time
+}
+
+// favorites returns the stories of page n of the favorites of the user, starting at 1,
+// and whether there are more pages.
+func (c *HackerNews) favorites(ctx context.Context, n int) ([]hnFavorite, bool, error) {
+	q := url.Values{}
+	q.Set("id", c.user)
+	q.Set("p", strconv.Itoa(n))
+	data, _, err := get(ctx, c.opts.client, c.limiter, c.opts.baseURL+"/favorites?"+q.Encode(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	return parseHNFavorites(data)
+}
+
+// parseHNFavorites reads the story rows of a favorites page: rows of class athing have the ID of their story,
+// followed by a row with its age, whose title is the submission time.
+func parseHNFavorites(data []byte) ([]hnFavorite, bool, error) {
+	var (
+		z         = html.NewTokenizer(bytes.NewReader(data))
+		favorites []hnFavorite
+		more      bool
+	)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, false, fmt.Errorf("parsing favorites: %w", err)
+			}
+			return favorites, more, nil
+		case html.StartTagToken:
+			name, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				attrs[string(k)] = string(v)
+			}
+			classes := strings.Fields(attrs["class"])
+			switch {
+			case string(name) == "tr" && hasClass(classes, "athing") && attrs["id"] != "":
+				favorites = append(favorites, hnFavorite{id: attrs["id"]})
+			case string(name) == "span" && hasClass(classes, "age") && len(favorites) > 0:
+				favorites[len(favorites)-1].submitted = parseHNAge(attrs["title"])
+			case string(name) == "a" && hasClass(classes, "morelink"):
+				more = true
+			}
+		}
+	}
+}
+
+func hasClass(classes []string, class string) bool {
+	for _, c := range classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHNAge parses the title of an age, such as "2024-03-05T10:00:00 1709632800",
+// an ISO time in UTC optionally followed by a Unix time.
+func parseHNAge(s string) time.Time {
+	iso, unix, _ := strings.Cut(s, " ")
+	if sec, err := strconv.ParseInt(unix, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC()
+	}
+	t, err := time.Parse("2006-01-02T15:04:05", iso)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+type hnItem struct {
+	ID      int    `json:"id"`
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Time    int64  `json:"time"`
+	Dead    bool   `json:"dead"`
+	Deleted bool   `json:"deleted"`
+}
+
+// story returns the story with id from the API.
+func (c *HackerNews) story(ctx context.Context, id string) (*hnItem, error) {
+	var it hnItem
+	u := fmt.Sprintf("%s/v0/item/%s.json", c.opts.apiURL, url.PathEscape(id))
+	if _, err := getJSON(ctx, c.opts.client, c.limiter, u, nil, &it); err != nil {
+		return nil, err
+	}
+	if it.ID == 0 {
+		return nil, fmt.Errorf("getting story %s: not found", id)
+	}
+	return &it, nil
+}
+
+// Iterator returns the stories favorited since the previous run, or submitted strictly after newerThan
+// when it is not known, newest first. Stories without a link, such as Ask HN, link to their thread.
+func (c *HackerNews) Iterator(ctx context.Context, newerThan time.Time) (source.Iterator, error) {
+	return collect(ctx, &c.markers, newerThan, c.page)
+}
+
+func (c *HackerNews) page(ctx context.Context, n int, cur *cursor) ([]*source.Item, bool, error) {
+	favorites, more, err := c.favorites(ctx, n)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var (
+		items []*source.Item
+		newer bool
+	)
+	for _, f := range favorites {
+		if cur.done(f.id) {
+			break
+		}
+		// Only the stories that may be new are read from the API.
+		if cur.byDate() && !f.submitted.IsZero() && !f.submitted.After(cur.newerThan) {
+			continue
+		}
+		st, err := c.story(ctx, f.id)
+		if err != nil {
+			return nil, false, err
+		}
+		date := time.Unix(st.Time, 0).UTC()
+		if cur.byDate() && !date.After(cur.newerThan) {
+			continue
+		}
+		newer = true
+		if st.Type != "story" || st.Dead || st.Deleted {
+			continue
+		}
+		items = append(items, c.toItem(st, date))
+	}
+	return items, more && cur.more(newer), nil
+}
+
+func (c *HackerNews) toItem(st *hnItem, date time.Time) *source.Item {
+	id := strconv.Itoa(st.ID)
+	thread := c.opts.baseURL + "/item?id=" + id
+	link := st.URL
+	if link == "" {
+		link = thread
+	}
+	return &source.Item{
+		ID:            "hn-" + id,
+		Title:         st.Title,
+		URL:           link,
+		Date:          date,
+		DiscussionURL: thread,
+	}
+}
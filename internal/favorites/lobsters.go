@@ -0,0 +1,102 @@
+package favorites
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/ratelimit"
+	"github.com/seriousben/positronic-blogger/internal/source"
+)
+
+// lobstersSessionCookie is the name of the session cookie of Lobsters.
+const lobstersSessionCookie = "lobster_trap"
+
+// Lobsters reads the stories saved by a Lobsters user.
+// Saved stories are private, they are read with the session cookie of the user.
+type Lobsters struct {
+	markers
+	session string
+	opts    *options
+	limiter *ratelimit.Limiter
+}
+
+// NewLobsters returns a client of the saved stories of the user logged in with session,
+// the value of the lobster_trap cookie of a logged in browser.
+func NewLobsters(session string, opts ...Option) (*Lobsters, error) {
+	if session == "" {
+		return nil, errors.New("missing Lobsters session")
+	}
+	o := newOptions(DefaultLobstersURL, opts)
+	return &Lobsters{session: session, opts: o, limiter: ratelimit.New(o.rateLimit)}, nil
+}
+
+type lobstersStory struct {
+	ShortID     string    `json:"short_id"`
+	ShortIDURL  string    `json:"short_id_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	CommentsURL string    `json:"comments_url"`
+	Tags        []string  `json:"tags"`
+}
+
+// saved returns the stories of page n of the saved stories, starting at 1.
+func (c *Lobsters) saved(ctx context.Context, n int) ([]lobstersStory, error) {
+	u := c.opts.baseURL + "/saved.json"
+	if n > 1 {
+		u = fmt.Sprintf("%s/saved/page/%d.json", c.opts.baseURL, n)
+	}
+	header := http.Header{"Cookie": {(&http.Cookie{Name: lobstersSessionCookie, Value: c.session}).String()}}
+	var stories []lobstersStory
+	if _, err := getJSON(ctx, c.opts.client, c.limiter, u, header, &stories); err != nil {
+		return nil, err
+	}
+	return stories, nil
+}
+
+// Iterator returns the stories saved since the previous run, or submitted strictly after newerThan
+// when it is not known, newest first. Text stories link to their thread.
+func (c *Lobsters) Iterator(ctx context.Context, newerThan time.Time) (source.Iterator, error) {
+	return collect(ctx, &c.markers, newerThan, c.page)
+}
+
+func (c *Lobsters) page(ctx context.Context, n int, cur *cursor) ([]*source.Item, bool, error) {
+	stories, err := c.saved(ctx, n)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var items []*source.Item
+	for _, st := range stories {
+		if cur.done(st.ShortID) {
+			break
+		}
+		if cur.byDate() && !st.CreatedAt.After(cur.newerThan) {
+			continue
+		}
+		items = append(items, c.toItem(st))
+	}
+	return items, len(stories) > 0 && cur.more(len(items) > 0), nil
+}
+
+func (c *Lobsters) toItem(st lobstersStory) *source.Item {
+	thread := st.CommentsURL
+	if thread == "" {
+		thread = st.ShortIDURL
+	}
+	link := st.URL
+	if link == "" {
+		link = thread
+	}
+	return &source.Item{
+		ID:            "lobsters-" + st.ShortID,
+		Title:         st.Title,
+		URL:           link,
+		Date:          st.CreatedAt,
+		Tags:          st.Tags,
+		DiscussionURL: thread,
+	}
+}
@@ -0,0 +1,209 @@
+package favorites
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/htmlmd"
+	"github.com/seriousben/positronic-blogger/internal/ratelimit"
+	"github.com/seriousben/positronic-blogger/internal/source"
+)
+
+// MastodonList is a list of statuses of a Mastodon user.
+type MastodonList string
+
+const (
+	// MastodonBookmarks are the statuses bookmarked by the user.
+	MastodonBookmarks MastodonList = "bookmarks"
+	// MastodonFavourites are the statuses favourited by the user.
+	MastodonFavourites MastodonList = "favourites"
+)
+
+// ParseMastodonList returns the list named s, an empty s is the bookmarks.
+func ParseMastodonList(s string) (MastodonList, error) {
+	switch l := MastodonList(strings.ToLower(s)); l {
+	case "":
+		return MastodonBookmarks, nil
+	case MastodonBookmarks, MastodonFavourites:
+		return l, nil
+	default:
+		return "", fmt.Errorf("unknown Mastodon list %q, expected one of bookmarks or favourites", s)
+	}
+}
+
+// mastodonPageSize is the number of statuses listed per request, the maximum of the API.
+const mastodonPageSize = 40
+
+// Mastodon reads the bookmarked or favourited statuses of a Mastodon user,
+// or of a user of a server implementing the Mastodon API.
+type Mastodon struct {
+	markers
+	list    MastodonList
+	token   string
+	opts    *options
+	limiter *ratelimit.Limiter
+}
+
+// NewMastodon returns a client of list, authenticated with the access token of an application
+// having the read:bookmarks or read:favourites scope. The URL of the instance must be set WithBaseURL.
+func NewMastodon(list MastodonList, token string, opts ...Option) (*Mastodon, error) {
+	if _, err := ParseMastodonList(string(list)); err != nil {
+		return nil, err
+	}
+	o := newOptions("", opts)
+	if o.baseURL == "" {
+		return nil, errors.New("missing Mastodon instance URL")
+	}
+	if token == "" {
+		return nil, errors.New("missing Mastodon access token")
+	}
+	return &Mastodon{list: list, token: token, opts: o, limiter: ratelimit.New(o.rateLimit)}, nil
+}
+
+type mastodonStatus struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Content   string    `json:"content"`
+	Tags      []struct {
+		Name string `json:"name"`
+	} `json:"tags"`
+	Card *struct {
+		URL          string `json:"url"`
+		Title        string `json:"title"`
+		AuthorName   string `json:"author_name"`
+		ProviderName string `json:"provider_name"`
+		ProviderURL  string `json:"provider_url"`
+		Image        string `json:"image"`
+	} `json:"card"`
+	Reblog *mastodonStatus `json:"reblog"`
+}
+
+// statuses returns the statuses of the page at u and the URL of the next page, empty on the last page.
+func (c *Mastodon) statuses(ctx context.Context, u string) ([]mastodonStatus, string, error) {
+	header := http.Header{"Authorization": {"Bearer " + c.token}}
+	var statuses []mastodonStatus
+	respHeader, err := getJSON(ctx, c.opts.client, c.limiter, u, header, &statuses)
+	if err != nil {
+		return nil, "", err
+	}
+	next := nextLink(respHeader.Values("Link"))
+	// The access token is only sent to the instance.
+	if next != "" && !sameOrigin(next, c.opts.baseURL) {
+		return nil, "", fmt.Errorf("getting %s: next page %s is not on the instance", c.list, next)
+	}
+	return statuses, next, nil
+}
+
+// sameOrigin reports whether two URLs have the same scheme and host.
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) && strings.EqualFold(ua.Host, ub.Host)
+}
+
+// nextLink returns the URL of the next page of a Link header, such as
+// <https://example.social/api/v1/bookmarks?max_id=7>; rel="next", empty when there is none.
+func nextLink(values []string) string {
+	for _, v := range values {
+		for _, link := range strings.Split(v, ",") {
+			target, params, _ := strings.Cut(link, ";")
+			target = strings.TrimSpace(target)
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, p := range strings.Split(params, ";") {
+				k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+				if strings.EqualFold(k, "rel") && slices.ContainsFunc(strings.Fields(strings.Trim(v, `"`)), isNext) {
+					return strings.Trim(target, "<>")
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func isNext(rel string) bool {
+	return strings.EqualFold(rel, "next")
+}
+
+// Iterator returns the statuses added to the list since the previous run, or posted strictly after newerThan
+// when none of the statuses listed by the previous run is known or still listed, and linking to an article,
+// newest first.
+// Articles are the link preview cards of the statuses, statuses without a card are skipped.
+// Boosts are the boosted status.
+func (c *Mastodon) Iterator(ctx context.Context, newerThan time.Time) (source.Iterator, error) {
+	q := url.Values{}
+	q.Set("limit", strconv.Itoa(mastodonPageSize))
+	next := fmt.Sprintf("%s/api/v1/%s?%s", c.opts.baseURL, c.list, q.Encode())
+
+	return collect(ctx, &c.markers, newerThan, func(ctx context.Context, _ int, cur *cursor) ([]*source.Item, bool, error) {
+		statuses, nextURL, err := c.statuses(ctx, next)
+		if err != nil {
+			return nil, false, err
+		}
+		next = nextURL
+
+		var (
+			items []*source.Item
+			newer bool
+		)
+		for _, st := range statuses {
+			if cur.done(st.ID) {
+				break
+			}
+			if st.Reblog != nil {
+				st = *st.Reblog
+			}
+			if cur.byDate() && !st.CreatedAt.After(cur.newerThan) {
+				continue
+			}
+			newer = true
+			if st.Card == nil || st.Card.URL == "" {
+				log.Printf("favorites:mastodon: skipping %s, it has no link", st.URL)
+				continue
+			}
+			items = append(items, toMastodonItem(st))
+		}
+		return items, next != "" && cur.more(newer), nil
+	})
+}
+
+// toMastodonItem maps a status to an item, its text being the comment.
+func toMastodonItem(st mastodonStatus) *source.Item {
+	title := strings.TrimSpace(st.Card.Title)
+	if title == "" {
+		title = st.Card.URL
+	}
+	var tags []string
+	for _, t := range st.Tags {
+		tags = append(tags, t.Name)
+	}
+	return &source.Item{
+		ID:            "mastodon-" + st.ID,
+		Title:         title,
+		URL:           st.Card.URL,
+		Comment:       strings.TrimSpace(htmlmd.Convert(st.Content)),
+		Date:          st.CreatedAt,
+		Author:        st.Card.AuthorName,
+		Site:          st.Card.ProviderName,
+		SiteURL:       st.Card.ProviderURL,
+		Tags:          tags,
+		Image:         st.Card.Image,
+		DiscussionURL: st.URL,
+	}
+}
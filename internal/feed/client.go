@@ -0,0 +1,277 @@
+// Package feed syncs the items of RSS, Atom and JSON Feed feeds,
+// such as the starred or shared items of a feed reader.
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/htmlmd"
+	"github.com/seriousben/positronic-blogger/internal/source"
+)
+
+const (
+	// DefaultTimeout bounds the time spent fetching a feed.
+	DefaultTimeout = 30 * time.Second
+	// maxFeedSize bounds how much of a feed is read.
+	maxFeedSize = 10 << 20
+)
+
+// Client fetches a feed. Feeds served over HTTP are fetched with conditional requests:
+// the feed is only downloaded again when it changed since the last fetch of the client.
+// The validators of the last fetch are the state of the client as a source.Stateful,
+// which the poster stores with the checkpoint for runs of new processes to send them too.
+type Client struct {
+	url     string
+	client  *http.Client
+	timeout time.Duration
+
+	mu           sync.Mutex
+	etag         string
+	lastModified string
+	cached       *Feed
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used to fetch the feed.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+// WithTimeout replaces the DefaultTimeout of fetching the feed.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// New returns a client of the feed at rawURL, an http or https URL,
+// or a file URL or path for feeds written to disk by other tools.
+func New(rawURL string, opts ...Option) *Client {
+	c := &Client{
+		url:     rawURL,
+		client:  http.DefaultClient,
+		timeout: DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Fetch returns the feed, as it was last fetched when it did not change since.
+func (c *Client) Fetch(ctx context.Context) (*Feed, error) {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return c.fetchHTTP(ctx)
+	case "file":
+		return readFile(u.Path)
+	case "":
+		return readFile(c.url)
+	default:
+		return nil, fmt.Errorf("unsupported feed scheme %q", u.Scheme)
+	}
+}
+
+func readFile(path string) (*Feed, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading feed: %w", err)
+	}
+	return Parse(data)
+}
+
+func (c *Client) fetchHTTP(ctx context.Context) (*Feed, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating feed request: %w", err)
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, */*;q=0.8")
+
+	c.mu.Lock()
+	if c.etag != "" {
+		req.Header.Set("If-None-Match", c.etag)
+	}
+	if c.lastModified != "" {
+		req.Header.Set("If-Modified-Since", c.lastModified)
+	}
+	c.mu.Unlock()
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getting feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.cached == nil {
+			// The validators were restored from a previous run, which posted the new items of the feed:
+			// the items it skipped are only read again once the feed changes.
+			return &Feed{}, nil
+		}
+		return c.cached, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting feed: unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading feed: %w", err)
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.etag = resp.Header.Get("ETag")
+	c.lastModified = resp.Header.Get("Last-Modified")
+	c.cached = f
+	c.mu.Unlock()
+	return f, nil
+}
+
+// state is the state of a client, stored with the checkpoint of the feed.
+type state struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// SetState restores the validators of the fetch of a previous run.
+// The validators of a fetch of the client are kept, they are as recent.
+func (c *Client) SetState(raw json.RawMessage) error {
+	if raw == nil {
+		return nil
+	}
+	var st state
+	if err := json.Unmarshal(raw, &st); err != nil {
+		return fmt.Errorf("parsing feed state: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cached == nil {
+		c.etag, c.lastModified = st.ETag, st.LastModified
+	}
+	return nil
+}
+
+// State returns the validators of the last fetch, nil when the feed was not fetched over HTTP
+// or has no validators.
+func (c *Client) State() (json.RawMessage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.etag == "" && c.lastModified == "" {
+		return nil, nil
+	}
+	return json.Marshal(state{ETag: c.etag, LastModified: c.lastModified})
+}
+
+// Iterator returns the items of the feed published strictly after newerThan, newest first.
+// Items without a date are skipped, they cannot be checkpointed.
+func (c *Client) Iterator(ctx context.Context, newerThan time.Time) (source.Iterator, error) {
+	f, err := c.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*source.Item
+	for _, it := range f.Items {
+		if it.Link == "" {
+			continue
+		}
+		if it.Date().IsZero() {
+			log.Printf("feed: skipping %s, it has no date", it.Link)
+			continue
+		}
+		if !it.Date().After(newerThan) {
+			continue
+		}
+		items = append(items, toItem(f, it))
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Date.After(items[j].Date) })
+	return &iterator{items: items}, nil
+}
+
+// toItem maps a feed item to a source item.
+// The summary of the item is its comment, or its content when it has no summary.
+// The feed is only the site of its items when they do not come from other feeds,
+// the starred items of a feed reader are not from the reader.
+func toItem(f *Feed, it Item) *source.Item {
+	comment := it.Summary
+	if comment == "" {
+		comment = it.Content
+	}
+	id := it.ID
+	if id == "" {
+		id = it.Link
+	}
+	site, siteURL := it.Source, it.SourceURL
+	if site == "" && siteURL == "" && sameSite(f.Link, it.Link) {
+		site, siteURL = f.Title, f.Link
+	}
+	return &source.Item{
+		ID:      id,
+		Title:   it.Title,
+		URL:     it.Link,
+		Comment: strings.TrimSpace(htmlmd.Convert(comment)),
+		Date:    it.Date(),
+		Author:  it.Author,
+		Site:    site,
+		SiteURL: siteURL,
+		Tags:    it.Categories,
+		Content: it.Content,
+		Image:   it.Image,
+	}
+}
+
+// sameSite reports whether two links are on the same host, ignoring a www. prefix.
+func sameSite(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil || ua.Host == "" {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.TrimPrefix(strings.ToLower(ua.Host), "www.") == strings.TrimPrefix(strings.ToLower(ub.Host), "www.")
+}
+
+type iterator struct {
+	items []*source.Item
+}
+
+func (i *iterator) Next(ctx context.Context) (*source.Item, error) {
+	if len(i.items) == 0 {
+		return nil, io.EOF
+	}
+	it := i.items[0]
+	i.items = i.items[1:]
+	return it, nil
+}
@@ -0,0 +1,296 @@
+package feed
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/github"
+	"github.com/seriousben/positronic-blogger/internal/github/githubtest"
+	"github.com/seriousben/positronic-blogger/internal/poster"
+	"github.com/seriousben/positronic-blogger/internal/source"
+	"gotest.tools/v3/assert"
+)
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	assert.NilError(t, err)
+	return data
+}
+
+func Test_Parse_RSS(t *testing.T) {
+	f, err := Parse(readTestdata(t, "starred.rss"))
+	assert.NilError(t, err)
+
+	assert.Equal(t, f.Title, "Starred items")
+	assert.Equal(t, f.Link, "https://reader.example/starred")
+	assert.Equal(t, len(f.Items), 3)
+	assert.DeepEqual(t, f.Items[0], Item{
+		ID:         "reader-42",
+		Title:      "Go 1.22 & loops",
+		Link:       "https://go.dev/blog/loopvar-preview",
+		Summary:    "<p>Fixing <b>for</b> loops in Go.</p>",
+		Content:    "<p>The full article.</p>",
+		Author:     "David Chase",
+		Published:  time.Date(2024, 3, 5, 10, 0, 0, 0, time.FixedZone("", 0)),
+		Categories: []string{"golang", "go"},
+		Image:      "https://go.dev/images/gophers.png",
+		Source:     "The Go Blog",
+		SourceURL:  "https://go.dev/blog/feed.atom",
+	})
+	assert.Equal(t, f.Items[1].Author, "Jane Doe")
+	assert.Equal(t, f.Items[1].Image, "https://example.com/older.jpg")
+	assert.Assert(t, f.Items[2].Date().IsZero())
+}
+
+func Test_Parse_Atom(t *testing.T) {
+	f, err := Parse(readTestdata(t, "links.atom"))
+	assert.NilError(t, err)
+
+	assert.Equal(t, f.Title, "Example Links")
+	assert.Equal(t, f.Link, "https://links.example/")
+	assert.Equal(t, len(f.Items), 2)
+
+	first := f.Items[0]
+	assert.Equal(t, first.ID, "tag:links.example,2024:1")
+	assert.Equal(t, first.Link, "https://links.example/posts/1")
+	assert.Equal(t, first.Author, "Links Author")
+	assert.Equal(t, first.Summary, `<div xmlns="http://www.w3.org/1999/xhtml">A <em>great</em> read.</div>`)
+	assert.DeepEqual(t, first.Categories, []string{"Web"})
+	assert.Equal(t, first.Image, "https://links.example/1.png")
+	assert.Assert(t, first.Published.IsZero())
+	assert.Equal(t, first.Date(), time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC))
+
+	second := f.Items[1]
+	assert.Equal(t, second.Author, "Guest")
+	assert.Equal(t, second.Summary, "1 &lt; 2")
+	assert.Assert(t, second.Date().Equal(time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC)))
+}
+
+func Test_Parse_JSON(t *testing.T) {
+	f, err := Parse(readTestdata(t, "linkblog.json"))
+	assert.NilError(t, err)
+
+	assert.Equal(t, f.Title, "A link blog")
+	assert.Equal(t, len(f.Items), 1)
+	it := f.Items[0]
+	assert.Equal(t, it.ID, "7")
+	assert.Equal(t, it.Link, "https://example.org/article")
+	assert.Equal(t, it.Content, "<p>My thoughts about it.</p>")
+	assert.Equal(t, it.Author, "Blogger")
+	assert.Equal(t, it.Image, "https://blog.example/7.jpg")
+	assert.Assert(t, it.Date().Equal(time.Date(2024, 3, 8, 14, 30, 0, 0, time.UTC)))
+}
+
+func Test_Parse_Errors(t *testing.T) {
+	_, err := Parse([]byte(`<html><body>not a feed</body></html>`))
+	assert.ErrorContains(t, err, "unknown feed format with root element <html>")
+	_, err = Parse([]byte(`{"version": "1"}`))
+	assert.ErrorContains(t, err, "unknown JSON feed version")
+	_, err = Parse(nil)
+	assert.ErrorContains(t, err, "empty feed")
+}
+
+func Test_Parse_Latin1(t *testing.T) {
+	f, err := Parse([]byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<rss><channel><title>Caf\xe9</title></channel></rss>"))
+	assert.NilError(t, err)
+	assert.Equal(t, f.Title, "Café")
+}
+
+func collect(t *testing.T, it source.Iterator) []*source.Item {
+	t.Helper()
+	var items []*source.Item
+	for {
+		item, err := it.Next(context.Background())
+		if err == io.EOF {
+			return items
+		}
+		assert.NilError(t, err)
+		items = append(items, item)
+	}
+}
+
+func Test_Client_Iterator(t *testing.T) {
+	ctx := context.Background()
+	c := New(filepath.Join("testdata", "starred.rss"))
+
+	it, err := c.Iterator(ctx, time.Time{})
+	assert.NilError(t, err)
+	items := collect(t, it)
+	assert.Equal(t, len(items), 2)
+	assert.DeepEqual(t, items[0], &source.Item{
+		ID:      "reader-42",
+		Title:   "Go 1.22 & loops",
+		URL:     "https://go.dev/blog/loopvar-preview",
+		Comment: "Fixing **for** loops in Go.",
+		Date:    time.Date(2024, 3, 5, 10, 0, 0, 0, time.FixedZone("", 0)),
+		Author:  "David Chase",
+		Site:    "The Go Blog",
+		SiteURL: "https://go.dev/blog/feed.atom",
+		Tags:    []string{"golang", "go"},
+		Content: "<p>The full article.</p>",
+		Image:   "https://go.dev/images/gophers.png",
+	})
+	// Starred items are not from the reader.
+	assert.Equal(t, items[1].Site, "")
+
+	it, err = c.Iterator(ctx, time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+	assert.NilError(t, err)
+	items = collect(t, it)
+	assert.Equal(t, len(items), 1)
+	assert.Equal(t, items[0].ID, "reader-42")
+
+	// Items of a site's own feed are from the site, newest first.
+	it, err = New("file://"+mustAbs(t, filepath.Join("testdata", "links.atom"))).Iterator(ctx, time.Time{})
+	assert.NilError(t, err)
+	items = collect(t, it)
+	assert.Equal(t, len(items), 2)
+	assert.Equal(t, items[0].URL, "https://links.example/posts/1")
+	assert.Equal(t, items[0].Site, "Example Links")
+	assert.Equal(t, items[0].Comment, "A *great* read.")
+	assert.Equal(t, items[1].Comment, "1 < 2")
+}
+
+func mustAbs(t *testing.T, path string) string {
+	t.Helper()
+	abs, err := filepath.Abs(path)
+	assert.NilError(t, err)
+	return abs
+}
+
+func Test_Client_ConditionalGet(t *testing.T) {
+	ctx := context.Background()
+	data := readTestdata(t, "linkblog.json")
+	var requests, downloads int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/feed+json")
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithHTTPClient(srv.Client()))
+	for range 3 {
+		f, err := c.Fetch(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, f.Title, "A link blog")
+	}
+	assert.Equal(t, requests, 3)
+	assert.Equal(t, downloads, 1)
+
+	// A new client has nothing to compare with.
+	_, err := New(srv.URL, WithHTTPClient(srv.Client())).Fetch(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, downloads, 2)
+}
+
+func Test_Client_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL, WithHTTPClient(srv.Client())).Fetch(context.Background())
+	assert.ErrorContains(t, err, "unexpected status 403 Forbidden")
+	_, err = New("ftp://example.com/feed").Fetch(context.Background())
+	assert.ErrorContains(t, err, `unsupported feed scheme "ftp"`)
+}
+
+func Test_Client_Poster(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+	repo, err := github.New(ctx, "token", srv.Owner, srv.Repo,
+		github.WithBaseURL(srv.BaseURL()),
+		github.WithHTTPClient(srv.Client()),
+		github.WithRateLimit(time.Millisecond),
+	)
+	assert.NilError(t, err)
+
+	p, err := poster.New(poster.Config{
+		Repository: repo,
+		Sources: []poster.SourceConfig{{
+			Name:           "feed",
+			Source:         New(filepath.Join("testdata", "starred.rss")),
+			ContentPath:    "content/links",
+			CheckpointPath: "content/links/starred-checkpoint",
+		}},
+	})
+	assert.NilError(t, err)
+
+	assert.NilError(t, p.Run(ctx))
+	files := srv.Files("main")
+	assert.Equal(t, len(files), 3)
+	assert.Equal(t, files["content/links/starred-checkpoint"], `"2024-03-05T10:00:00Z"`)
+	post := files["content/links/2024-03-05-go-1-22-and-loops.md"]
+	assert.Assert(t, strings.Contains(post, `originalUrl = "https://go.dev/blog/loopvar-preview"`), post)
+	assert.Assert(t, strings.Contains(post, `site = "The Go Blog"`), post)
+
+	// Nothing is published once the checkpoint is up to date.
+	assert.NilError(t, p.Run(ctx))
+	assert.Equal(t, len(srv.PullRequests()), 1)
+}
+
+func Test_Client_Poster_Validators(t *testing.T) {
+	ctx := context.Background()
+	data := readTestdata(t, "starred.rss")
+	var downloads int
+	feedSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write(data)
+	}))
+	defer feedSrv.Close()
+
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+	repo, err := github.New(ctx, "token", srv.Owner, srv.Repo,
+		github.WithBaseURL(srv.BaseURL()),
+		github.WithHTTPClient(srv.Client()),
+		github.WithRateLimit(time.Millisecond),
+	)
+	assert.NilError(t, err)
+
+	// Each run has its own client, as one-shot runs do.
+	run := func() {
+		t.Helper()
+		p, err := poster.New(poster.Config{
+			Repository: repo,
+			Sources: []poster.SourceConfig{{
+				Name:           "feed",
+				Source:         New(feedSrv.URL, WithHTTPClient(feedSrv.Client())),
+				ContentPath:    "content/links",
+				CheckpointPath: "content/links/starred-checkpoint",
+			}},
+		})
+		assert.NilError(t, err)
+		assert.NilError(t, p.Run(ctx))
+	}
+
+	run()
+	files := srv.Files("main")
+	assert.Equal(t, len(files), 3)
+	assert.Equal(t, files["content/links/starred-checkpoint"], `{"checkpoint":"2024-03-05T10:00:00Z","state":{"etag":"\"v1\""}}`)
+
+	// The validators stored with the checkpoint make the next run a conditional request.
+	run()
+	assert.Equal(t, downloads, 1)
+	assert.Equal(t, len(srv.PullRequests()), 1)
+}
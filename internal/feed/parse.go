@@ -0,0 +1,438 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+// Feed is a parsed RSS, Atom or JSON Feed feed.
+type Feed struct {
+	Title string
+	// Link is the home page of the site of the feed.
+	Link  string
+	Items []Item
+}
+
+// Item is an entry of a feed.
+type Item struct {
+	ID    string
+	Title string
+	Link  string
+	// Summary and Content are HTML.
+	Summary    string
+	Content    string
+	Author     string
+	Published  time.Time
+	Updated    time.Time
+	Categories []string
+	Image      string
+	// Source and SourceURL are the name and home page of the site the item comes from,
+	// when the feed aggregates items of other feeds.
+	Source    string
+	SourceURL string
+}
+
+// Date returns when the item was published, or last updated when the feed does not say.
+func (it Item) Date() time.Time {
+	if !it.Published.IsZero() {
+		return it.Published
+	}
+	return it.Updated
+}
+
+// Parse parses an RSS 2.0, RSS 1.0, Atom 1.0 or JSON Feed 1.x document.
+func Parse(data []byte) (*Feed, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseJSON(trimmed)
+	}
+
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, err
+	}
+	switch root {
+	case "rss", "RDF":
+		return parseRSS(data)
+	case "feed":
+		return parseAtom(data)
+	default:
+		return nil, fmt.Errorf("unknown feed format with root element <%s>", root)
+	}
+}
+
+func newDecoder(data []byte) *xml.Decoder {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.CharsetReader = charsetReader
+	// Feeds in the wild are often not quite XML, such as with HTML entities.
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	return d
+}
+
+func rootElement(data []byte) (string, error) {
+	d := newDecoder(data)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				return "", errors.New("empty feed")
+			}
+			return "", fmt.Errorf("parsing feed: %w", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se.Name.Local, nil
+		}
+	}
+}
+
+// charsetReader decodes the non UTF-8 encodings common in feeds.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "latin-1":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return strings.NewReader(string(runes)), nil
+	default:
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+}
+
+type rssDocument struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Links []string  `xml:"link"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	// RSS 1.0 items are siblings of the channel.
+	Items []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Links       []string `xml:"link"`
+	GUID        string   `xml:"guid"`
+	About       string   `xml:"about,attr"`
+	Description []string `xml:"description"`
+	Content     string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string   `xml:"pubDate"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Author      string   `xml:"author"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories  []string `xml:"category"`
+	Enclosures  []struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	Thumbnails []struct {
+		URL string `xml:"url,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	Source struct {
+		URL   string `xml:"url,attr"`
+		Title string `xml:",chardata"`
+	} `xml:"source"`
+}
+
+func parseRSS(data []byte) (*Feed, error) {
+	var doc rssDocument
+	if err := newDecoder(data).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parsing RSS feed: %w", err)
+	}
+
+	f := &Feed{Title: strings.TrimSpace(doc.Channel.Title), Link: first(doc.Channel.Links)}
+	for _, ri := range append(doc.Channel.Items, doc.Items...) {
+		it := Item{
+			ID:         strings.TrimSpace(ri.GUID),
+			Title:      strings.TrimSpace(ri.Title),
+			Link:       first(ri.Links),
+			Summary:    first(ri.Description),
+			Content:    strings.TrimSpace(ri.Content),
+			Author:     strings.TrimSpace(ri.Creator),
+			Published:  parseDate(ri.PubDate),
+			Categories: trimAll(ri.Categories),
+			Source:     strings.TrimSpace(ri.Source.Title),
+			SourceURL:  strings.TrimSpace(ri.Source.URL),
+		}
+		if it.Published.IsZero() {
+			it.Published = parseDate(ri.Date)
+		}
+		if it.Author == "" {
+			it.Author = rssAuthor(ri.Author)
+		}
+		if it.ID == "" {
+			it.ID = ri.About
+		}
+		for _, e := range ri.Enclosures {
+			if strings.HasPrefix(e.Type, "image/") {
+				it.Image = e.URL
+				break
+			}
+		}
+		if it.Image == "" && len(ri.Thumbnails) > 0 {
+			it.Image = ri.Thumbnails[0].URL
+		}
+		f.Items = append(f.Items, it)
+	}
+	return f, nil
+}
+
+// rssAuthor returns the name of an RSS author, an email address optionally followed by a name
+// in parentheses such as "jane@example.com (Jane Doe)".
+func rssAuthor(s string) string {
+	s = strings.TrimSpace(s)
+	if start, end := strings.Index(s, "("), strings.LastIndex(s, ")"); start >= 0 && end > start {
+		return strings.TrimSpace(s[start+1 : end])
+	}
+	return s
+}
+
+type atomDocument struct {
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Authors []atomName  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type atomName struct {
+	Name string `xml:"name"`
+}
+
+// atomText is an Atom text construct, text, escaped html or inline xhtml.
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// html returns the text construct as HTML.
+func (t atomText) html() string {
+	switch t.Type {
+	case "html", "text/html":
+		return strings.TrimSpace(t.Text)
+	case "xhtml":
+		return strings.TrimSpace(t.Inner)
+	default:
+		return html.EscapeString(strings.TrimSpace(t.Text))
+	}
+}
+
+type atomEntry struct {
+	ID         string     `xml:"id"`
+	Title      string     `xml:"title"`
+	Links      []atomLink `xml:"link"`
+	Summary    atomText   `xml:"summary"`
+	Content    atomText   `xml:"content"`
+	Published  string     `xml:"published"`
+	Updated    string     `xml:"updated"`
+	Authors    []atomName `xml:"author"`
+	Categories []struct {
+		Term  string `xml:"term,attr"`
+		Label string `xml:"label,attr"`
+	} `xml:"category"`
+	Thumbnails []struct {
+		URL string `xml:"url,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	Source struct {
+		Title string     `xml:"title"`
+		Links []atomLink `xml:"link"`
+	} `xml:"source"`
+}
+
+// alternate returns the link to the page of a feed or entry.
+func alternate(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return strings.TrimSpace(l.Href)
+		}
+	}
+	return ""
+}
+
+func parseAtom(data []byte) (*Feed, error) {
+	var doc atomDocument
+	if err := newDecoder(data).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parsing Atom feed: %w", err)
+	}
+
+	f := &Feed{Title: strings.TrimSpace(doc.Title), Link: alternate(doc.Links)}
+	for _, e := range doc.Entries {
+		it := Item{
+			ID:        strings.TrimSpace(e.ID),
+			Title:     strings.TrimSpace(e.Title),
+			Link:      alternate(e.Links),
+			Summary:   e.Summary.html(),
+			Content:   e.Content.html(),
+			Published: parseDate(e.Published),
+			Updated:   parseDate(e.Updated),
+			Source:    strings.TrimSpace(e.Source.Title),
+			SourceURL: alternate(e.Source.Links),
+		}
+		authors := e.Authors
+		if len(authors) == 0 {
+			authors = doc.Authors
+		}
+		if len(authors) > 0 {
+			it.Author = strings.TrimSpace(authors[0].Name)
+		}
+		for _, c := range e.Categories {
+			if c.Label != "" {
+				it.Categories = append(it.Categories, strings.TrimSpace(c.Label))
+			} else if c.Term != "" {
+				it.Categories = append(it.Categories, strings.TrimSpace(c.Term))
+			}
+		}
+		for _, l := range e.Links {
+			if l.Rel == "enclosure" && strings.HasPrefix(l.Type, "image/") {
+				it.Image = l.Href
+				break
+			}
+		}
+		if it.Image == "" && len(e.Thumbnails) > 0 {
+			it.Image = e.Thumbnails[0].URL
+		}
+		f.Items = append(f.Items, it)
+	}
+	return f, nil
+}
+
+type jsonDocument struct {
+	Version     string `json:"version"`
+	Title       string `json:"title"`
+	HomePageURL string `json:"home_page_url"`
+	Items       []struct {
+		ID json.RawMessage `json:"id"`
+		// ExternalURL is the page a link blog entry is about.
+		ExternalURL   string     `json:"external_url"`
+		URL           string     `json:"url"`
+		Title         string     `json:"title"`
+		ContentHTML   string     `json:"content_html"`
+		ContentText   string     `json:"content_text"`
+		Summary       string     `json:"summary"`
+		Image         string     `json:"image"`
+		BannerImage   string     `json:"banner_image"`
+		DatePublished string     `json:"date_published"`
+		DateModified  string     `json:"date_modified"`
+		Author        *jsonName  `json:"author"`
+		Authors       []jsonName `json:"authors"`
+		Tags          []string   `json:"tags"`
+	} `json:"items"`
+}
+
+type jsonName struct {
+	Name string `json:"name"`
+}
+
+func parseJSON(data []byte) (*Feed, error) {
+	var doc jsonDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing JSON feed: %w", err)
+	}
+	if !strings.HasPrefix(doc.Version, "https://jsonfeed.org/version/") {
+		return nil, fmt.Errorf("unknown JSON feed version %q", doc.Version)
+	}
+
+	f := &Feed{Title: strings.TrimSpace(doc.Title), Link: doc.HomePageURL}
+	for _, ji := range doc.Items {
+		it := Item{
+			// IDs are strings, but some feeds use numbers.
+			ID:         strings.Trim(string(ji.ID), `"`),
+			Title:      strings.TrimSpace(ji.Title),
+			Link:       ji.ExternalURL,
+			Summary:    html.EscapeString(strings.TrimSpace(ji.Summary)),
+			Content:    strings.TrimSpace(ji.ContentHTML),
+			Published:  parseDate(ji.DatePublished),
+			Updated:    parseDate(ji.DateModified),
+			Categories: trimAll(ji.Tags),
+			Image:      ji.Image,
+		}
+		if it.Link == "" {
+			it.Link = ji.URL
+		}
+		if it.Content == "" && ji.ContentText != "" {
+			it.Content = "<p>" + html.EscapeString(strings.TrimSpace(ji.ContentText)) + "</p>"
+		}
+		if it.Image == "" {
+			it.Image = ji.BannerImage
+		}
+		switch {
+		case len(ji.Authors) > 0:
+			it.Author = ji.Authors[0].Name
+		case ji.Author != nil:
+			it.Author = ji.Author.Name
+		}
+		f.Items = append(f.Items, it)
+	}
+	return f, nil
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseDate parses the RFC 822 dates of RSS and RFC 3339 dates of Atom and JSON Feed,
+// along with their common variants. Malformed dates are zero.
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func first(ss []string) string {
+	for _, s := range ss {
+		if s = strings.TrimSpace(s); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func trimAll(ss []string) []string {
+	var out []string
+	for _, s := range ss {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -3,45 +3,148 @@ package github
 import (
 	"context"
 	"encoding/base64"
-	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/go-github/github"
+	"github.com/seriousben/positronic-blogger/internal/backend"
 	"golang.org/x/oauth2"
 )
 
 const apiRequestRateLimit = 720 * time.Millisecond
 
-var ErrFileNotFound = errors.New("file not found")
+var ErrFileNotFound = backend.ErrFileNotFound
 
 type Client struct {
 	ghClient    *github.Client
 	apiTicker   *time.Ticker
 	owner, repo string
+
+	baseBranchMu sync.Mutex
+	baseBranch   string
+
+	// blobsMu serializes ListFiles so concurrent listings share the blobs read by the first.
+	blobsMu sync.Mutex
+	// blobs caches the content of the blobs read by ListFiles by SHA, blobs never change.
+	blobs map[string]string
+
+	baseURL    string
+	httpClient *http.Client
+	rateLimit  time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseBranch sets the branch posts are read from and merged into.
+// By default, the default branch of the repository is used.
+func WithBaseBranch(branch string) Option {
+	return func(c *Client) {
+		c.baseBranch = branch
+	}
+}
+
+// WithBaseURL sets the URL of the GitHub API, such as a GitHub Enterprise server or a fake.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = baseURL
+	}
 }
 
-func New(ctx context.Context, githubToken, owner, repo string) (*Client, error) {
+// WithHTTPClient sets the HTTP client wrapped to authenticate API requests.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithRateLimit sets the minimum delay between API requests.
+func WithRateLimit(d time.Duration) Option {
+	return func(c *Client) {
+		c.rateLimit = d
+	}
+}
+
+func New(ctx context.Context, githubToken, owner, repo string, opts ...Option) (*Client, error) {
+	cl := &Client{
+		owner:     owner,
+		repo:      repo,
+		rateLimit: apiRequestRateLimit,
+		blobs:     map[string]string{},
+	}
+	for _, opt := range opts {
+		opt(cl)
+	}
+
+	if cl.httpClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, cl.httpClient)
+	}
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: githubToken},
 	)
 	tc := oauth2.NewClient(ctx, ts)
 	c := github.NewClient(tc)
 
-	return &Client{
-		ghClient:  c,
-		apiTicker: time.NewTicker(apiRequestRateLimit),
-		owner:     owner,
-		repo:      repo,
-	}, nil
+	if cl.baseURL != "" {
+		u, err := url.Parse(cl.baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing base URL: %w", err)
+		}
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+		c.BaseURL = u
+	}
+
+	cl.ghClient = c
+	cl.apiTicker = time.NewTicker(cl.rateLimit)
+	return cl, nil
+}
+
+// BaseBranch returns the configured base branch,
+// detecting the default branch of the repository when none is configured.
+func (c *Client) BaseBranch(ctx context.Context) (string, error) {
+	c.baseBranchMu.Lock()
+	defer c.baseBranchMu.Unlock()
+
+	if c.baseBranch != "" {
+		return c.baseBranch, nil
+	}
+
+	<-c.apiTicker.C
+	r, _, err := c.ghClient.Repositories.Get(ctx, c.owner, c.repo)
+	if err != nil {
+		return "", fmt.Errorf("detecting default branch: %w", err)
+	}
+	if r.GetDefaultBranch() == "" {
+		return "", fmt.Errorf("repository %s/%s has no default branch", c.owner, c.repo)
+	}
+	c.baseBranch = r.GetDefaultBranch()
+	log.Printf("Using default branch %s of %s/%s", c.baseBranch, c.owner, c.repo)
+	return c.baseBranch, nil
+}
+
+func (c *Client) baseRef(ctx context.Context) (string, error) {
+	branch, err := c.BaseBranch(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("refs/heads/%s", branch), nil
 }
 
 func (c *Client) GetContent(ctx context.Context, path string) (content string, sha string, err error) {
-	mainRef, _, err := c.ghClient.Git.GetRef(ctx, c.owner, c.repo, "refs/heads/main")
+	baseRef, err := c.baseRef(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	mainRef, _, err := c.ghClient.Git.GetRef(ctx, c.owner, c.repo, baseRef)
 	if err != nil {
 		return "", "", err
 	}
@@ -80,11 +183,101 @@ func (c *Client) GetContent(ctx context.Context, path string) (content string, s
 	return "", "", fmt.Errorf("file not found (%s): %w", path, ErrFileNotFound)
 }
 
+// ListFiles lists dir from a single recursive tree of the base branch.
+// Only the blobs not read by a previous call are downloaded, listing the posts again
+// once they were read only costs the new and changed posts.
+func (c *Client) ListFiles(ctx context.Context, dir string) ([]backend.File, error) {
+	baseRef, err := c.baseRef(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.blobsMu.Lock()
+	defer c.blobsMu.Unlock()
+
+	<-c.apiTicker.C
+	mainRef, _, err := c.ghClient.Git.GetRef(ctx, c.owner, c.repo, baseRef)
+	if err != nil {
+		return nil, err
+	}
+
+	<-c.apiTicker.C
+	tr, _, err := c.ghClient.Git.GetTree(ctx, c.owner, c.repo, *mainRef.Object.SHA, true)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.Trim(filepath.ToSlash(dir), "/") + "/"
+	var files []backend.File
+	for _, te := range tr.Entries {
+		if te.GetType() != "blob" || !strings.HasPrefix(te.GetPath(), prefix) {
+			continue
+		}
+		content, ok := c.blobs[te.GetSHA()]
+		if !ok {
+			<-c.apiTicker.C
+			bl, _, err := c.ghClient.Git.GetBlob(ctx, c.owner, c.repo, te.GetSHA())
+			if err != nil {
+				return nil, err
+			}
+			b, err := base64.StdEncoding.DecodeString(bl.GetContent())
+			if err != nil {
+				return nil, err
+			}
+			content = string(b)
+			c.blobs[te.GetSHA()] = content
+		}
+		files = append(files, backend.File{Path: te.GetPath(), Content: content, SHA: te.GetSHA()})
+	}
+
+	return files, nil
+}
+
 type BranchClient struct {
 	client     *Client
 	branchName string
 	branchRef  string
 	baseRef    string
+	identity   backend.Identity
+}
+
+// SetIdentity sets who the commits of the branch are attributed to.
+// Without identity, commits are attributed to the authenticated user.
+func (c *BranchClient) SetIdentity(id backend.Identity) {
+	c.identity = id
+}
+
+func commitAuthor(s backend.Signature) *github.CommitAuthor {
+	if s.IsZero() {
+		return nil
+	}
+	return &github.CommitAuthor{Name: github.String(s.Name), Email: github.String(s.Email)}
+}
+
+func (c *BranchClient) contentFileOptions(commitMsg, content string) github.RepositoryContentFileOptions {
+	msg := c.identity.Message(commitMsg)
+	return github.RepositoryContentFileOptions{
+		Branch:    &c.branchName,
+		Message:   &msg,
+		Content:   []byte(content),
+		Author:    commitAuthor(c.identity.Author),
+		Committer: commitAuthor(c.identity.EffectiveCommitter()),
+	}
+}
+
+var (
+	_ backend.Repository = (*Client)(nil)
+	_ backend.Branch     = (*BranchClient)(nil)
+)
+
+// OpenBranch implements backend.Repository using StartBranch.
+func (c *Client) OpenBranch(ctx context.Context, branchName string, id backend.Identity) (backend.Branch, error) {
+	brc, err := c.StartBranch(ctx, branchName)
+	if err != nil {
+		return nil, err
+	}
+	brc.SetIdentity(id)
+	return brc, nil
 }
 
 // https://git-scm.com/book/en/v2
@@ -95,7 +288,12 @@ func (c *Client) StartBranch(ctx context.Context, branchName string) (*BranchCli
 
 	ref := fmt.Sprintf("refs/heads/%s", branchName)
 
-	mainRef, _, err := c.ghClient.Git.GetRef(ctx, c.owner, c.repo, "refs/heads/main")
+	baseRef, err := c.baseRef(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mainRef, _, err := c.ghClient.Git.GetRef(ctx, c.owner, c.repo, baseRef)
 	if err != nil {
 		return nil, err
 	}
@@ -116,7 +314,7 @@ func (c *Client) StartBranch(ctx context.Context, branchName string) (*BranchCli
 
 			// Only reuse if the branch is based on current main
 			if *existingRef.Object.SHA == *mainRef.Object.SHA {
-				log.Printf("Branch %s already exists at current main, reusing it", branchName)
+				log.Printf("Branch %s already exists at current %s, reusing it", branchName, baseRef)
 				return &BranchClient{
 					client:     c,
 					branchName: branchName,
@@ -163,8 +361,7 @@ func (c *Client) StartBranch(ctx context.Context, branchName string) (*BranchCli
 			if err != nil {
 				return nil, fmt.Errorf("failed to recreate branch %s: %w", branchName, err)
 			}
-		}
-		if err != nil {
+		} else {
 			return nil, err
 		}
 	}
@@ -183,12 +380,7 @@ func (c *BranchClient) CreateFile(ctx context.Context, commitMsg, path, content
 	// TODO: Manage it as a tree!
 	// https://stackoverflow.com/questions/11801983/how-to-create-a-commit-and-push-into-repo-with-github-api-v3
 
-	var opts = github.RepositoryContentFileOptions{
-		Branch:    &c.branchName,
-		Message:   &commitMsg,
-		Content:   []byte(content),
-		Committer: &github.CommitAuthor{Name: github.String("Benjamin Boudreau"), Email: github.String("boudreau.benjamin@gmail.com")},
-	}
+	var opts = c.contentFileOptions(commitMsg, content)
 	_, resp, err := c.client.ghClient.Repositories.CreateFile(ctx, c.client.owner, c.client.repo, path, &opts)
 	if err != nil {
 		if resp != nil && resp.StatusCode == http.StatusConflict {
@@ -209,13 +401,8 @@ func (c *BranchClient) UpdateFile(ctx context.Context, commitMsg, path, sha, con
 
 	<-c.client.apiTicker.C
 
-	var opts = github.RepositoryContentFileOptions{
-		Branch:    &c.branchName,
-		Message:   &commitMsg,
-		Content:   []byte(content),
-		Committer: &github.CommitAuthor{Name: github.String("Benjamin Boudreau"), Email: github.String("boudreau.benjamin@gmail.com")},
-		SHA:       &sha,
-	}
+	var opts = c.contentFileOptions(commitMsg, content)
+	opts.SHA = &sha
 	_, resp, err := c.client.ghClient.Repositories.CreateFile(ctx, c.client.owner, c.client.repo, path, &opts)
 	if err != nil {
 		if resp != nil && resp.StatusCode == http.StatusConflict {
@@ -229,6 +416,66 @@ func (c *BranchClient) UpdateFile(ctx context.Context, commitMsg, path, sha, con
 	return nil
 }
 
+// CommitFiles creates or replaces all files in a single commit using the Git Data API.
+// Either all files land on the branch or none do.
+func (c *BranchClient) CommitFiles(ctx context.Context, commitMsg string, files []backend.File) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	<-c.client.apiTicker.C
+	ref, _, err := c.client.ghClient.Git.GetRef(ctx, c.client.owner, c.client.repo, c.branchRef)
+	if err != nil {
+		return fmt.Errorf("getting branch %s: %w", c.branchName, err)
+	}
+
+	<-c.client.apiTicker.C
+	parent, _, err := c.client.ghClient.Git.GetCommit(ctx, c.client.owner, c.client.repo, *ref.Object.SHA)
+	if err != nil {
+		return fmt.Errorf("getting branch %s head commit: %w", c.branchName, err)
+	}
+
+	entries := make([]github.TreeEntry, 0, len(files))
+	for _, f := range files {
+		entries = append(entries, github.TreeEntry{
+			Path:    github.String(f.Path),
+			Mode:    github.String("100644"),
+			Type:    github.String("blob"),
+			Content: github.String(f.Content),
+		})
+	}
+
+	<-c.client.apiTicker.C
+	tree, _, err := c.client.ghClient.Git.CreateTree(ctx, c.client.owner, c.client.repo, *parent.Tree.SHA, entries)
+	if err != nil {
+		return fmt.Errorf("creating tree: %w", err)
+	}
+
+	<-c.client.apiTicker.C
+	commitMsg = c.identity.Message(commitMsg)
+	commit, _, err := c.client.ghClient.Git.CreateCommit(ctx, c.client.owner, c.client.repo, &github.Commit{
+		Message:   &commitMsg,
+		Tree:      tree,
+		Parents:   []github.Commit{{SHA: parent.SHA}},
+		Author:    commitAuthor(c.identity.Author),
+		Committer: commitAuthor(c.identity.EffectiveCommitter()),
+	})
+	if err != nil {
+		return fmt.Errorf("creating commit: %w", err)
+	}
+
+	<-c.client.apiTicker.C
+	_, _, err = c.client.ghClient.Git.UpdateRef(ctx, c.client.owner, c.client.repo, &github.Reference{
+		Ref:    github.String(c.branchRef),
+		Object: &github.GitObject{SHA: commit.SHA},
+	}, false)
+	if err != nil {
+		return fmt.Errorf("updating branch %s: %w", c.branchName, err)
+	}
+
+	return nil
+}
+
 func (c *BranchClient) PullRequest(ctx context.Context, title, body string) (*github.PullRequest, error) {
 	<-c.client.apiTicker.C
 
@@ -259,6 +506,18 @@ func (c *BranchClient) PullRequest(ctx context.Context, title, body string) (*gi
 	return pr, nil
 }
 
+// Publish opens a pull request for the branch and, when merge is true, waits for it to be merged.
+func (c *BranchClient) Publish(ctx context.Context, title, body string, merge bool) error {
+	pr, err := c.PullRequest(ctx, title, body)
+	if err != nil {
+		return err
+	}
+	if !merge {
+		return nil
+	}
+	return c.WaitAndMerge(ctx, pr)
+}
+
 func (c *BranchClient) WaitAndMerge(ctx context.Context, pr *github.PullRequest) error {
 	var (
 		i   = 0
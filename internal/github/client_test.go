@@ -0,0 +1,158 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"github.com/seriousben/positronic-blogger/internal/github/githubtest"
+	"gotest.tools/v3/assert"
+)
+
+func newTestClient(t *testing.T, srv *githubtest.Server, opts ...Option) *Client {
+	t.Helper()
+	opts = append([]Option{
+		WithBaseURL(srv.BaseURL()),
+		WithHTTPClient(srv.Client()),
+		WithRateLimit(time.Millisecond),
+	}, opts...)
+	c, err := New(context.Background(), "token", srv.Owner, srv.Repo, opts...)
+	assert.NilError(t, err)
+	return c
+}
+
+func Test_Client_Fake(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+	srv.SetDefaultBranch("trunk")
+	srv.SetFile("trunk", "content/links/checkpoint", "checkpoint")
+
+	c := newTestClient(t, srv)
+
+	branch, err := c.BaseBranch(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, branch, "trunk")
+
+	content, sha, err := c.GetContent(ctx, "content/links/checkpoint")
+	assert.NilError(t, err)
+	assert.Equal(t, content, "checkpoint")
+
+	_, _, err = c.GetContent(ctx, "content/links/missing")
+	assert.Assert(t, errors.Is(err, ErrFileNotFound))
+
+	id := backend.Identity{
+		Author:   backend.Signature{Name: "Jane", Email: "jane@example.com"},
+		Trailers: []backend.Trailer{{Key: "Co-authored-by", Value: "Bot <bot@example.com>"}},
+	}
+	brc, err := c.OpenBranch(ctx, "new-posts", id)
+	assert.NilError(t, err)
+
+	assert.NilError(t, brc.CreateFile(ctx, "add post", "content/links/post.md", "post"))
+	assert.NilError(t, brc.UpdateFile(ctx, "update checkpoint", "content/links/checkpoint", sha, "checkpoint 2"))
+	assert.NilError(t, brc.CommitFiles(ctx, "batch", []backend.File{
+		{Path: "content/links/other.md", Content: "other"},
+		{Path: "content/links/checkpoint", Content: "checkpoint 3"},
+	}))
+
+	commits := srv.Commits("new-posts")
+	assert.Equal(t, commits[0].Message, "batch\n\nCo-authored-by: Bot <bot@example.com>\n")
+	assert.Equal(t, commits[0].Author, githubtest.Signature{Name: "Jane", Email: "jane@example.com"})
+	assert.Equal(t, commits[0].Committer, commits[0].Author)
+	assert.Equal(t, commits[1].Message, "update checkpoint\n\nCo-authored-by: Bot <bot@example.com>\n")
+
+	// Files are only visible on the base branch once published.
+	_, ok := srv.File("trunk", "content/links/post.md")
+	assert.Assert(t, !ok)
+
+	assert.NilError(t, brc.Publish(ctx, "title", "body", true))
+
+	assert.DeepEqual(t, srv.Files("trunk"), map[string]string{
+		"content/links/checkpoint": "checkpoint 3",
+		"content/links/post.md":    "post",
+		"content/links/other.md":   "other",
+	})
+	assert.DeepEqual(t, srv.Branches(), []string{"main", "trunk"})
+	prs := srv.PullRequests()
+	assert.Equal(t, len(prs), 1)
+	assert.Equal(t, prs[0].Base, "trunk")
+	assert.Assert(t, prs[0].Merged)
+
+	files, err := c.ListFiles(ctx, "content/links")
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 3)
+}
+
+// blobCounter counts the blobs read through it.
+type blobCounter struct {
+	next  http.RoundTripper
+	reads int
+}
+
+func (bc *blobCounter) RoundTrip(r *http.Request) (*http.Response, error) {
+	if strings.Contains(r.URL.Path, "/git/blobs/") {
+		bc.reads++
+	}
+	return bc.next.RoundTrip(r)
+}
+
+func Test_Client_ListFiles_Cache(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+	srv.SetFile("main", "content/links/a.md", "a")
+	srv.SetFile("main", "content/links/b.md", "b")
+	srv.SetFile("main", "README.md", "readme")
+
+	counter := &blobCounter{next: srv.Client().Transport}
+	c := newTestClient(t, srv, WithHTTPClient(&http.Client{Transport: counter}))
+
+	files, err := c.ListFiles(ctx, "content/links")
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 2)
+	assert.Equal(t, counter.reads, 2)
+
+	// Only the new and changed blobs are read again.
+	srv.SetFile("main", "content/links/b.md", "b2")
+	srv.SetFile("main", "content/links/c.md", "c")
+	files, err = c.ListFiles(ctx, "content/links")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, files, []backend.File{
+		{Path: "content/links/a.md", Content: "a", SHA: files[0].SHA},
+		{Path: "content/links/b.md", Content: "b2", SHA: files[1].SHA},
+		{Path: "content/links/c.md", Content: "c", SHA: files[2].SHA},
+	})
+	assert.Equal(t, counter.reads, 4)
+}
+
+func Test_Client_Fake_ReuseBranch(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+
+	c := newTestClient(t, srv, WithBaseBranch("main"))
+
+	brc, err := c.StartBranch(ctx, "posts")
+	assert.NilError(t, err)
+	assert.NilError(t, brc.CreateFile(ctx, "add post", "post.md", "post"))
+	assert.NilError(t, brc.Publish(ctx, "title", "body", false))
+
+	// The stale branch and its pull request are replaced once the base branch moves.
+	srv.SetFile("main", "other.md", "other")
+	brc, err = c.StartBranch(ctx, "posts")
+	assert.NilError(t, err)
+	_, ok := srv.File("posts", "post.md")
+	assert.Assert(t, !ok)
+
+	assert.NilError(t, brc.CreateFile(ctx, "add post", "post.md", "post"))
+	assert.NilError(t, brc.Publish(ctx, "title", "body", false))
+
+	prs := srv.PullRequests()
+	assert.Equal(t, len(prs), 2)
+	assert.Equal(t, prs[0].State, "closed")
+	assert.Equal(t, prs[1].State, "open")
+}
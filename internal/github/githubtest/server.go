@@ -0,0 +1,723 @@
+// Package githubtest provides an in-process fake of the GitHub REST API
+// for hermetic tests of the github client.
+//
+// Only the subset of the API used by the client is implemented:
+// repositories, refs, trees, blobs, commits, contents and pull requests.
+// The repository is kept in memory and can be inspected with the Server methods.
+package githubtest
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// DefaultUser is the authenticated user commits are attributed to
+// when a request has no author or committer.
+var DefaultUser = Signature{Name: "positronic-test", Email: "positronic-test@users.noreply.github.com"}
+
+// Signature is the name and email of a commit author or committer.
+type Signature struct {
+	Name  string
+	Email string
+}
+
+// Commit is a commit of the fake repository.
+type Commit struct {
+	SHA       string
+	Message   string
+	Author    Signature
+	Committer Signature
+	Parents   []string
+	tree      string
+}
+
+// PullRequest is a pull request of the fake repository.
+type PullRequest struct {
+	Number int
+	Title  string
+	Body   string
+	Head   string
+	Base   string
+	State  string
+	Merged bool
+}
+
+type treeEntry struct {
+	name string
+	typ  string
+	sha  string
+}
+
+// Server is a fake GitHub API serving a single repository.
+type Server struct {
+	*httptest.Server
+
+	Owner, Repo string
+
+	mu            sync.Mutex
+	defaultBranch string
+	refs          map[string]string
+	blobs         map[string]string
+	trees         map[string][]treeEntry
+	commits       map[string]*Commit
+	pulls         []*PullRequest
+	clock         time.Time
+}
+
+// NewServer starts a fake serving owner/repo, with a main branch holding an empty commit.
+// The server must be closed by the caller.
+func NewServer(owner, repo string) *Server {
+	s := &Server{
+		Owner:         owner,
+		Repo:          repo,
+		defaultBranch: "main",
+		refs:          map[string]string{},
+		blobs:         map[string]string{},
+		trees:         map[string][]treeEntry{},
+		commits:       map[string]*Commit{},
+		clock:         time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	root := s.writeTree(map[string]string{})
+	s.refs["heads/main"] = s.writeCommit("Initial commit", root, nil, DefaultUser, DefaultUser)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// BaseURL returns the API base URL to configure the github client with.
+func (s *Server) BaseURL() string {
+	return s.URL + "/"
+}
+
+// SetDefaultBranch creates branch from the default branch, if needed, and makes it the default.
+func (s *Server) SetDefaultBranch(branch string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.refs["heads/"+branch]; !ok {
+		s.refs["heads/"+branch] = s.refs["heads/"+s.defaultBranch]
+	}
+	s.defaultBranch = branch
+}
+
+// SetFile commits content at path on branch, creating the branch from the default branch if needed.
+func (s *Server) SetFile(branch, p, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	head, ok := s.refs["heads/"+branch]
+	if !ok {
+		head = s.refs["heads/"+s.defaultBranch]
+	}
+	files := s.files(s.commits[head].tree)
+	files[p] = s.writeBlob(content)
+	s.refs["heads/"+branch] = s.writeCommit("Set "+p, s.writeTree(files), []string{head}, DefaultUser, DefaultUser)
+}
+
+// File returns the content of the file at path on branch.
+func (s *Server) File(branch, p string) (string, bool) {
+	files := s.Files(branch)
+	content, ok := files[p]
+	return content, ok
+}
+
+// Files returns the content of every file on branch by path.
+func (s *Server) Files(branch string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	head, ok := s.refs["heads/"+branch]
+	if !ok {
+		return nil
+	}
+	files := map[string]string{}
+	for p, sha := range s.files(s.commits[head].tree) {
+		files[p] = s.blobs[sha]
+	}
+	return files
+}
+
+// Branches returns the sorted names of the branches.
+func (s *Server) Branches() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var branches []string
+	for ref := range s.refs {
+		if b, ok := strings.CutPrefix(ref, "heads/"); ok {
+			branches = append(branches, b)
+		}
+	}
+	sort.Strings(branches)
+	return branches
+}
+
+// Commits returns the first-parent history of branch, newest first.
+func (s *Server) Commits(branch string) []Commit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var commits []Commit
+	sha := s.refs["heads/"+branch]
+	for sha != "" {
+		c := s.commits[sha]
+		commits = append(commits, *c)
+		sha = ""
+		if len(c.Parents) > 0 {
+			sha = c.Parents[0]
+		}
+	}
+	return commits
+}
+
+// Commit returns the commit with sha.
+func (s *Server) Commit(sha string) (Commit, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.commits[sha]
+	if !ok {
+		return Commit{}, false
+	}
+	return *c, true
+}
+
+// PullRequests returns every pull request, open or not.
+func (s *Server) PullRequests() []PullRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prs := make([]PullRequest, 0, len(s.pulls))
+	for _, pr := range s.pulls {
+		prs = append(prs, *pr)
+	}
+	return prs
+}
+
+func hash(kind, content string) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s %d\x00%s", kind, len(content), content)))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) writeBlob(content string) string {
+	sha := hash("blob", content)
+	s.blobs[sha] = content
+	return sha
+}
+
+// writeTree stores the nested trees of files, blob SHAs by path, and returns the root tree SHA.
+func (s *Server) writeTree(files map[string]string) string {
+	var (
+		entries []treeEntry
+		subdirs = map[string]map[string]string{}
+	)
+	for p, sha := range files {
+		dir, rest, ok := strings.Cut(p, "/")
+		if !ok {
+			entries = append(entries, treeEntry{name: p, typ: "blob", sha: sha})
+			continue
+		}
+		if subdirs[dir] == nil {
+			subdirs[dir] = map[string]string{}
+		}
+		subdirs[dir][rest] = sha
+	}
+	for dir, files := range subdirs {
+		entries = append(entries, treeEntry{name: dir, typ: "tree", sha: s.writeTree(files)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s %s %s\n", e.typ, e.name, e.sha)
+	}
+	sha := hash("tree", b.String())
+	s.trees[sha] = entries
+	return sha
+}
+
+// files returns the blob SHAs of the tree by path.
+func (s *Server) files(tree string) map[string]string {
+	files := map[string]string{}
+	for _, e := range s.trees[tree] {
+		if e.typ == "tree" {
+			for p, sha := range s.files(e.sha) {
+				files[e.name+"/"+p] = sha
+			}
+			continue
+		}
+		files[e.name] = e.sha
+	}
+	return files
+}
+
+func (s *Server) writeCommit(msg, tree string, parents []string, author, committer Signature) string {
+	s.clock = s.clock.Add(time.Second)
+	sha := hash("commit", fmt.Sprintf("%s\n%s\n%v\n%v\n%v\n%s", tree, msg, parents, author, committer, s.clock))
+	s.commits[sha] = &Commit{
+		SHA:       sha,
+		Message:   msg,
+		Author:    author,
+		Committer: committer,
+		Parents:   parents,
+		tree:      tree,
+	}
+	return sha
+}
+
+// isAncestor reports whether commit a is reachable from commit b.
+func (s *Server) isAncestor(a, b string) bool {
+	if a == b {
+		return true
+	}
+	c, ok := s.commits[b]
+	if !ok {
+		return false
+	}
+	for _, p := range c.Parents {
+		if s.isAncestor(a, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func signature(a *github.CommitAuthor) Signature {
+	if a == nil || a.GetName() == "" {
+		return DefaultUser
+	}
+	return Signature{Name: a.GetName(), Email: a.GetEmail()}
+}
+
+type apiError struct {
+	status  int
+	message string
+}
+
+func errorf(status int, format string, args ...any) *apiError {
+	return &apiError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, v, apiErr := s.route(r)
+	w.Header().Set("Content-Type", "application/json")
+	if apiErr != nil {
+		w.WriteHeader(apiErr.status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": apiErr.message})
+		return
+	}
+	w.WriteHeader(status)
+	if v != nil {
+		_ = json.NewEncoder(w).Encode(v)
+	}
+}
+
+func (s *Server) route(r *http.Request) (int, any, *apiError) {
+	prefix := fmt.Sprintf("/repos/%s/%s", s.Owner, s.Repo)
+	p, ok := strings.CutPrefix(r.URL.Path, prefix)
+	if !ok {
+		return 0, nil, errorf(http.StatusNotFound, "Not Found")
+	}
+
+	switch {
+	case p == "" && r.Method == http.MethodGet:
+		return http.StatusOK, &github.Repository{
+			Name:          github.String(s.Repo),
+			FullName:      github.String(s.Owner + "/" + s.Repo),
+			DefaultBranch: github.String(s.defaultBranch),
+		}, nil
+	case strings.HasPrefix(p, "/git/refs"):
+		return s.serveRefs(r, strings.TrimPrefix(strings.TrimPrefix(p, "/git/refs"), "/"))
+	case strings.HasPrefix(p, "/git/trees"):
+		return s.serveTrees(r, strings.TrimPrefix(p, "/git/trees"))
+	case strings.HasPrefix(p, "/git/blobs/") && r.Method == http.MethodGet:
+		return s.getBlob(strings.TrimPrefix(p, "/git/blobs/"))
+	case strings.HasPrefix(p, "/git/commits"):
+		return s.serveCommits(r, strings.TrimPrefix(p, "/git/commits"))
+	case strings.HasPrefix(p, "/contents/") && r.Method == http.MethodPut:
+		return s.putContents(r, strings.TrimPrefix(p, "/contents/"))
+	case strings.HasPrefix(p, "/pulls"):
+		return s.servePulls(r, strings.TrimPrefix(p, "/pulls"))
+	}
+	return 0, nil, errorf(http.StatusNotFound, "Not Found")
+}
+
+func decode(r *http.Request, v any) *apiError {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return errorf(http.StatusBadRequest, "Problems parsing JSON: %v", err)
+	}
+	return nil
+}
+
+func (s *Server) reference(ref string) *github.Reference {
+	return &github.Reference{
+		Ref:    github.String("refs/" + ref),
+		Object: &github.GitObject{Type: github.String("commit"), SHA: github.String(s.refs[ref])},
+	}
+}
+
+func (s *Server) serveRefs(r *http.Request, ref string) (int, any, *apiError) {
+	switch r.Method {
+	case http.MethodGet:
+		if _, ok := s.refs[ref]; !ok {
+			return 0, nil, errorf(http.StatusNotFound, "Not Found")
+		}
+		return http.StatusOK, s.reference(ref), nil
+	case http.MethodPost:
+		var req struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		}
+		if err := decode(r, &req); err != nil {
+			return 0, nil, err
+		}
+		ref := strings.TrimPrefix(req.Ref, "refs/")
+		if _, ok := s.refs[ref]; ok {
+			return 0, nil, errorf(http.StatusUnprocessableEntity, "Reference already exists")
+		}
+		if _, ok := s.commits[req.SHA]; !ok {
+			return 0, nil, errorf(http.StatusUnprocessableEntity, "Object does not exist")
+		}
+		s.refs[ref] = req.SHA
+		return http.StatusCreated, s.reference(ref), nil
+	case http.MethodPatch:
+		var req struct {
+			SHA   string `json:"sha"`
+			Force bool   `json:"force"`
+		}
+		if err := decode(r, &req); err != nil {
+			return 0, nil, err
+		}
+		old, ok := s.refs[ref]
+		if !ok {
+			return 0, nil, errorf(http.StatusUnprocessableEntity, "Reference does not exist")
+		}
+		if _, ok := s.commits[req.SHA]; !ok {
+			return 0, nil, errorf(http.StatusUnprocessableEntity, "Object does not exist")
+		}
+		if !req.Force && !s.isAncestor(old, req.SHA) {
+			return 0, nil, errorf(http.StatusUnprocessableEntity, "Update is not a fast forward")
+		}
+		s.refs[ref] = req.SHA
+		return http.StatusOK, s.reference(ref), nil
+	case http.MethodDelete:
+		if _, ok := s.refs[ref]; !ok {
+			return 0, nil, errorf(http.StatusUnprocessableEntity, "Reference does not exist")
+		}
+		delete(s.refs, ref)
+		return http.StatusNoContent, nil, nil
+	}
+	return 0, nil, errorf(http.StatusMethodNotAllowed, "Method Not Allowed")
+}
+
+func (s *Server) serveTrees(r *http.Request, p string) (int, any, *apiError) {
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(p, "/"):
+		sha := strings.TrimPrefix(p, "/")
+		if c, ok := s.commits[sha]; ok {
+			sha = c.tree
+		}
+		if _, ok := s.trees[sha]; !ok {
+			return 0, nil, errorf(http.StatusNotFound, "Not Found")
+		}
+		return http.StatusOK, s.tree(sha, r.URL.Query().Get("recursive") != ""), nil
+	case r.Method == http.MethodPost && p == "":
+		var req struct {
+			BaseTree string             `json:"base_tree"`
+			Tree     []github.TreeEntry `json:"tree"`
+		}
+		if err := decode(r, &req); err != nil {
+			return 0, nil, err
+		}
+		files := map[string]string{}
+		if req.BaseTree != "" {
+			if _, ok := s.trees[req.BaseTree]; !ok {
+				return 0, nil, errorf(http.StatusUnprocessableEntity, "Invalid base_tree")
+			}
+			files = s.files(req.BaseTree)
+		}
+		for _, e := range req.Tree {
+			switch {
+			case e.Content != nil:
+				files[e.GetPath()] = s.writeBlob(e.GetContent())
+			case e.SHA != nil:
+				if _, ok := s.blobs[e.GetSHA()]; !ok {
+					return 0, nil, errorf(http.StatusUnprocessableEntity, "Invalid tree entry %s", e.GetPath())
+				}
+				files[e.GetPath()] = e.GetSHA()
+			default:
+				delete(files, e.GetPath())
+			}
+		}
+		return http.StatusCreated, s.tree(s.writeTree(files), false), nil
+	}
+	return 0, nil, errorf(http.StatusMethodNotAllowed, "Method Not Allowed")
+}
+
+func (s *Server) tree(sha string, recursive bool) *github.Tree {
+	t := &github.Tree{SHA: github.String(sha)}
+	var walk func(prefix, sha string)
+	walk = func(prefix, sha string) {
+		for _, e := range s.trees[sha] {
+			mode := "100644"
+			if e.typ == "tree" {
+				mode = "040000"
+			}
+			t.Entries = append(t.Entries, github.TreeEntry{
+				Path: github.String(path.Join(prefix, e.name)),
+				Mode: github.String(mode),
+				Type: github.String(e.typ),
+				SHA:  github.String(e.sha),
+			})
+			if recursive && e.typ == "tree" {
+				walk(path.Join(prefix, e.name), e.sha)
+			}
+		}
+	}
+	walk("", sha)
+	return t
+}
+
+func (s *Server) getBlob(sha string) (int, any, *apiError) {
+	content, ok := s.blobs[sha]
+	if !ok {
+		return 0, nil, errorf(http.StatusNotFound, "Not Found")
+	}
+	return http.StatusOK, &github.Blob{
+		SHA:      github.String(sha),
+		Content:  github.String(base64.StdEncoding.EncodeToString([]byte(content))),
+		Encoding: github.String("base64"),
+		Size:     github.Int(len(content)),
+	}, nil
+}
+
+func (s *Server) commit(sha string) *github.Commit {
+	c := s.commits[sha]
+	gc := &github.Commit{
+		SHA:       github.String(sha),
+		Message:   github.String(c.Message),
+		Tree:      &github.Tree{SHA: github.String(c.tree)},
+		Author:    &github.CommitAuthor{Name: github.String(c.Author.Name), Email: github.String(c.Author.Email)},
+		Committer: &github.CommitAuthor{Name: github.String(c.Committer.Name), Email: github.String(c.Committer.Email)},
+	}
+	for _, p := range c.Parents {
+		gc.Parents = append(gc.Parents, github.Commit{SHA: github.String(p)})
+	}
+	return gc
+}
+
+func (s *Server) serveCommits(r *http.Request, p string) (int, any, *apiError) {
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(p, "/"):
+		sha := strings.TrimPrefix(p, "/")
+		if _, ok := s.commits[sha]; !ok {
+			return 0, nil, errorf(http.StatusNotFound, "Not Found")
+		}
+		return http.StatusOK, s.commit(sha), nil
+	case r.Method == http.MethodPost && p == "":
+		var req struct {
+			Message   string               `json:"message"`
+			Tree      string               `json:"tree"`
+			Parents   []string             `json:"parents"`
+			Author    *github.CommitAuthor `json:"author"`
+			Committer *github.CommitAuthor `json:"committer"`
+		}
+		if err := decode(r, &req); err != nil {
+			return 0, nil, err
+		}
+		if _, ok := s.trees[req.Tree]; !ok {
+			return 0, nil, errorf(http.StatusUnprocessableEntity, "Tree SHA does not exist")
+		}
+		for _, p := range req.Parents {
+			if _, ok := s.commits[p]; !ok {
+				return 0, nil, errorf(http.StatusUnprocessableEntity, "Parent SHA does not exist")
+			}
+		}
+		author := signature(req.Author)
+		committer := author
+		if req.Committer != nil {
+			committer = signature(req.Committer)
+		}
+		sha := s.writeCommit(req.Message, req.Tree, req.Parents, author, committer)
+		return http.StatusCreated, s.commit(sha), nil
+	}
+	return 0, nil, errorf(http.StatusMethodNotAllowed, "Method Not Allowed")
+}
+
+func (s *Server) putContents(r *http.Request, p string) (int, any, *apiError) {
+	var req github.RepositoryContentFileOptions
+	if err := decode(r, &req); err != nil {
+		return 0, nil, err
+	}
+
+	branch := req.GetBranch()
+	if branch == "" {
+		branch = s.defaultBranch
+	}
+	head, ok := s.refs["heads/"+branch]
+	if !ok {
+		return 0, nil, errorf(http.StatusNotFound, "Branch %s not found", branch)
+	}
+
+	files := s.files(s.commits[head].tree)
+	existing, exists := files[p]
+	switch {
+	case exists && req.SHA == nil:
+		return 0, nil, errorf(http.StatusUnprocessableEntity, "Invalid request.\n\n\"sha\" wasn't supplied.")
+	case req.SHA != nil && req.GetSHA() != existing:
+		return 0, nil, errorf(http.StatusConflict, "%s does not match %s", p, req.GetSHA())
+	}
+
+	files[p] = s.writeBlob(string(req.Content))
+	author := signature(req.Author)
+	committer := author
+	if req.Committer != nil {
+		committer = signature(req.Committer)
+	}
+	sha := s.writeCommit(req.GetMessage(), s.writeTree(files), []string{head}, author, committer)
+	s.refs["heads/"+branch] = sha
+
+	status := http.StatusCreated
+	if exists {
+		status = http.StatusOK
+	}
+	return status, &github.RepositoryContentResponse{
+		Content: &github.RepositoryContent{
+			Name: github.String(path.Base(p)),
+			Path: github.String(p),
+			SHA:  github.String(files[p]),
+		},
+		Commit: *s.commit(sha),
+	}, nil
+}
+
+func (s *Server) pullRequest(pr *PullRequest) *github.PullRequest {
+	return &github.PullRequest{
+		Number:    github.Int(pr.Number),
+		State:     github.String(pr.State),
+		Title:     github.String(pr.Title),
+		Body:      github.String(pr.Body),
+		Merged:    github.Bool(pr.Merged),
+		Mergeable: github.Bool(pr.State == "open"),
+		Head:      &github.PullRequestBranch{Ref: github.String(pr.Head), SHA: github.String(s.refs["heads/"+pr.Head])},
+		Base:      &github.PullRequestBranch{Ref: github.String(pr.Base), SHA: github.String(s.refs["heads/"+pr.Base])},
+	}
+}
+
+func (s *Server) lookupPull(p string) (*PullRequest, string, *apiError) {
+	num, rest, _ := strings.Cut(strings.TrimPrefix(p, "/"), "/")
+	n, err := strconv.Atoi(num)
+	if err != nil || n < 1 || n > len(s.pulls) {
+		return nil, "", errorf(http.StatusNotFound, "Not Found")
+	}
+	return s.pulls[n-1], rest, nil
+}
+
+func (s *Server) servePulls(r *http.Request, p string) (int, any, *apiError) {
+	switch {
+	case r.Method == http.MethodGet && p == "":
+		var (
+			q     = r.URL.Query()
+			state = q.Get("state")
+			head  = q.Get("head")
+		)
+		if state == "" {
+			state = "open"
+		}
+		// GitHub expects owner:branch, the owner is optional here.
+		head = strings.TrimPrefix(head, s.Owner+":")
+		prs := []*github.PullRequest{}
+		for _, pr := range s.pulls {
+			if (state == "all" || pr.State == state) && (head == "" || pr.Head == head) {
+				prs = append(prs, s.pullRequest(pr))
+			}
+		}
+		return http.StatusOK, prs, nil
+	case r.Method == http.MethodPost && p == "":
+		var req github.NewPullRequest
+		if err := decode(r, &req); err != nil {
+			return 0, nil, err
+		}
+		pr := &PullRequest{
+			Number: len(s.pulls) + 1,
+			Title:  req.GetTitle(),
+			Body:   req.GetBody(),
+			Head:   strings.TrimPrefix(req.GetHead(), "refs/heads/"),
+			Base:   strings.TrimPrefix(req.GetBase(), "refs/heads/"),
+			State:  "open",
+		}
+		head, ok := s.refs["heads/"+pr.Head]
+		if !ok {
+			return 0, nil, errorf(http.StatusUnprocessableEntity, "Validation Failed: head %s does not exist", pr.Head)
+		}
+		base, ok := s.refs["heads/"+pr.Base]
+		if !ok {
+			return 0, nil, errorf(http.StatusUnprocessableEntity, "Validation Failed: base %s does not exist", pr.Base)
+		}
+		if s.isAncestor(head, base) {
+			return 0, nil, errorf(http.StatusUnprocessableEntity, "Validation Failed: No commits between %s and %s", pr.Base, pr.Head)
+		}
+		s.pulls = append(s.pulls, pr)
+		return http.StatusCreated, s.pullRequest(pr), nil
+	}
+
+	pr, rest, apiErr := s.lookupPull(p)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+
+	switch {
+	case r.Method == http.MethodGet && rest == "":
+		return http.StatusOK, s.pullRequest(pr), nil
+	case r.Method == http.MethodPatch && rest == "":
+		var req github.PullRequest
+		if err := decode(r, &req); err != nil {
+			return 0, nil, err
+		}
+		if req.State != nil && !pr.Merged {
+			pr.State = req.GetState()
+		}
+		if req.Title != nil {
+			pr.Title = req.GetTitle()
+		}
+		return http.StatusOK, s.pullRequest(pr), nil
+	case r.Method == http.MethodPut && rest == "merge":
+		if pr.State != "open" {
+			return 0, nil, errorf(http.StatusMethodNotAllowed, "Pull Request is not mergeable")
+		}
+		head, ok := s.refs["heads/"+pr.Head]
+		if !ok {
+			return 0, nil, errorf(http.StatusMethodNotAllowed, "Head branch was deleted")
+		}
+		base := s.refs["heads/"+pr.Base]
+		if !s.isAncestor(base, head) {
+			return 0, nil, errorf(http.StatusMethodNotAllowed, "Pull Request is not mergeable")
+		}
+		msg := fmt.Sprintf("Merge pull request #%d from %s/%s\n\n%s", pr.Number, s.Owner, pr.Head, pr.Title)
+		sha := s.writeCommit(msg, s.commits[head].tree, []string{base, head}, DefaultUser, DefaultUser)
+		s.refs["heads/"+pr.Base] = sha
+		pr.State = "closed"
+		pr.Merged = true
+		return http.StatusOK, &github.PullRequestMergeResult{
+			SHA:     github.String(sha),
+			Merged:  github.Bool(true),
+			Message: github.String("Pull Request successfully merged"),
+		}, nil
+	}
+	return 0, nil, errorf(http.StatusMethodNotAllowed, "Method Not Allowed")
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/seriousben/positronic-blogger/internal/backend"
 	"gotest.tools/v3/assert"
 )
 
@@ -65,6 +66,13 @@ Content of file2
 	`)
 	assert.NilError(t, err)
 
+	file3Path := fmt.Sprintf("%s/%s", branchName, "file3.md")
+	err = br.CommitFiles(ctx, "adding third file and checkpoint", []backend.File{
+		{Path: file3Path, Content: "# File3"},
+		{Path: fmt.Sprintf("%s/%s", branchName, "checkpoint"), Content: uid},
+	})
+	assert.NilError(t, err)
+
 	//t.Logf("manual verification time: %s", branchName)
 	//time.Sleep(30 * time.Second)
 
@@ -81,6 +89,10 @@ Content of file2
 	assert.NilError(t, err)
 	assert.Equal(t, content, file1Content)
 
+	content, _, err = cl.GetContent(contentCtx, file3Path)
+	assert.NilError(t, err)
+	assert.Equal(t, content, "# File3")
+
 	_, _, err = cl.GetContent(contentCtx, "file-not-found")
 	assert.ErrorIs(t, err, ErrFileNotFound)
 }
@@ -0,0 +1,288 @@
+// Package htmlmd converts HTML snippets, such as NewsBlur comments, to Markdown.
+package htmlmd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	spacesRegex   = regexp.MustCompile(`[ \t\r\f\v]+`)
+	newlinesRegex = regexp.MustCompile(`\n{3,}`)
+	urlRegex      = regexp.MustCompile(`(?i)\b(?:https?://|mailto:)[^\s<>"]+`)
+)
+
+// escaper escapes the Markdown metacharacters of text.
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+)
+
+// escape escapes the Markdown metacharacters of text, except in its bare URLs,
+// which Markdown renderers link as they are.
+func escape(s string) string {
+	var (
+		b    strings.Builder
+		last int
+	)
+	for _, loc := range urlRegex.FindAllStringIndex(s, -1) {
+		b.WriteString(escaper.Replace(s[last:loc[0]]))
+		b.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(escaper.Replace(s[last:]))
+	return b.String()
+}
+
+// blockElements are rendered as Markdown blocks, everything else is inline.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Figure: true,
+	atom.Footer: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Header: true, atom.Hr: true, atom.Li: true,
+	atom.Main: true, atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true,
+	atom.Table: true, atom.Tr: true, atom.Ul: true,
+}
+
+// Convert returns the Markdown of an HTML snippet.
+//
+// Links, emphasis, code, lists, blockquotes, headings and images are converted,
+// entities are decoded and unknown elements are replaced by their text.
+// Markdown metacharacters of the text, such as * and _, are escaped.
+// Snippets without any block element or line break are plain text with some inline markup:
+// their line breaks are kept instead of being collapsed as HTML whitespace.
+func Convert(s string) string {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(s), body)
+	if err != nil {
+		// Reading from a string cannot fail.
+		return s
+	}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+
+	c := &converter{keepNewlines: !hasBreaks(body)}
+	return strings.Join(c.blocks(body), "\n\n")
+}
+
+func hasBreaks(n *html.Node) bool {
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if ch.Type == html.ElementNode && (ch.DataAtom == atom.Br || blockElements[ch.DataAtom]) {
+			return true
+		}
+		if hasBreaks(ch) {
+			return true
+		}
+	}
+	return false
+}
+
+type converter struct {
+	keepNewlines bool
+}
+
+func isBlock(n *html.Node) bool {
+	return n.Type == html.ElementNode && blockElements[n.DataAtom]
+}
+
+// blocks renders the children of n as Markdown blocks.
+// Consecutive inline children make a paragraph.
+func (c *converter) blocks(n *html.Node) []string {
+	var (
+		out []string
+		run strings.Builder
+	)
+	flush := func() {
+		if p := clean(run.String()); p != "" {
+			out = append(out, p)
+		}
+		run.Reset()
+	}
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if !isBlock(ch) {
+			run.WriteString(c.inline(ch))
+			continue
+		}
+		flush()
+		if b := c.block(ch); b != "" {
+			out = append(out, b)
+		}
+	}
+	flush()
+	return out
+}
+
+func (c *converter) block(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		title := strings.ReplaceAll(clean(c.inlineChildren(n)), "\n", " ")
+		if title == "" {
+			return ""
+		}
+		level := int(n.Data[1] - '0')
+		return strings.Repeat("#", level) + " " + title
+	case atom.Blockquote:
+		return prefixLines(strings.Join(c.blocks(n), "\n\n"), "> ", "> ")
+	case atom.Ul, atom.Ol:
+		return c.list(n)
+	case atom.Pre:
+		code := strings.TrimSuffix(text(n), "\n")
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		return fence + "\n" + code + "\n" + fence
+	case atom.Hr:
+		return "---"
+	default:
+		return strings.Join(c.blocks(n), "\n\n")
+	}
+}
+
+// list renders a tight list, nested blocks are indented under their item.
+func (c *converter) list(n *html.Node) string {
+	var items []string
+	num := 1
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if ch.Type != html.ElementNode || ch.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+		content := strings.Join(c.blocks(ch), "\n")
+		items = append(items, prefixLines(content, marker, strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+func (c *converter) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		b.WriteString(c.inline(ch))
+	}
+	return b.String()
+}
+
+func (c *converter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		if c.keepNewlines {
+			return escape(n.Data)
+		}
+		return escape(strings.ReplaceAll(n.Data, "\n", " "))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Title:
+		return ""
+	case atom.Br:
+		return "\n"
+	case atom.A:
+		return c.link(n)
+	case atom.Img:
+		src := attr(n, "src")
+		if src == "" {
+			return ""
+		}
+		return "![" + attr(n, "alt") + "](" + src + ")"
+	case atom.B, atom.Strong:
+		return wrap(c.inlineChildren(n), "**")
+	case atom.I, atom.Em:
+		return wrap(c.inlineChildren(n), "*")
+	case atom.Del, atom.S, atom.Strike:
+		return wrap(c.inlineChildren(n), "~~")
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		return wrap(text(n), "`")
+	default:
+		// Blocks nested in inline elements, such as a paragraph in a link, are flattened.
+		return c.inlineChildren(n)
+	}
+}
+
+// link renders a link. Links whose text is their URL are left bare,
+// links to anything but web pages and email addresses are replaced by their text.
+func (c *converter) link(n *html.Node) string {
+	label := strings.ReplaceAll(clean(c.inlineChildren(n)), "\n", " ")
+	href := strings.TrimSpace(attr(n, "href"))
+
+	lower := strings.ToLower(href)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") && !strings.HasPrefix(lower, "mailto:") {
+		return label
+	}
+	switch strings.ReplaceAll(clean(text(n)), "\n", " ") {
+	case "", href, strings.TrimPrefix(strings.TrimPrefix(href, "https://"), "http://"), strings.TrimPrefix(href, "mailto:"):
+		return href
+	}
+	return "[" + label + "](" + strings.ReplaceAll(strings.ReplaceAll(href, "(", "%28"), ")", "%29") + ")"
+}
+
+// wrap surrounds s with a Markdown delimiter, keeping its surrounding spaces outside,
+// where delimiters must be for the emphasis to apply.
+func wrap(s, delim string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	start := strings.Index(s, trimmed)
+	return s[:start] + delim + trimmed + delim + s[start+len(trimmed):]
+}
+
+// clean collapses the spaces of inline content and trims its lines.
+func clean(s string) string {
+	lines := strings.Split(spacesRegex.ReplaceAllString(s, " "), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	s = strings.Join(lines, "\n")
+	return strings.Trim(newlinesRegex.ReplaceAllString(s, "\n\n"), "\n")
+}
+
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		p := rest
+		if i == 0 {
+			p = first
+		}
+		if l == "" {
+			p = strings.TrimRight(p, " ")
+		}
+		lines[i] = p + l
+	}
+	return strings.Join(lines, "\n")
+}
+
+func text(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		b.WriteString(text(ch))
+	}
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package htmlmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func Test_Convert(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		html string
+		md   string
+	}{
+		{
+			name: "plain text",
+			html: "Great read.\nSecond line.",
+			md:   "Great read.\nSecond line.",
+		},
+		{
+			name: "entities",
+			html: "Tips &amp; tricks &lt;3 &quot;quoted&quot; &#8212; caf&eacute;",
+			md:   `Tips & tricks <3 "quoted" — café`,
+		},
+		{
+			name: "linkified url",
+			html: `See <a href="https://example.com/related">https://example.com/related</a>`,
+			md:   "See https://example.com/related",
+		},
+		{
+			name: "two links on one line",
+			html: `<a href="https://a.example/">first</a> and <a href="https://b.example/x">https://b.example/x</a> too`,
+			md:   "[first](https://a.example/) and https://b.example/x too",
+		},
+		{
+			name: "link text without scheme",
+			html: `<a href="https://example.com/post" rel="nofollow">example.com/post</a>`,
+			md:   "https://example.com/post",
+		},
+		{
+			name: "link with parentheses and emphasis",
+			html: `<a href="https://en.wikipedia.org/wiki/Go_(programming_language)"><b>Go</b> on Wikipedia</a>`,
+			md:   "[**Go** on Wikipedia](https://en.wikipedia.org/wiki/Go_%28programming_language%29)",
+		},
+		{
+			name: "unsafe link",
+			html: `<a href="javascript:alert(1)">click</a> me`,
+			md:   "click me",
+		},
+		{
+			name: "emphasis",
+			html: `This is <b>bold</b>, <em>emphasized </em>and <code>code()</code>, not <del>wrong</del>.`,
+			md:   "This is **bold**, *emphasized* and `code()`, not ~~wrong~~.",
+		},
+		{
+			name: "line breaks",
+			html: "First line<br>second line<br/>\nthird   line",
+			md:   "First line\nsecond line\nthird line",
+		},
+		{
+			name: "paragraphs",
+			html: "<p>One\nparagraph.</p>\n<p>Another&nbsp;one.</p>",
+			md:   "One paragraph.\n\nAnother one.",
+		},
+		{
+			name: "lists",
+			html: "<p>Takeaways:</p><ul><li>Simple</li><li>Fast<ul><li>really</li></ul></li></ul><ol><li>one</li><li>two</li></ol>",
+			md:   "Takeaways:\n\n- Simple\n- Fast\n  - really\n\n1. one\n2. two",
+		},
+		{
+			name: "blockquote",
+			html: "<blockquote><p>Simplicity is prerequisite for reliability.</p><p>Dijkstra</p></blockquote>So true.",
+			md:   "> Simplicity is prerequisite for reliability.\n>\n> Dijkstra\n\nSo true.",
+		},
+		{
+			name: "code block",
+			html: "<pre><code>if err != nil {\n\treturn err\n}\n</code></pre>",
+			md:   "```\nif err != nil {\n\treturn err\n}\n```",
+		},
+		{
+			name: "heading and image",
+			html: `<h2>Summary</h2><img src="https://example.com/a.png" alt="diagram">`,
+			md:   "## Summary\n\n![diagram](https://example.com/a.png)",
+		},
+		{
+			name: "unknown elements and scripts",
+			html: `<span class="x">kept</span><script>alert(1)</script>`,
+			md:   "kept",
+		},
+		{
+			name: "markdown metacharacters",
+			html: `foo_bar_baz *not emphasis* [sic] a\b <code>a_b</code> https://example.com/a_b*c`,
+			md:   "foo\\_bar\\_baz \\*not emphasis\\* \\[sic\\] a\\\\b `a_b` https://example.com/a_b*c",
+		},
+		{
+			name: "link label with brackets",
+			html: `<a href="https://example.com/a_b">[PDF] my_file</a> <a href="https://example.com/a_b">https://example.com/a_b</a>`,
+			md:   "[\\[PDF\\] my\\_file](https://example.com/a_b) https://example.com/a_b",
+		},
+		{
+			name: "empty",
+			html: "  ",
+			md:   "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, Convert(tc.html), tc.md)
+		})
+	}
+}
+
+// Test_Convert_NewsBlur converts comments as returned by the NewsBlur API,
+// URLs linkified, entities encoded and line breaks kept, to their Markdown in the .md file of the same name.
+func Test_Convert_NewsBlur(t *testing.T) {
+	files, err := filepath.Glob("testdata/newsblur/*.html")
+	assert.NilError(t, err)
+	assert.Assert(t, len(files) > 0)
+	for _, f := range files {
+		t.Run(filepath.Base(f), func(t *testing.T) {
+			in, err := os.ReadFile(f)
+			assert.NilError(t, err)
+			want, err := os.ReadFile(strings.TrimSuffix(f, ".html") + ".md")
+			assert.NilError(t, err)
+			assert.Equal(t, Convert(string(in)), string(want))
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Package importer backfills posts from bookmark exports, such as the bookmark files of browsers.
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"github.com/seriousben/positronic-blogger/internal/canonical"
+	"github.com/seriousben/positronic-blogger/internal/dedup"
+	"github.com/seriousben/positronic-blogger/internal/template"
+)
+
+// DefaultBatchSize is the number of posts committed at once.
+// It keeps the trees sent to GitHub small enough for imports of thousands of bookmarks.
+const DefaultBatchSize = 100
+
+// Filter selects the entries to import, its zero value selects all of them.
+type Filter struct {
+	// Folder selects the entries of a folder and of its subfolders, such as Blog/Go.
+	Folder string
+	// Since and Until select the entries added in [Since, Until), when not zero.
+	Since time.Time
+	Until time.Time
+	// Tags selects the entries having at least one of the tags.
+	Tags []string
+}
+
+// Match reports whether e is selected. Entries without a date do not match a date range.
+func (f Filter) Match(e Entry) bool {
+	if folder := strings.Trim(f.Folder, "/"); folder != "" {
+		if !strings.EqualFold(e.Folder, folder) && !hasPrefixFold(e.Folder, folder+"/") {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && (e.Added.IsZero() || e.Added.Before(f.Since)) {
+		return false
+	}
+	if !f.Until.IsZero() && (e.Added.IsZero() || !e.Added.Before(f.Until)) {
+		return false
+	}
+	if len(f.Tags) > 0 && !slices.ContainsFunc(e.Tags, func(t string) bool {
+		return slices.ContainsFunc(f.Tags, func(want string) bool { return strings.EqualFold(t, want) })
+	}) {
+		return false
+	}
+	return true
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// Importer turns bookmarks into posts committed to a single branch.
+type Importer struct {
+	Repository backend.Repository
+	// ContentPath is where posts are created, and where existing posts are looked up.
+	ContentPath string
+	// LookupPaths are other paths where existing posts are looked up,
+	// such as the content paths of the configured sources.
+	LookupPaths []string
+	// Template renders posts, template.Default is used when nil.
+	Template *template.Template
+	// Canonicalizer cleans up the links of entries, links are kept as they are when nil.
+	Canonicalizer *canonical.Canonicalizer
+	// Identity is who the commits are attributed to.
+	Identity backend.Identity
+	// BatchSize is the number of posts per commit, DefaultBatchSize when zero.
+	BatchSize int
+}
+
+// Plan is what an import creates.
+type Plan struct {
+	Files []backend.File
+	// Duplicates is the number of entries already posted, or imported twice.
+	Duplicates int
+	// Undated is the number of entries skipped for having no date.
+	Undated int
+}
+
+// Plan renders the posts of entries that were not posted yet.
+// Entries without a date are skipped, a post is named after its date.
+func (im *Importer) Plan(ctx context.Context, entries []Entry) (*Plan, error) {
+	if im.Repository == nil {
+		return nil, errors.New("missing repository")
+	}
+	if im.ContentPath == "" {
+		return nil, errors.New("missing content path")
+	}
+	tmpl := im.Template
+	if tmpl == nil {
+		tmpl = template.Default
+	}
+
+	// Posts are never created over the existing files of the content path.
+	idx := dedup.New()
+	paths := map[string]bool{}
+	for _, dir := range append([]string{im.ContentPath}, im.LookupPaths...) {
+		files, err := im.Repository.ListFiles(ctx, dir)
+		if err != nil {
+			return nil, fmt.Errorf("listing posts: %w", err)
+		}
+		idx.AddFiles(files)
+		if dir != im.ContentPath {
+			continue
+		}
+		for _, f := range files {
+			paths[f.Path] = true
+		}
+	}
+
+	plan := &Plan{}
+	for _, e := range entries {
+		if e.Added.IsZero() {
+			log.Printf("import: skipping %s, it has no date", e.URL)
+			plan.Undated++
+			continue
+		}
+
+		post := entryToPost(e)
+		if im.Canonicalizer != nil {
+			post.URL = im.Canonicalizer.Canonicalize(ctx, post.URL)
+		}
+		if existing, ok := idx.Lookup(post.URL); ok {
+			log.Printf("import: skipping %s, already posted in %s", post.URL, existing.Path)
+			plan.Duplicates++
+			continue
+		}
+
+		filePath := uniquePath(paths, path.Join(im.ContentPath, tmpl.FileName(post)))
+		paths[filePath] = true
+		idx.Add(post.URL, dedup.Entry{Path: filePath})
+
+		buf, err := tmpl.Render(post)
+		if err != nil {
+			return nil, fmt.Errorf("rendering %s: %w", e.URL, err)
+		}
+		plan.Files = append(plan.Files, backend.File{Path: filePath, Content: buf.String()})
+	}
+	return plan, nil
+}
+
+// Import commits the files of plan to branchName, in batches, and opens a single pull request.
+func (im *Importer) Import(ctx context.Context, branchName, title string, plan *Plan) error {
+	if len(plan.Files) == 0 {
+		return nil
+	}
+	size := im.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+
+	brc, err := im.Repository.OpenBranch(ctx, branchName, im.Identity)
+	if err != nil {
+		return err
+	}
+	batches := (len(plan.Files) + size - 1) / size
+	for i, batch := range slices.Collect(slices.Chunk(plan.Files, size)) {
+		commit := fmt.Sprintf("auto: import %d posts [skip ci]", len(batch))
+		if batches > 1 {
+			commit = fmt.Sprintf("auto: import %d posts (%d/%d) [skip ci]", len(batch), i+1, batches)
+		}
+		if err := brc.CommitFiles(ctx, commit, batch); err != nil {
+			return fmt.Errorf("committing batch %d of %d: %w", i+1, batches, err)
+		}
+		log.Printf("import: committed batch %d of %d", i+1, batches)
+	}
+
+	body := fmt.Sprintf("Imported %d bookmarks with https://github.com/seriousben/positronic-blogger", len(plan.Files))
+	if plan.Duplicates > 0 {
+		body += fmt.Sprintf(", skipped %d already posted", plan.Duplicates)
+	}
+	return brc.Publish(ctx, title, body, false)
+}
+
+func entryToPost(e Entry) template.Post {
+	title := strings.TrimSpace(e.Title)
+	if title == "" {
+		title = e.URL
+	}
+	return template.Post{
+		Title:   title,
+		URL:     strings.TrimSpace(e.URL),
+		Comment: strings.TrimSpace(e.Description),
+		Date:    e.Added,
+		Tags:    e.Tags,
+	}
+}
+
+// uniquePath returns p, or p with a numbered suffix when it is already taken,
+// bookmarks of the same day may have the same title.
+func uniquePath(taken map[string]bool, p string) string {
+	if !taken[p] {
+		return p
+	}
+	ext := path.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	for i := 2; ; i++ {
+		if c := fmt.Sprintf("%s-%d%s", base, i, ext); !taken[c] {
+			return c
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/canonical"
+	"github.com/seriousben/positronic-blogger/internal/github"
+	"github.com/seriousben/positronic-blogger/internal/github/githubtest"
+	"gotest.tools/v3/assert"
+)
+
+func parseTestdata(t *testing.T, name string) []Entry {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	assert.NilError(t, err)
+	f, err := DetectFormat(name, data)
+	assert.NilError(t, err)
+	entries, err := Parse(f, data)
+	assert.NilError(t, err)
+	return entries
+}
+
+func Test_Parse_Netscape(t *testing.T) {
+	entries := parseTestdata(t, "bookmarks.html")
+	assert.DeepEqual(t, entries, []Entry{
+		{
+			Title:       "Fixing For Loops in Go 1.22",
+			URL:         "https://go.dev/blog/loopvar-preview",
+			Description: "Loop variables are per iteration & no longer shared.",
+			Tags:        []string{"go", "blog"},
+			Folder:      "Bookmarks bar",
+			Added:       time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			Title:  "An essay",
+			URL:    "https://example.com/essay?utm_source=rss",
+			Folder: "Bookmarks bar/Blog",
+			Added:  time.Unix(1700000000, 0).UTC(),
+		},
+		{
+			Title:  "An essay",
+			URL:    "https://example.com/same-day",
+			Folder: "Bookmarks bar/Blog",
+			Added:  time.Unix(1700000100, 0).UTC(),
+		},
+		{Title: "No date", URL: "https://example.org/undated"},
+		{Title: "Microseconds", URL: "https://example.org/micro", Added: time.Unix(1600000000, 0).UTC()},
+	})
+}
+
+func Test_Parse_OPML(t *testing.T) {
+	entries := parseTestdata(t, "links.opml")
+	assert.DeepEqual(t, entries, []Entry{
+		{
+			Title:  "Go 1.22 & loops",
+			URL:    "https://go.dev/blog/loopvar-preview",
+			Tags:   []string{"go", "blog"},
+			Folder: "Reading",
+			Added:  time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC),
+		},
+		{Title: "The Go Blog", URL: "https://go.dev/blog/", Folder: "Reading"},
+	})
+}
+
+func Test_Parse_CSV(t *testing.T) {
+	entries := parseTestdata(t, "pocket.csv")
+	assert.DeepEqual(t, entries, []Entry{{
+		Title:       "Pocket, saved",
+		URL:         "https://example.com/pocket",
+		Description: "Saved for later",
+		Tags:        []string{"go", "reading"},
+		Added:       time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC),
+	}})
+
+	_, err := Parse(FormatCSV, []byte("title,date\nNo links,2024-03-05\n"))
+	assert.ErrorContains(t, err, "missing url column")
+}
+
+func Test_DetectFormat(t *testing.T) {
+	f, err := DetectFormat("export", []byte("<!DOCTYPE NETSCAPE-Bookmark-file-1>"))
+	assert.NilError(t, err)
+	assert.Equal(t, f, FormatNetscape)
+	f, err = DetectFormat("export.xml", []byte(`<?xml version="1.0"?><opml version="2.0">`))
+	assert.NilError(t, err)
+	assert.Equal(t, f, FormatOPML)
+	_, err = DetectFormat("export", []byte("url,title"))
+	assert.ErrorContains(t, err, "cannot detect the format of export")
+	_, err = ParseFormat("json")
+	assert.ErrorContains(t, err, `unknown import format "json"`)
+}
+
+func Test_Filter(t *testing.T) {
+	entries := parseTestdata(t, "bookmarks.html")
+	match := func(f Filter) []string {
+		var urls []string
+		for _, e := range entries {
+			if f.Match(e) {
+				urls = append(urls, e.URL)
+			}
+		}
+		return urls
+	}
+
+	assert.Equal(t, len(match(Filter{})), 5)
+	assert.DeepEqual(t, match(Filter{Folder: "bookmarks bar/blog/"}), []string{
+		"https://example.com/essay?utm_source=rss",
+		"https://example.com/same-day",
+	})
+	assert.Equal(t, len(match(Filter{Folder: "Bookmarks bar"})), 3)
+	assert.Equal(t, len(match(Filter{Folder: "Bookmarks"})), 0)
+	assert.DeepEqual(t, match(Filter{Tags: []string{"Go"}}), []string{"https://go.dev/blog/loopvar-preview"})
+	assert.DeepEqual(t, match(Filter{
+		Since: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}), []string{
+		"https://example.com/essay?utm_source=rss",
+		"https://example.com/same-day",
+	})
+}
+
+func Test_Importer(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+	srv.SetFile("main", "content/links/2024-03-05-go-loops.md", "+++\noriginalUrl = \"https://go.dev/blog/loopvar-preview/\"\n+++\n")
+	// A post of the same name as an imported one is not overwritten.
+	srv.SetFile("main", "content/links/2023-11-14-an-essay.md", "+++\noriginalUrl = \"https://example.com/other-essay\"\n+++\n")
+	// Posts of the other content paths are not imported again.
+	srv.SetFile("main", "content/notes/2020-09-13-microseconds.md", "+++\noriginalUrl = \"https://example.org/micro\"\n+++\n")
+	repo, err := github.New(ctx, "token", srv.Owner, srv.Repo,
+		github.WithBaseURL(srv.BaseURL()),
+		github.WithHTTPClient(srv.Client()),
+		github.WithRateLimit(time.Millisecond),
+	)
+	assert.NilError(t, err)
+
+	entries := parseTestdata(t, "bookmarks.html")
+	// The same link bookmarked twice is imported once.
+	entries = append(entries, Entry{Title: "Again", URL: "https://example.com/essay", Added: time.Now()})
+
+	im := &Importer{
+		Repository:    repo,
+		ContentPath:   "content/links",
+		LookupPaths:   []string{"content/links", "content/notes"},
+		Canonicalizer: canonical.New(),
+		BatchSize:     1,
+	}
+	plan, err := im.Plan(ctx, entries)
+	assert.NilError(t, err)
+	assert.Equal(t, plan.Duplicates, 3)
+	assert.Equal(t, plan.Undated, 1)
+	var paths []string
+	for _, f := range plan.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.DeepEqual(t, paths, []string{
+		"content/links/2023-11-14-an-essay-2.md",
+		"content/links/2023-11-14-an-essay-3.md",
+	})
+	assert.Assert(t, strings.Contains(plan.Files[0].Content, `originalUrl = "https://example.com/essay"`), plan.Files[0].Content)
+
+	assert.NilError(t, im.Import(ctx, "import", "Import 2 bookmarks", plan))
+	commits := srv.Commits("import")
+	assert.Equal(t, commits[0].Message, "auto: import 1 posts (2/2) [skip ci]")
+	assert.Equal(t, commits[1].Message, "auto: import 1 posts (1/2) [skip ci]")
+	assert.Equal(t, srv.Files("import")["content/links/2023-11-14-an-essay.md"], srv.Files("main")["content/links/2023-11-14-an-essay.md"])
+	assert.Equal(t, len(srv.Files("import")), 5)
+
+	prs := srv.PullRequests()
+	assert.Equal(t, len(prs), 1)
+	assert.Equal(t, prs[0].Title, "Import 2 bookmarks")
+	assert.Equal(t, prs[0].Merged, false)
+	assert.Equal(t, len(srv.Files("main")), 3)
+}
@@ -0,0 +1,377 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Format is the format of a bookmarks export.
+type Format string
+
+const (
+	// FormatNetscape is the bookmark HTML file exported by browsers and most bookmarking services.
+	FormatNetscape Format = "netscape"
+	// FormatOPML is an outline of links, such as exported by outliners and feed readers.
+	FormatOPML Format = "opml"
+	// FormatCSV is a table with a header row, such as exported by Pocket or Raindrop.
+	FormatCSV Format = "csv"
+)
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatNetscape, FormatOPML, FormatCSV:
+		return f, nil
+	case "html":
+		return FormatNetscape, nil
+	default:
+		return "", fmt.Errorf("unknown import format %q, expected one of netscape, opml or csv", s)
+	}
+}
+
+// DetectFormat guesses the format of the export named name from its extension, then its content.
+func DetectFormat(name string, data []byte) (Format, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".html", ".htm":
+		return FormatNetscape, nil
+	case ".opml":
+		return FormatOPML, nil
+	case ".csv":
+		return FormatCSV, nil
+	}
+	head := strings.ToLower(string(data[:min(len(data), 512)]))
+	switch {
+	case strings.Contains(head, "<!doctype netscape-bookmark-file"), strings.Contains(head, "<dl"):
+		return FormatNetscape, nil
+	case strings.Contains(head, "<opml"):
+		return FormatOPML, nil
+	}
+	return "", fmt.Errorf("cannot detect the format of %s, set it explicitly", name)
+}
+
+// Entry is a bookmarked link.
+type Entry struct {
+	Title       string
+	URL         string
+	Description string
+	Tags        []string
+	// Folder is the path of the folder holding the entry, its parts separated by a slash.
+	Folder string
+	// Added is when the link was bookmarked, it is zero when the export does not say.
+	Added time.Time
+}
+
+// Parse returns the entries of an export in format f, in the order of the file.
+func Parse(f Format, data []byte) ([]Entry, error) {
+	switch f {
+	case FormatNetscape:
+		return parseNetscape(data)
+	case FormatOPML:
+		return parseOPML(data)
+	case FormatCSV:
+		return parseCSV(data)
+	default:
+		return nil, fmt.Errorf("unknown import format %q", f)
+	}
+}
+
+// parseNetscape parses the bookmark file format, an HTML document of nested definition lists:
+// folders are <H3> headings followed by a <DL> of their bookmarks, bookmarks are <A> links,
+// optionally followed by a <DD> description. Elements are rarely closed.
+func parseNetscape(data []byte) ([]Entry, error) {
+	var (
+		z       = html.NewTokenizer(bytes.NewReader(data))
+		entries []Entry
+		// folders holds the name of the folder of each open list, empty for the root list.
+		folders []string
+		// heading is the name of the last folder heading, the folder of the next list.
+		heading string
+		// text is where the text being read goes: a heading, a link title or a description.
+		text    *string
+		current = -1
+	)
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, fmt.Errorf("parsing bookmarks: %w", err)
+			}
+			for i := range entries {
+				entries[i].Title = strings.TrimSpace(entries[i].Title)
+				entries[i].Description = strings.TrimSpace(entries[i].Description)
+			}
+			return entries, nil
+
+		case html.TextToken:
+			if text != nil {
+				*text += string(z.Text())
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				attrs[string(k)] = string(v)
+			}
+
+			switch atom.Lookup(name) {
+			case atom.H3:
+				heading = ""
+				text = &heading
+				current = -1
+			case atom.Dl:
+				folders = append(folders, strings.TrimSpace(heading))
+				heading = ""
+				text = nil
+				current = -1
+			case atom.A:
+				e := Entry{
+					URL:    attrs["href"],
+					Tags:   splitTags(attrs["tags"]),
+					Folder: folderPath(folders),
+					Added:  parseUnix(attrs["add_date"]),
+				}
+				entries = append(entries, e)
+				current = len(entries) - 1
+				text = &entries[current].Title
+			case atom.Dd:
+				if current >= 0 {
+					text = &entries[current].Description
+				} else {
+					text = nil
+				}
+			case atom.Dt, atom.P:
+				text = nil
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Dl:
+				if len(folders) > 0 {
+					folders = folders[:len(folders)-1]
+				}
+				text = nil
+				current = -1
+			case atom.A, atom.H3, atom.Dd:
+				text = nil
+			}
+		}
+	}
+}
+
+// folderPath joins the names of nested folders, skipping unnamed lists.
+func folderPath(folders []string) string {
+	var parts []string
+	for _, f := range folders {
+		if f != "" {
+			parts = append(parts, f)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr"`
+	URL      string        `xml:"url,attr"`
+	HTMLURL  string        `xml:"htmlUrl,attr"`
+	Created  string        `xml:"created,attr"`
+	Category string        `xml:"category,attr"`
+	Note     string        `xml:"_note,attr"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlDocument struct {
+	Body struct {
+		Outlines []opmlOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+// parseOPML parses an outline, outlines with a url or htmlUrl are links, the others are folders.
+// The htmlUrl of a feed subscription is the site of the feed.
+func parseOPML(data []byte) ([]Entry, error) {
+	var doc opmlDocument
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	if err := d.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parsing OPML: %w", err)
+	}
+
+	var entries []Entry
+	var walk func(outlines []opmlOutline, folders []string)
+	walk = func(outlines []opmlOutline, folders []string) {
+		for _, o := range outlines {
+			title := o.Title
+			if title == "" {
+				title = o.Text
+			}
+			u := o.URL
+			if u == "" {
+				u = o.HTMLURL
+			}
+			if u == "" {
+				walk(o.Outlines, append(folders, strings.TrimSpace(title)))
+				continue
+			}
+			var tags []string
+			for _, c := range strings.Split(o.Category, ",") {
+				// Categories may be paths, such as /Tags/go.
+				if c = strings.Trim(strings.TrimSpace(c), "/"); c != "" {
+					tags = append(tags, c[strings.LastIndex(c, "/")+1:])
+				}
+			}
+			entries = append(entries, Entry{
+				Title:       title,
+				URL:         u,
+				Description: o.Note,
+				Tags:        tags,
+				Folder:      folderPath(folders),
+				Added:       parseDate(o.Created),
+			})
+			walk(o.Outlines, append(folders, strings.TrimSpace(title)))
+		}
+	}
+	walk(doc.Body.Outlines, nil)
+	return entries, nil
+}
+
+// csvColumns are the header names recognized for each field, in order of preference.
+var csvColumns = map[string][]string{
+	"url":         {"url", "href", "link"},
+	"title":       {"title", "name"},
+	"description": {"description", "note", "notes", "excerpt", "extended", "comment"},
+	"tags":        {"tags", "tag", "labels"},
+	"folder":      {"folder", "collection", "path"},
+	"added":       {"created", "added", "time_added", "date_added", "date", "time"},
+}
+
+// parseCSV parses a table whose first row names its columns.
+// Only the url column is required.
+func parseCSV(data []byte) ([]Entry, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("parsing CSV: missing header row")
+	}
+
+	header := map[string]int{}
+	for i, name := range records[0] {
+		header[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	columns := map[string]int{}
+	for field, names := range csvColumns {
+		columns[field] = -1
+		for _, name := range names {
+			if i, ok := header[name]; ok {
+				columns[field] = i
+				break
+			}
+		}
+	}
+	if columns["url"] < 0 {
+		return nil, errors.New("parsing CSV: missing url column")
+	}
+
+	get := func(record []string, field string) string {
+		if i := columns[field]; i >= 0 && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var entries []Entry
+	for _, record := range records[1:] {
+		e := Entry{
+			Title:       get(record, "title"),
+			URL:         get(record, "url"),
+			Description: get(record, "description"),
+			Tags:        splitTags(get(record, "tags")),
+			Folder:      strings.Trim(get(record, "folder"), "/"),
+			Added:       parseDate(get(record, "added")),
+		}
+		if e.URL == "" {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// splitTags splits a list of tags separated by commas, pipes or semicolons,
+// or by spaces when there are none of those.
+func splitTags(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '|' || r == ';' })
+	if len(fields) <= 1 {
+		fields = strings.Fields(s)
+	}
+	var tags []string
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			tags = append(tags, f)
+		}
+	}
+	return tags
+}
+
+// parseUnix parses a time in seconds since the epoch, the zero time when it is not one.
+func parseUnix(s string) time.Time {
+	sec, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || sec <= 0 {
+		return time.Time{}
+	}
+	// Some browsers export microseconds.
+	if sec > 1e11 {
+		return time.UnixMicro(sec).UTC()
+	}
+	return time.Unix(sec, 0).UTC()
+}
+
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseDate parses a date in one of the common export layouts or in seconds since the epoch,
+// the zero time when it is none of those.
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}
+	}
+	if t := parseUnix(s); !t.IsZero() {
+		return t
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
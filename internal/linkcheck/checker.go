@@ -0,0 +1,368 @@
+// Package linkcheck audits the links of published posts for link rot.
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"github.com/seriousben/positronic-blogger/internal/template"
+)
+
+const (
+	DefaultConcurrency = 8
+	// DefaultHostDelay is the minimum delay between two requests to the same host.
+	DefaultHostDelay = time.Second
+	DefaultRetries   = 2
+	DefaultTimeout   = 30 * time.Second
+
+	maxRedirects = 10
+	// maxBodySize bounds how much of a page is read before closing the connection.
+	maxBodySize = 64 << 10
+)
+
+// Post is a published post and the link it curates.
+type Post struct {
+	Path string
+	// SHA is the blob SHA of the post on the base branch.
+	SHA     string
+	Content string
+	URL     string
+}
+
+// Posts returns the posts under dir having an originalUrl, by path.
+func Posts(ctx context.Context, repo backend.Repository, dir string) ([]Post, error) {
+	files, err := repo.ListFiles(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing posts: %w", err)
+	}
+
+	var posts []Post
+	for _, f := range files {
+		switch path.Ext(f.Path) {
+		case ".md", ".markdown", ".html":
+		default:
+			continue
+		}
+		_, fm, err := template.ParseFrontMatter(f.Content)
+		if err != nil {
+			log.Printf("linkcheck: skipping %s: %v", f.Path, err)
+			continue
+		}
+		u, ok := fm["originalUrl"].(string)
+		if !ok || u == "" {
+			continue
+		}
+		posts = append(posts, Post{Path: f.Path, SHA: f.SHA, Content: f.Content, URL: u})
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].Path < posts[j].Path })
+	return posts, nil
+}
+
+// Status is the outcome of checking a link.
+type Status string
+
+const (
+	// StatusOK links work, possibly after a redirect that does not change the page,
+	// such as from http to https.
+	StatusOK Status = "ok"
+	// StatusRedirected links redirect to another page, where the article moved.
+	StatusRedirected Status = "redirected"
+	// StatusChanged links redirect to the home page of a site, the article is likely gone.
+	StatusChanged Status = "changed"
+	// StatusDead links are not found, gone or their host does not exist anymore.
+	StatusDead Status = "dead"
+	// StatusError links could not be checked, such as on timeouts, server errors or blocked requests.
+	StatusError Status = "error"
+)
+
+// Result is the outcome of checking the link of a post.
+type Result struct {
+	Path   string `json:"path"`
+	URL    string `json:"url"`
+	Status Status `json:"status"`
+	// Code is the HTTP status code of the last response, zero when there was none.
+	Code int `json:"code,omitempty"`
+	// FinalURL is where the link redirects to.
+	FinalURL string `json:"finalUrl,omitempty"`
+	// Permanent is whether all the redirects are permanent.
+	Permanent bool   `json:"permanent,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Attempts  int    `json:"attempts"`
+}
+
+// Checker checks links with bounded concurrency, per host rate limits and retries.
+type Checker struct {
+	client      *http.Client
+	concurrency int
+	hostDelay   time.Duration
+	retries     int
+	timeout     time.Duration
+	backoff     time.Duration
+
+	// slots bounds the requests in flight to concurrency.
+	slots chan struct{}
+
+	mu    sync.Mutex
+	hosts map[string]time.Time
+}
+
+type Option func(*Checker)
+
+// WithHTTPClient sets the HTTP client used to check links. Its redirect policy is replaced.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Checker) {
+		cl := *client
+		c.client = &cl
+	}
+}
+
+// WithConcurrency replaces the DefaultConcurrency of requests in flight.
+func WithConcurrency(n int) Option {
+	return func(c *Checker) {
+		c.concurrency = n
+	}
+}
+
+// WithHostDelay replaces the DefaultHostDelay between requests to the same host.
+func WithHostDelay(d time.Duration) Option {
+	return func(c *Checker) {
+		c.hostDelay = d
+	}
+}
+
+// WithRetries replaces the DefaultRetries of links that could not be checked.
+func WithRetries(n int) Option {
+	return func(c *Checker) {
+		c.retries = n
+	}
+}
+
+// WithTimeout replaces the DefaultTimeout of each attempt.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Checker) {
+		c.timeout = d
+	}
+}
+
+func New(opts ...Option) *Checker {
+	c := &Checker{
+		client:      &http.Client{},
+		concurrency: DefaultConcurrency,
+		hostDelay:   DefaultHostDelay,
+		retries:     DefaultRetries,
+		timeout:     DefaultTimeout,
+		backoff:     time.Second,
+		hosts:       map[string]time.Time{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.concurrency < 1 {
+		c.concurrency = 1
+	}
+	c.slots = make(chan struct{}, c.concurrency)
+	// Redirects are followed one by one to tell permanent ones apart.
+	c.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return c
+}
+
+// CheckAll checks the links of posts and returns their results in the order of posts.
+func (c *Checker) CheckAll(ctx context.Context, posts []Post) []Result {
+	results := make([]Result, len(posts))
+	var wg sync.WaitGroup
+	for i, p := range posts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := c.Check(ctx, p.URL)
+			r.Path = p.Path
+			results[i] = r
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Check checks a link, retrying it when it could not be checked.
+func (c *Checker) Check(ctx context.Context, rawURL string) Result {
+	var r Result
+	for attempt := 1; ; attempt++ {
+		r = c.check(ctx, rawURL)
+		r.Attempts = attempt
+		if r.Status != StatusError || attempt > c.retries || ctx.Err() != nil {
+			return r
+		}
+		select {
+		case <-ctx.Done():
+			return r
+		case <-time.After(c.backoff * time.Duration(attempt)):
+		}
+	}
+}
+
+func (c *Checker) check(ctx context.Context, rawURL string) Result {
+	r := Result{URL: rawURL}
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		r.Status, r.Error = StatusDead, "malformed link"
+		return r
+	}
+
+	permanent := true
+	for hop := 0; ; hop++ {
+		if hop == maxRedirects {
+			r.Status, r.Error = StatusError, "too many redirects"
+			return r
+		}
+		resp, err := c.get(ctx, u)
+		if err != nil {
+			r.Error = err.Error()
+			r.Status = StatusError
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+				r.Status = StatusDead
+			}
+			return r
+		}
+		r.Code = resp.StatusCode
+
+		if isRedirect(resp.StatusCode) {
+			loc, err := resp.Location()
+			if err != nil {
+				r.Status, r.Error = StatusError, "redirect without location"
+				return r
+			}
+			if resp.StatusCode != http.StatusMovedPermanently && resp.StatusCode != http.StatusPermanentRedirect {
+				permanent = false
+			}
+			u = loc
+			continue
+		}
+
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusGone,
+			resp.StatusCode == http.StatusUnavailableForLegalReasons:
+			r.Status = StatusDead
+			return r
+		default:
+			r.Status, r.Error = StatusError, "unexpected status "+resp.Status
+			return r
+		}
+
+		final := u.String()
+		switch {
+		case sameLink(rawURL, final):
+			r.Status = StatusOK
+		case isHome(u) && !isHome(mustParse(rawURL)):
+			r.Status, r.FinalURL, r.Permanent = StatusChanged, final, permanent
+		default:
+			r.Status, r.FinalURL, r.Permanent = StatusRedirected, final, permanent
+		}
+		return r
+	}
+}
+
+// get requests u once its host is not rate limited anymore and a request slot is free.
+// Slots are only taken once the host can be requested, links waiting for a busy host
+// do not hold up the links of other hosts.
+func (c *Checker) get(ctx context.Context, u *url.URL) (*http.Response, error) {
+	if err := c.wait(ctx, u.Host); err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case c.slots <- struct{}{}:
+	}
+	defer func() { <-c.slots }()
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// Some sites reject requests without the headers of a browser.
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	req.Header.Set("User-Agent", "positronic-linkcheck (+https://github.com/seriousben/positronic-blogger)")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodySize))
+	resp.Body.Close()
+	return resp, nil
+}
+
+// wait blocks until a request can be made to host.
+func (c *Checker) wait(ctx context.Context, host string) error {
+	c.mu.Lock()
+	now := time.Now()
+	at := c.hosts[host]
+	if at.Before(now) {
+		at = now
+	}
+	c.hosts[host] = at.Add(c.hostDelay)
+	c.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(at)):
+		return nil
+	}
+}
+
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// sameLink reports whether two links are the same page,
+// ignoring the scheme, a www. prefix and a trailing slash.
+func sameLink(a, b string) bool {
+	key := func(s string) string {
+		u := mustParse(s)
+		host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+		key := host + strings.TrimRight(u.EscapedPath(), "/")
+		if u.RawQuery != "" {
+			key += "?" + u.RawQuery
+		}
+		return key
+	}
+	return key(a) == key(b)
+}
+
+func isHome(u *url.URL) bool {
+	return strings.Trim(u.Path, "/") == "" && u.RawQuery == ""
+}
+
+func mustParse(s string) *url.URL {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return &url.URL{}
+	}
+	return u
+}
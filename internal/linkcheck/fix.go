@@ -0,0 +1,124 @@
+package linkcheck
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/seriousben/positronic-blogger/internal/archive"
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"github.com/seriousben/positronic-blogger/internal/template"
+)
+
+// Fixer proposes fixes for rotten links in a pull request.
+type Fixer struct {
+	Repository backend.Repository
+	// Archiver looks up the snapshots added to the posts of dead and changed links.
+	// Those posts are left as they are when nil.
+	Archiver *archive.Archiver
+	Identity backend.Identity
+}
+
+// Fix updates posts according to their results, on the branch branchName, and opens a pull
+// request that is left for review. Links permanently redirected are replaced by where they
+// redirect to, dead and changed links get their most recent snapshot as archiveUrl.
+// It returns the paths of the posts fixed, no pull request is opened when there are none.
+func (f *Fixer) Fix(ctx context.Context, branchName string, posts []Post, results []Result) ([]string, error) {
+	byPath := make(map[string]Post, len(posts))
+	for _, p := range posts {
+		byPath[p.Path] = p
+	}
+
+	var (
+		files []backend.File
+		lines []string
+	)
+	for _, r := range results {
+		p, ok := byPath[r.Path]
+		if !ok {
+			continue
+		}
+		content, change, err := f.fix(ctx, p, r)
+		if err != nil {
+			log.Printf("linkcheck: fixing %s: %v", p.Path, err)
+			continue
+		}
+		if change == "" {
+			continue
+		}
+		files = append(files, backend.File{Path: p.Path, Content: content})
+		lines = append(lines, fmt.Sprintf("- `%s`: %s", p.Path, change))
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	brc, err := f.Repository.OpenBranch(ctx, branchName, f.Identity)
+	if err != nil {
+		return nil, fmt.Errorf("opening branch: %w", err)
+	}
+	if err := brc.CommitFiles(ctx, fmt.Sprintf("auto: fix %d rotten links", len(files)), files); err != nil {
+		return nil, fmt.Errorf("committing fixes: %w", err)
+	}
+	body := "Links found rotten by positronic-linkcheck:\n\n" + strings.Join(lines, "\n")
+	if err := brc.Publish(ctx, fmt.Sprintf("Fix %d rotten links", len(files)), body, false); err != nil {
+		return nil, fmt.Errorf("publishing fixes: %w", err)
+	}
+
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+	return paths, nil
+}
+
+// fix returns the fixed content of p and a description of the change, empty when there is none.
+func (f *Fixer) fix(ctx context.Context, p Post, r Result) (string, string, error) {
+	switch r.Status {
+	case StatusRedirected:
+		if !r.Permanent {
+			return "", "", nil
+		}
+		content, err := template.SetFrontMatterField(p.Content, "originalUrl", r.FinalURL)
+		if err != nil {
+			return "", "", err
+		}
+		// The link can also be in the body, such as in a "Read the article" line.
+		return replaceLink(content, r.URL, r.FinalURL), fmt.Sprintf("%s moved to %s", r.URL, r.FinalURL), nil
+	case StatusDead, StatusChanged:
+		if f.Archiver == nil {
+			return "", "", nil
+		}
+		_, fm, err := template.ParseFrontMatter(p.Content)
+		if err != nil {
+			return "", "", err
+		}
+		if existing, _ := fm["archiveUrl"].(string); existing != "" {
+			return "", "", nil
+		}
+		snapshot, err := f.Archiver.Lookup(ctx, r.URL)
+		if err != nil || snapshot == "" {
+			return "", "", err
+		}
+		content, err := template.SetFrontMatterField(p.Content, "archiveUrl", snapshot)
+		if err != nil {
+			return "", "", err
+		}
+		return content, fmt.Sprintf("%s is %s, archived at %s", r.URL, r.Status, snapshot), nil
+	default:
+		return "", "", nil
+	}
+}
+
+// linkDelimiters surround links in Markdown and HTML, as in [text](link), <link> and href="link".
+var linkDelimiters = [][2]string{{"(", ")"}, {"<", ">"}, {`"`, `"`}, {"'", "'"}}
+
+// replaceLink replaces the links to old by links to new in content.
+// Only whole links are replaced, leaving alone the links old is a prefix of and old in plain text.
+func replaceLink(content, old, new string) string {
+	for _, d := range linkDelimiters {
+		content = strings.ReplaceAll(content, d[0]+old+d[1], d[0]+new+d[1])
+	}
+	return content
+}
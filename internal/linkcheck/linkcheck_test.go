@@ -0,0 +1,230 @@
+package linkcheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/archive"
+	"github.com/seriousben/positronic-blogger/internal/github"
+	"github.com/seriousben/positronic-blogger/internal/github/githubtest"
+	"gotest.tools/v3/assert"
+)
+
+// newSite starts a site whose pages have rotten in all the ways links rot.
+func newSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	var flaky atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "home")
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+	mux.HandleFunc("/slash", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/slash/", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/slash/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "slash")
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/moving", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/moving", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusPermanentRedirect)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "new")
+	})
+	mux.HandleFunc("/temporary", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusFound)
+	})
+	mux.HandleFunc("/vanished", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	})
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if flaky.Add(1) == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "flaky")
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestChecker(opts ...Option) *Checker {
+	c := New(append([]Option{WithHostDelay(0)}, opts...)...)
+	c.backoff = time.Millisecond
+	return c
+}
+
+func Test_Checker_Check(t *testing.T) {
+	ctx := context.Background()
+	site := newSite(t)
+	c := newTestChecker(WithHTTPClient(site.Client()))
+
+	for _, tc := range []struct {
+		path string
+		want Result
+	}{
+		{"/ok", Result{Status: StatusOK, Code: 200, Attempts: 1}},
+		{"/slash", Result{Status: StatusOK, Code: 200, Attempts: 1}},
+		{"/moved", Result{Status: StatusRedirected, Code: 200, FinalURL: site.URL + "/new", Permanent: true, Attempts: 1}},
+		{"/temporary", Result{Status: StatusRedirected, Code: 200, FinalURL: site.URL + "/new", Attempts: 1}},
+		{"/vanished", Result{Status: StatusChanged, Code: 200, FinalURL: site.URL + "/", Permanent: true, Attempts: 1}},
+		{"/gone", Result{Status: StatusDead, Code: 410, Attempts: 1}},
+		{"/missing", Result{Status: StatusDead, Code: 404, Attempts: 1}},
+		{"/flaky", Result{Status: StatusOK, Code: 200, Attempts: 2}},
+		{"/broken", Result{Status: StatusError, Code: 500, Error: "unexpected status 500 Internal Server Error", Attempts: 3}},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			tc.want.URL = site.URL + tc.path
+			assert.DeepEqual(t, c.Check(ctx, site.URL+tc.path), tc.want)
+		})
+	}
+
+	r := c.Check(ctx, "mailto:someone@example.com")
+	assert.Equal(t, r.Status, StatusDead)
+}
+
+func Test_Checker_HostDelay(t *testing.T) {
+	site := newSite(t)
+	c := New(WithHTTPClient(site.Client()), WithHostDelay(50*time.Millisecond), WithConcurrency(3))
+
+	start := time.Now()
+	results := c.CheckAll(context.Background(), []Post{
+		{Path: "a.md", URL: site.URL + "/ok"},
+		{Path: "b.md", URL: site.URL + "/ok"},
+		{Path: "c.md", URL: site.URL + "/ok"},
+	})
+	assert.Assert(t, time.Since(start) >= 100*time.Millisecond)
+	for i, r := range results {
+		assert.Equal(t, r.Path, string(rune('a'+i))+".md")
+		assert.Equal(t, r.Status, StatusOK)
+	}
+}
+
+func Test_Report(t *testing.T) {
+	report := NewReport([]Result{
+		{Path: "ok.md", URL: "https://example.com/ok", Status: StatusOK, Code: 200, Attempts: 1},
+		{Path: "moved.md", URL: "https://example.com/moved", Status: StatusRedirected, Code: 200, FinalURL: "https://example.com/new", Permanent: true, Attempts: 1},
+		{Path: "gone.md", URL: "https://example.com/gone", Status: StatusDead, Code: 410, Attempts: 1},
+	})
+
+	buf := new(bytes.Buffer)
+	assert.NilError(t, report.WriteText(buf))
+	assert.Equal(t, buf.String(), `3 links checked: 1 ok, 1 dead, 0 changed, 1 redirected, 0 errors
+
+dead:
+  gone.md  https://example.com/gone  HTTP 410
+
+redirected:
+  moved.md  https://example.com/moved  → https://example.com/new (permanent)
+`)
+
+	buf.Reset()
+	assert.NilError(t, report.WriteJSON(buf))
+	assert.Assert(t, strings.Contains(buf.String(), `"counts": {
+    "dead": 1,
+    "ok": 1,
+    "redirected": 1
+  }`), buf.String())
+	assert.Assert(t, !strings.Contains(buf.String(), "ok.md"))
+}
+
+func Test_Fixer_Fix(t *testing.T) {
+	ctx := context.Background()
+	site := newSite(t)
+	wayback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"archived_snapshots": {"closest": {"available": true,
+		"url": "http://web.archive.org/web/20200101000000/`+r.URL.Query().Get("url")+`"}}}`)
+	}))
+	defer wayback.Close()
+
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+	repo, err := github.New(ctx, "token", srv.Owner, srv.Repo,
+		github.WithBaseURL(srv.BaseURL()),
+		github.WithHTTPClient(srv.Client()),
+		github.WithRateLimit(time.Millisecond),
+	)
+	assert.NilError(t, err)
+
+	post := func(url string) string {
+		return "+++\ntitle = \"A post\"\noriginalUrl = \"" + url + "\"\n+++\n\n[Read the article](" + url + ")\n"
+	}
+	srv.SetFile("main", "content/links/ok.md", post(site.URL+"/ok"))
+	srv.SetFile("main", "content/links/moved.md", post(site.URL+"/moved"))
+	srv.SetFile("main", "content/links/temporary.md", post(site.URL+"/temporary"))
+	srv.SetFile("main", "content/links/gone.md", post(site.URL+"/gone"))
+	srv.SetFile("main", "content/links/index.json", `{"not": "a post"}`)
+
+	posts, err := Posts(ctx, repo, "content/links")
+	assert.NilError(t, err)
+	assert.Equal(t, len(posts), 4)
+
+	results := newTestChecker(WithHTTPClient(site.Client())).CheckAll(ctx, posts)
+	fixer := &Fixer{
+		Repository: repo,
+		Archiver:   archive.New(archive.WithWayback(wayback.URL), archive.WithHTTPClient(wayback.Client())),
+	}
+	fixed, err := fixer.Fix(ctx, "linkcheck", posts, results)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, fixed, []string{"content/links/gone.md", "content/links/moved.md"})
+
+	moved, _ := srv.File("linkcheck", "content/links/moved.md")
+	assert.Equal(t, moved, post(site.URL+"/new"))
+	gone, _ := srv.File("linkcheck", "content/links/gone.md")
+	assert.Equal(t, gone, "+++\ntitle = \"A post\"\noriginalUrl = \""+site.URL+"/gone\"\n"+
+		"archiveUrl = \"http://web.archive.org/web/20200101000000/"+site.URL+"/gone\"\n+++\n\n[Read the article]("+site.URL+"/gone)\n")
+	temporary, _ := srv.File("linkcheck", "content/links/temporary.md")
+	assert.Equal(t, temporary, post(site.URL+"/temporary"))
+
+	prs := srv.PullRequests()
+	assert.Equal(t, len(prs), 1)
+	assert.Equal(t, prs[0].Title, "Fix 2 rotten links")
+	assert.Assert(t, !prs[0].Merged)
+
+	// Nothing is left to fix once archived.
+	srv.SetFile("main", "content/links/gone.md", gone)
+	posts, err = Posts(ctx, repo, "content/links")
+	assert.NilError(t, err)
+	fixed, err = fixer.Fix(ctx, "linkcheck-again", posts[:1], results[:1])
+	assert.NilError(t, err)
+	assert.Equal(t, len(fixed), 0)
+}
+
+func Test_Fixer_fix_PrefixURL(t *testing.T) {
+	content := "+++\n" +
+		"originalUrl = \"https://a.example/x\"\n" +
+		"comment = \"Unlike https://a.example/x2, https://a.example/x is short.\"\n" +
+		"+++\n\n" +
+		"[Read the article](https://a.example/x), not [the sequel](https://a.example/x2) or <https://a.example/x/more>.\n" +
+		"<a href=\"https://a.example/x\">again</a>\n"
+	r := Result{URL: "https://a.example/x", FinalURL: "https://b.example/y", Status: StatusRedirected, Permanent: true}
+
+	fixed, change, err := (&Fixer{}).fix(context.Background(), Post{Path: "content/links/x.md", Content: content}, r)
+	assert.NilError(t, err)
+	assert.Equal(t, change, "https://a.example/x moved to https://b.example/y")
+	assert.Equal(t, fixed, "+++\n"+
+		"originalUrl = \"https://b.example/y\"\n"+
+		"comment = \"Unlike https://a.example/x2, https://a.example/x is short.\"\n"+
+		"+++\n\n"+
+		"[Read the article](https://b.example/y), not [the sequel](https://a.example/x2) or <https://a.example/x/more>.\n"+
+		"<a href=\"https://b.example/y\">again</a>\n")
+}
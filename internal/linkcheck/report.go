@@ -0,0 +1,79 @@
+package linkcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// reported are the statuses detailed in reports, in order.
+var reported = []Status{StatusDead, StatusChanged, StatusRedirected, StatusError}
+
+// Report is a summary of the results of a check.
+type Report struct {
+	Checked int            `json:"checked"`
+	Counts  map[Status]int `json:"counts"`
+	// Results are the results of the links that are not ok.
+	Results []Result `json:"results"`
+}
+
+// NewReport summarizes results.
+func NewReport(results []Result) *Report {
+	r := &Report{Checked: len(results), Counts: map[Status]int{}, Results: []Result{}}
+	for _, res := range results {
+		r.Counts[res.Status]++
+	}
+	for _, s := range reported {
+		for _, res := range results {
+			if res.Status == s {
+				r.Results = append(r.Results, res)
+			}
+		}
+	}
+	return r
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes the report for humans, grouped by status.
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%d links checked: %d ok, %d dead, %d changed, %d redirected, %d errors\n",
+		r.Checked, r.Counts[StatusOK], r.Counts[StatusDead], r.Counts[StatusChanged], r.Counts[StatusRedirected], r.Counts[StatusError])
+	for _, s := range reported {
+		if r.Counts[s] == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\n%s:\n", s)
+		for _, res := range r.Results {
+			if res.Status != s {
+				continue
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", res.Path, res.URL, detail(res))
+		}
+	}
+	return tw.Flush()
+}
+
+func detail(r Result) string {
+	switch r.Status {
+	case StatusRedirected, StatusChanged:
+		if r.Permanent {
+			return "→ " + r.FinalURL + " (permanent)"
+		}
+		return "→ " + r.FinalURL
+	case StatusDead:
+		if r.Code != 0 {
+			return fmt.Sprintf("HTTP %d", r.Code)
+		}
+		return r.Error
+	default:
+		return fmt.Sprintf("%s (%d attempts)", r.Error, r.Attempts)
+	}
+}
@@ -0,0 +1,372 @@
+// Package linkpreview fetches the Open Graph, Twitter card and <meta> metadata
+// pages describe themselves with, to enrich posts linking to them.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/template"
+	"golang.org/x/net/html"
+)
+
+const (
+	// DefaultTimeout bounds the time spent fetching a page.
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxSize bounds how much of a page is read, metadata is in its head.
+	DefaultMaxSize = 512 << 10
+	// DefaultCacheTTL is how long previews, and pages without one, are cached.
+	DefaultCacheTTL = 24 * time.Hour
+)
+
+// Preview is the metadata of a page.
+type Preview struct {
+	Title       string
+	SiteName    string
+	Description string
+	// Image is the absolute URL of the image of the page.
+	Image     string
+	Author    string
+	Published time.Time
+	// Language is a BCP 47 language tag, such as en or en-US.
+	Language string
+}
+
+// Cache stores the previews of pages by URL.
+// A nil preview records that the page has none, such as a missing page.
+type Cache interface {
+	Get(url string) (p *Preview, ok bool)
+	Set(url string, p *Preview)
+}
+
+// MemoryCache is a Cache keeping previews in memory for a limited time.
+type MemoryCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	preview *Preview
+	expires time.Time
+}
+
+// NewMemoryCache returns a cache keeping previews for ttl.
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{ttl: ttl, now: time.Now, entries: map[string]cacheEntry{}}
+}
+
+func (c *MemoryCache) Get(url string) (*Preview, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[url]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(e.expires) {
+		delete(c.entries, url)
+		return nil, false
+	}
+	return e.preview, true
+}
+
+func (c *MemoryCache) Set(url string, p *Preview) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[url] = cacheEntry{preview: p, expires: c.now().Add(c.ttl)}
+}
+
+// Fetcher fetches the previews of pages.
+type Fetcher struct {
+	client  *http.Client
+	timeout time.Duration
+	maxSize int64
+	cache   Cache
+}
+
+type Option func(*Fetcher)
+
+// WithHTTPClient sets the HTTP client used to fetch pages.
+func WithHTTPClient(client *http.Client) Option {
+	return func(f *Fetcher) {
+		f.client = client
+	}
+}
+
+// WithTimeout replaces the DefaultTimeout of fetching a page.
+func WithTimeout(d time.Duration) Option {
+	return func(f *Fetcher) {
+		f.timeout = d
+	}
+}
+
+// WithMaxSize replaces the DefaultMaxSize of the part of a page read.
+func WithMaxSize(n int64) Option {
+	return func(f *Fetcher) {
+		f.maxSize = n
+	}
+}
+
+// WithCache replaces the default memory cache, caching is disabled when c is nil.
+func WithCache(c Cache) Option {
+	return func(f *Fetcher) {
+		f.cache = c
+	}
+}
+
+func New(opts ...Option) *Fetcher {
+	f := &Fetcher{
+		client:  http.DefaultClient,
+		timeout: DefaultTimeout,
+		maxSize: DefaultMaxSize,
+		cache:   NewMemoryCache(DefaultCacheTTL),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Fetch returns the preview of the page at rawURL.
+// Pages that are not HTML have an empty preview.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	if f.cache != nil {
+		if p, ok := f.cache.Get(rawURL); ok {
+			if p == nil {
+				return nil, fmt.Errorf("no preview of %s (cached)", rawURL)
+			}
+			return p, nil
+		}
+	}
+
+	p, err := f.fetch(ctx, rawURL)
+	// Timeouts and unavailable servers say nothing about the page, the next fetch may succeed.
+	if f.cache != nil && (err == nil || definitive(err)) {
+		f.cache.Set(rawURL, p)
+	}
+	return p, err
+}
+
+// statusError is the unexpected status of the response of a page.
+type statusError struct {
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %s", e.status)
+}
+
+// definitive reports whether fetching a page failed for a reason that holds on the next fetch,
+// such as a missing page, rather than a transient one, such as a timeout or an unavailable server.
+func definitive(err error) bool {
+	var se *statusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	switch se.code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return se.code < 500
+}
+
+func (f *Fetcher) fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{status: resp.Status, code: resp.StatusCode}
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "html") {
+		return &Preview{}, nil
+	}
+
+	m := parseHead(io.LimitReader(resp.Body, f.maxSize))
+	if m.lang == "" {
+		m.lang = resp.Header.Get("Content-Language")
+	}
+	return m.preview(resp.Request.URL), nil
+}
+
+// Enrich fills the empty metadata fields of a post with the preview of the page it links to.
+// Failures are logged and leave the post as it is, a page without preview is never a reason
+// not to publish a post.
+func (f *Fetcher) Enrich(ctx context.Context, p *template.Post) {
+	pr, err := f.Fetch(ctx, p.URL)
+	if err != nil {
+		log.Printf("linkpreview: fetching %s: %v", p.URL, err)
+		return
+	}
+
+	fill := func(field *string, v string) {
+		if *field == "" {
+			*field = v
+		}
+	}
+	fill(&p.Title, pr.Title)
+	fill(&p.Site, pr.SiteName)
+	fill(&p.Description, pr.Description)
+	fill(&p.Image, pr.Image)
+	fill(&p.Author, pr.Author)
+	fill(&p.Language, pr.Language)
+	if p.Published.IsZero() {
+		p.Published = pr.Published
+	}
+}
+
+// meta holds the metadata found in the head of a page.
+type meta struct {
+	// values holds the first content of each <meta> property or name, lower cased.
+	values map[string]string
+	title  string
+	lang   string
+}
+
+func (m *meta) first(keys ...string) string {
+	for _, k := range keys {
+		if v := strings.TrimSpace(m.values[k]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (m *meta) preview(base *url.URL) *Preview {
+	p := &Preview{
+		Title:       m.first("og:title", "twitter:title"),
+		SiteName:    m.first("og:site_name", "application-name"),
+		Description: m.first("og:description", "twitter:description", "description"),
+		Author:      m.first("author", "article:author", "twitter:creator"),
+		Language:    strings.TrimSpace(m.lang),
+	}
+	if p.Title == "" {
+		p.Title = strings.Join(strings.Fields(m.title), " ")
+	}
+	// article:author is often the URL of a profile rather than a name.
+	if strings.HasPrefix(p.Author, "http://") || strings.HasPrefix(p.Author, "https://") {
+		p.Author = m.first("author", "twitter:creator")
+	}
+	if img := m.first("og:image:secure_url", "og:image", "og:image:url", "twitter:image", "twitter:image:src"); img != "" {
+		if iu, err := base.Parse(img); err == nil && (iu.Scheme == "http" || iu.Scheme == "https") {
+			p.Image = iu.String()
+		}
+	}
+	if p.Language == "" {
+		// Open Graph locales use underscores, such as en_US.
+		p.Language = strings.ReplaceAll(m.first("og:locale", "content-language"), "_", "-")
+	}
+	if d := m.first("article:published_time", "og:published_time", "datepublished", "date", "dc.date", "pubdate"); d != "" {
+		p.Published = parseDate(d)
+	}
+	return p
+}
+
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+func parseDate(s string) time.Time {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseHead returns the metadata of the head of a page.
+func parseHead(r io.Reader) *meta {
+	m := &meta{values: map[string]string{}}
+	z := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return m
+		case html.TextToken:
+			if inTitle && m.title == "" {
+				m.title = string(z.Text())
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "head":
+				return m
+			case "title":
+				inTitle = false
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				attrs[string(k)] = string(v)
+			}
+			switch string(name) {
+			case "body":
+				return m
+			case "html":
+				m.lang = attrs["lang"]
+			case "title":
+				inTitle = tt == html.StartTagToken
+			case "meta":
+				key := attrs["property"]
+				if key == "" {
+					key = attrs["name"]
+				}
+				if key == "" {
+					key = attrs["itemprop"]
+				}
+				if key == "" {
+					key = attrs["http-equiv"]
+				}
+				key = strings.ToLower(strings.TrimSpace(key))
+				if _, ok := m.values[key]; key != "" && !ok {
+					m.values[key] = attrs["content"]
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package linkpreview
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/template"
+	"gotest.tools/v3/assert"
+)
+
+const articlePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Fallback title | Example</title>
+<meta property="og:title" content="Go Generics in Practice">
+<meta property="og:site_name" content="Example Blog">
+<meta property="og:description" content="Type parameters &amp; constraints, explained.">
+<meta property="og:image" content="/images/generics.png">
+<meta property="og:locale" content="fr_CA">
+<meta property="article:author" content="https://example.com/authors/jane">
+<meta name="author" content="Jane Doe">
+<meta property="article:published_time" content="2022-03-05T09:00:00+01:00">
+<meta name="twitter:title" content="Ignored, og:title comes first">
+</head>
+<body>
+<meta property="og:description" content="Ignored, in the body">
+</body>
+</html>`
+
+func newTestServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprint(w, articlePage)
+	})
+	mux.HandleFunc("/twitter", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Language", "de")
+		fmt.Fprint(w, `<html><head>
+<title>
+  Plain   title
+</title>
+<meta name="twitter:description" content="From the card.">
+<meta name="twitter:image" content="https://cdn.example.com/card.jpg">
+<meta name="twitter:creator" content="@jane">
+<meta name="date" content="2021-12-31">
+</head></html>`)
+	})
+	mux.HandleFunc("/paper.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		fmt.Fprint(w, "%PDF-1.4")
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "try again later", http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Huge</title>`+strings.Repeat(`<meta name="x" content="padding">`, 1000)+
+			`<meta property="og:site_name" content="Too far"></head></html>`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func Test_Fetch(t *testing.T) {
+	srv, _ := newTestServer(t)
+	f := New(WithHTTPClient(srv.Client()))
+	ctx := context.Background()
+
+	p, err := f.Fetch(ctx, srv.URL+"/article")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, p, &Preview{
+		Title:       "Go Generics in Practice",
+		SiteName:    "Example Blog",
+		Description: "Type parameters & constraints, explained.",
+		Image:       srv.URL + "/images/generics.png",
+		Author:      "Jane Doe",
+		Published:   time.Date(2022, 3, 5, 9, 0, 0, 0, time.FixedZone("", 3600)),
+		Language:    "en",
+	})
+
+	p, err = f.Fetch(ctx, srv.URL+"/twitter")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, p, &Preview{
+		Title:       "Plain title",
+		Description: "From the card.",
+		Image:       "https://cdn.example.com/card.jpg",
+		Author:      "@jane",
+		Published:   time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC),
+		Language:    "de",
+	})
+
+	p, err = f.Fetch(ctx, srv.URL+"/paper.pdf")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, p, &Preview{})
+
+	_, err = f.Fetch(ctx, "mailto:jane@example.com")
+	assert.ErrorContains(t, err, "unsupported scheme")
+}
+
+func Test_Fetch_Limits(t *testing.T) {
+	srv, _ := newTestServer(t)
+	f := New(WithHTTPClient(srv.Client()), WithTimeout(50*time.Millisecond), WithMaxSize(4096))
+	ctx := context.Background()
+
+	start := time.Now()
+	_, err := f.Fetch(ctx, srv.URL+"/slow")
+	assert.ErrorContains(t, err, "context deadline exceeded")
+	assert.Assert(t, time.Since(start) < 2*time.Second)
+
+	p, err := f.Fetch(ctx, srv.URL+"/huge")
+	assert.NilError(t, err)
+	assert.Equal(t, p.Title, "Huge")
+	assert.Equal(t, p.SiteName, "")
+}
+
+func Test_Fetch_Cache(t *testing.T) {
+	srv, hits := newTestServer(t)
+	cache := NewMemoryCache(time.Hour)
+	now := time.Date(2022, 3, 5, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	f := New(WithHTTPClient(srv.Client()), WithCache(cache))
+	ctx := context.Background()
+
+	for range 2 {
+		_, err := f.Fetch(ctx, srv.URL+"/article")
+		assert.NilError(t, err)
+		_, err = f.Fetch(ctx, srv.URL+"/missing")
+		assert.Assert(t, err != nil)
+	}
+	assert.Equal(t, hits.Load(), int32(2))
+
+	now = now.Add(time.Hour)
+	_, err := f.Fetch(ctx, srv.URL+"/article")
+	assert.NilError(t, err)
+	assert.Equal(t, hits.Load(), int32(3))
+
+	// Transient failures are not cached.
+	hits.Store(0)
+	f = New(WithHTTPClient(srv.Client()), WithCache(cache), WithTimeout(50*time.Millisecond))
+	for range 2 {
+		_, err = f.Fetch(ctx, srv.URL+"/unavailable")
+		assert.ErrorContains(t, err, "unexpected status 503")
+		_, err = f.Fetch(ctx, srv.URL+"/slow")
+		assert.ErrorContains(t, err, "context deadline exceeded")
+	}
+	assert.Equal(t, hits.Load(), int32(4))
+}
+
+func Test_Enrich(t *testing.T) {
+	srv, _ := newTestServer(t)
+	f := New(WithHTTPClient(srv.Client()))
+	ctx := context.Background()
+
+	p := template.Post{Title: "My title", URL: srv.URL + "/article", Author: "From the source"}
+	f.Enrich(ctx, &p)
+	assert.Equal(t, p.Title, "My title")
+	assert.Equal(t, p.Author, "From the source")
+	assert.Equal(t, p.Site, "Example Blog")
+	assert.Equal(t, p.Description, "Type parameters & constraints, explained.")
+	assert.Equal(t, p.Image, srv.URL+"/images/generics.png")
+	assert.Equal(t, p.Language, "en")
+	assert.Assert(t, !p.Published.IsZero())
+
+	// Failures leave the post as it is.
+	p = template.Post{Title: "Missing", URL: srv.URL + "/missing"}
+	f.Enrich(ctx, &p)
+	assert.DeepEqual(t, p, template.Post{Title: "Missing", URL: srv.URL + "/missing"})
+}
@@ -0,0 +1,386 @@
+package localgit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/seriousben/positronic-blogger/internal/backend"
+)
+
+const (
+	fallbackName  = "positronic-blogger"
+	fallbackEmail = "positronic-blogger@localhost"
+)
+
+var (
+	_ backend.Repository = (*Repository)(nil)
+	_ backend.Branch     = (*Branch)(nil)
+)
+
+// Repository publishes posts to a local git working tree or bare repository
+// using the git command line.
+type Repository struct {
+	dir        string
+	bare       bool
+	baseBranch string
+	env        []string
+}
+
+// Option configures a Repository.
+type Option func(*Repository)
+
+// WithBaseBranch sets the branch posts are read from and merged into.
+// By default, the branch HEAD points to is used.
+func WithBaseBranch(branch string) Option {
+	return func(r *Repository) {
+		r.baseBranch = branch
+	}
+}
+
+// Open opens the git repository at dir. dir can be a working tree or a bare repository.
+func Open(ctx context.Context, dir string, opts ...Option) (*Repository, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolving repository path: %w", err)
+	}
+
+	r := &Repository{
+		dir: abs,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	bare, err := r.git(ctx, nil, "rev-parse", "--is-bare-repository")
+	if err != nil {
+		return nil, fmt.Errorf("opening git repository %s: %w", abs, err)
+	}
+	r.bare = bare == "true"
+
+	if r.baseBranch == "" {
+		head, err := r.git(ctx, nil, "symbolic-ref", "--quiet", "--short", "HEAD")
+		if err != nil {
+			return nil, fmt.Errorf("detecting default branch of %s: %w", abs, err)
+		}
+		r.baseBranch = head
+	}
+
+	// Commits are created with plumbing commands that fail without an identity,
+	// fallback on a generic one when the repository has none configured.
+	if _, err := r.git(ctx, nil, "var", "GIT_COMMITTER_IDENT"); err != nil {
+		r.env = append(r.env,
+			"GIT_AUTHOR_NAME="+fallbackName,
+			"GIT_AUTHOR_EMAIL="+fallbackEmail,
+			"GIT_COMMITTER_NAME="+fallbackName,
+			"GIT_COMMITTER_EMAIL="+fallbackEmail,
+		)
+	}
+
+	return r, nil
+}
+
+// BaseBranch returns the branch posts are read from and merged into.
+func (r *Repository) BaseBranch() string {
+	return r.baseBranch
+}
+
+func (r *Repository) git(ctx context.Context, stdin []byte, args ...string) (string, error) {
+	return r.gitEnv(ctx, nil, stdin, args...)
+}
+
+func (r *Repository) gitEnv(ctx context.Context, env []string, stdin []byte, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", r.dir}, args...)...)
+	cmd.Env = append(append(os.Environ(), r.env...), env...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+// resolve returns the object name of rev or an empty string when it does not exist.
+func (r *Repository) resolve(ctx context.Context, rev string) (string, error) {
+	out, err := r.git(ctx, nil, "rev-parse", "--verify", "--quiet", rev)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", err
+	}
+	return out, nil
+}
+
+func (r *Repository) GetContent(ctx context.Context, path string) (content string, sha string, err error) {
+	return r.getContent(ctx, "refs/heads/"+r.baseBranch, path)
+}
+
+func (r *Repository) getContent(ctx context.Context, ref, path string) (string, string, error) {
+	sha, err := r.resolve(ctx, fmt.Sprintf("%s:%s", ref, filepath.ToSlash(path)))
+	if err != nil {
+		return "", "", err
+	}
+	if sha == "" {
+		return "", "", fmt.Errorf("file not found (%s): %w", path, backend.ErrFileNotFound)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "-C", r.dir, "cat-file", "blob", sha)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("git cat-file: %w", err)
+	}
+	return string(out), sha, nil
+}
+
+func (r *Repository) ListFiles(ctx context.Context, dir string) ([]backend.File, error) {
+	prefix := strings.Trim(filepath.ToSlash(dir), "/")
+	treeish := fmt.Sprintf("refs/heads/%s:%s", r.baseBranch, prefix)
+	sha, err := r.resolve(ctx, treeish)
+	if err != nil {
+		return nil, err
+	}
+	if sha == "" {
+		return nil, nil
+	}
+
+	out, err := r.git(ctx, nil, "ls-tree", "-r", "-z", treeish)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []backend.File
+	for _, entry := range strings.Split(out, "\x00") {
+		// <mode> SP <type> SP <object> TAB <file>
+		meta, name, ok := strings.Cut(entry, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 3 || fields[1] != "blob" {
+			continue
+		}
+		filePath := path.Join(prefix, name)
+		content, blobSHA, err := r.getContent(ctx, "refs/heads/"+r.baseBranch, filePath)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, backend.File{Path: filePath, Content: content, SHA: blobSHA})
+	}
+	return files, nil
+}
+
+func (r *Repository) OpenBranch(ctx context.Context, branchName string, id backend.Identity) (backend.Branch, error) {
+	b, err := r.StartBranch(ctx, branchName)
+	if err != nil {
+		return nil, err
+	}
+	b.SetIdentity(id)
+	return b, nil
+}
+
+// StartBranch creates a branch pointing to the base branch.
+// An existing branch is reused when it still points to the base branch and is recreated otherwise.
+func (r *Repository) StartBranch(ctx context.Context, branchName string) (*Branch, error) {
+	ref := fmt.Sprintf("refs/heads/%s", branchName)
+	baseRef := fmt.Sprintf("refs/heads/%s", r.baseBranch)
+
+	baseSHA, err := r.resolve(ctx, baseRef)
+	if err != nil {
+		return nil, err
+	}
+	if baseSHA == "" {
+		return nil, fmt.Errorf("base branch %s not found", r.baseBranch)
+	}
+
+	existingSHA, err := r.resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	switch existingSHA {
+	case baseSHA:
+		log.Printf("Branch %s already exists at current %s, reusing it", branchName, r.baseBranch)
+	case "":
+	default:
+		log.Printf("Branch %s exists but is stale, recreating", branchName)
+	}
+
+	if existingSHA != baseSHA {
+		if _, err := r.git(ctx, nil, "update-ref", ref, baseSHA); err != nil {
+			return nil, fmt.Errorf("creating branch %s: %w", branchName, err)
+		}
+	}
+
+	return &Branch{
+		repo:       r,
+		branchName: branchName,
+		branchRef:  ref,
+		baseRef:    baseRef,
+	}, nil
+}
+
+// Branch is a branch of a local git repository.
+// Files are committed directly to the branch without touching the working tree.
+type Branch struct {
+	repo       *Repository
+	branchName string
+	branchRef  string
+	baseRef    string
+	identity   backend.Identity
+}
+
+// SetIdentity sets who the commits of the branch are attributed to.
+// Without identity, commits are attributed following the repository git config.
+func (b *Branch) SetIdentity(id backend.Identity) {
+	b.identity = id
+}
+
+// identityEnv returns the git environment variables attributing commits to the branch identity.
+func (b *Branch) identityEnv() []string {
+	var env []string
+	if a := b.identity.Author; !a.IsZero() {
+		env = append(env, "GIT_AUTHOR_NAME="+a.Name, "GIT_AUTHOR_EMAIL="+a.Email)
+	}
+	if c := b.identity.EffectiveCommitter(); !c.IsZero() {
+		env = append(env, "GIT_COMMITTER_NAME="+c.Name, "GIT_COMMITTER_EMAIL="+c.Email)
+	}
+	return env
+}
+
+func (b *Branch) CreateFile(ctx context.Context, commitMsg, path, content string) error {
+	_, _, err := b.repo.getContent(ctx, b.branchRef, path)
+	if err == nil {
+		return fmt.Errorf("file %s already exists on branch %s", path, b.branchName)
+	}
+	if !errors.Is(err, backend.ErrFileNotFound) {
+		return err
+	}
+	return b.commitFile(ctx, commitMsg, path, content)
+}
+
+func (b *Branch) UpdateFile(ctx context.Context, commitMsg, path, sha, content string) error {
+	_, curSHA, err := b.repo.getContent(ctx, b.branchRef, path)
+	if err != nil {
+		return err
+	}
+	if curSHA != sha {
+		return fmt.Errorf("file %s on branch %s is at %s, not %s", path, b.branchName, curSHA, sha)
+	}
+	return b.commitFile(ctx, commitMsg, path, content)
+}
+
+func (b *Branch) commitFile(ctx context.Context, commitMsg, path, content string) error {
+	return b.CommitFiles(ctx, commitMsg, []backend.File{{Path: path, Content: content}})
+}
+
+// CommitFiles creates or replaces all files in a single commit on the branch.
+func (b *Branch) CommitFiles(ctx context.Context, commitMsg string, files []backend.File) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	r := b.repo
+
+	parent, err := r.resolve(ctx, b.branchRef)
+	if err != nil {
+		return err
+	}
+	if parent == "" {
+		return fmt.Errorf("branch %s not found", b.branchName)
+	}
+
+	// Build the new tree in a throw-away index so the working tree
+	// and the repository index are never touched.
+	index, err := os.CreateTemp("", "positronic-index-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary index: %w", err)
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	if _, err := r.gitEnv(ctx, env, nil, "read-tree", parent); err != nil {
+		return err
+	}
+	for _, f := range files {
+		blob, err := r.git(ctx, []byte(f.Content), "hash-object", "-w", "--stdin")
+		if err != nil {
+			return err
+		}
+		if _, err := r.gitEnv(ctx, env, nil, "update-index", "--add", "--cacheinfo", fmt.Sprintf("100644,%s,%s", blob, filepath.ToSlash(f.Path))); err != nil {
+			return err
+		}
+	}
+	tree, err := r.gitEnv(ctx, env, nil, "write-tree")
+	if err != nil {
+		return err
+	}
+
+	commit, err := r.gitEnv(ctx, b.identityEnv(), []byte(b.identity.Message(commitMsg)), "commit-tree", tree, "-p", parent)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.git(ctx, nil, "update-ref", b.branchRef, commit, parent); err != nil {
+		return fmt.Errorf("updating branch %s: %w", b.branchName, err)
+	}
+	return nil
+}
+
+// Publish fast-forwards the base branch to the branch when merge is true.
+// Without merge, the branch is left in place for review.
+func (b *Branch) Publish(ctx context.Context, title, body string, merge bool) error {
+	if !merge {
+		log.Printf("Branch %s ready for review: %s", b.branchName, title)
+		return nil
+	}
+
+	r := b.repo
+	if _, err := r.git(ctx, nil, "merge-base", "--is-ancestor", b.baseRef, b.branchRef); err != nil {
+		return fmt.Errorf("branch %s cannot be fast-forwarded onto %s: %w", b.branchName, r.baseBranch, err)
+	}
+
+	head, err := r.git(ctx, nil, "symbolic-ref", "--quiet", "HEAD")
+	if err != nil {
+		head = ""
+	}
+
+	if !r.bare && head == b.baseRef {
+		// The base branch is checked out, update the working tree along with it.
+		if _, err := r.git(ctx, nil, "merge", "--ff-only", b.branchRef); err != nil {
+			return err
+		}
+	} else {
+		baseSHA, err := r.resolve(ctx, b.baseRef)
+		if err != nil {
+			return err
+		}
+		branchSHA, err := r.resolve(ctx, b.branchRef)
+		if err != nil {
+			return err
+		}
+		if _, err := r.git(ctx, nil, "update-ref", b.baseRef, branchSHA, baseSHA); err != nil {
+			return err
+		}
+	}
+
+	return b.DeleteBranch(ctx)
+}
+
+func (b *Branch) DeleteBranch(ctx context.Context) error {
+	_, err := b.repo.git(ctx, nil, "update-ref", "-d", b.branchRef)
+	return err
+}
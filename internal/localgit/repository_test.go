@@ -0,0 +1,164 @@
+package localgit
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"gotest.tools/v3/assert"
+)
+
+func gitInit(t *testing.T, args ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping, git is not installed")
+	}
+
+	dir := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		assert.NilError(t, err, string(out))
+	}
+	run(append([]string{"init", "--initial-branch=main"}, args...)...)
+	if len(args) == 0 {
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme\n"), 0o644))
+		run("add", "README.md")
+		run("commit", "-m", "initial")
+	}
+	return dir
+}
+
+func Test_Repository(t *testing.T) {
+	ctx := context.Background()
+	dir := gitInit(t)
+
+	repo, err := Open(ctx, dir)
+	assert.NilError(t, err)
+
+	content, _, err := repo.GetContent(ctx, "README.md")
+	assert.NilError(t, err)
+	assert.Equal(t, content, "readme\n")
+
+	_, _, err = repo.GetContent(ctx, "file-not-found")
+	assert.ErrorIs(t, err, backend.ErrFileNotFound)
+
+	br, err := repo.OpenBranch(ctx, "test-branch", backend.Identity{})
+	assert.NilError(t, err)
+
+	assert.NilError(t, br.CreateFile(ctx, "adding first file", "content/links/file1.md", "file1"))
+	assert.NilError(t, br.CreateFile(ctx, "adding checkpoint", "content/links/checkpoint", "1"))
+	assert.Assert(t, br.CreateFile(ctx, "adding file1 again", "content/links/file1.md", "file1") != nil)
+
+	// Not visible on main before publishing.
+	_, _, err = repo.GetContent(ctx, "content/links/file1.md")
+	assert.ErrorIs(t, err, backend.ErrFileNotFound)
+
+	assert.NilError(t, br.Publish(ctx, "title", "body", true))
+
+	content, sha, err := repo.GetContent(ctx, "content/links/checkpoint")
+	assert.NilError(t, err)
+	assert.Equal(t, content, "1")
+
+	files, err := repo.ListFiles(ctx, "content")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, files, []backend.File{
+		{Path: "content/links/checkpoint", Content: "1", SHA: sha},
+		{Path: "content/links/file1.md", Content: "file1", SHA: files[1].SHA},
+	})
+
+	files, err = repo.ListFiles(ctx, "missing")
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 0)
+
+	// Working tree follows the checked-out base branch.
+	b, err := os.ReadFile(filepath.Join(dir, "content/links/file1.md"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), "file1")
+
+	br, err = repo.OpenBranch(ctx, "test-branch-2", backend.Identity{})
+	assert.NilError(t, err)
+	assert.Assert(t, br.UpdateFile(ctx, "bad sha", "content/links/checkpoint", "bad", "2") != nil)
+	assert.NilError(t, br.UpdateFile(ctx, "update checkpoint", "content/links/checkpoint", sha, "2"))
+	assert.NilError(t, br.Publish(ctx, "title", "body", false))
+
+	content, _, err = repo.GetContent(ctx, "content/links/checkpoint")
+	assert.NilError(t, err)
+	assert.Equal(t, content, "1")
+
+	assert.NilError(t, br.DeleteBranch(ctx))
+}
+
+func Test_Repository_BaseBranch(t *testing.T) {
+	ctx := context.Background()
+	dir := gitInit(t)
+	out, err := exec.Command("git", "-C", dir, "branch", "gh-pages").CombinedOutput()
+	assert.NilError(t, err, string(out))
+
+	repo, err := Open(ctx, dir, WithBaseBranch("gh-pages"))
+	assert.NilError(t, err)
+	assert.Equal(t, repo.BaseBranch(), "gh-pages")
+
+	br, err := repo.OpenBranch(ctx, "test-branch", backend.Identity{})
+	assert.NilError(t, err)
+	assert.NilError(t, br.CreateFile(ctx, "adding file", "file1.md", "file1"))
+	assert.NilError(t, br.Publish(ctx, "title", "body", true))
+
+	content, _, err := repo.GetContent(ctx, "file1.md")
+	assert.NilError(t, err)
+	assert.Equal(t, content, "file1")
+
+	// main is left untouched.
+	_, err = os.Stat(filepath.Join(dir, "file1.md"))
+	assert.Assert(t, os.IsNotExist(err))
+	out, err = exec.Command("git", "-C", dir, "cat-file", "-e", "main:file1.md").CombinedOutput()
+	assert.Assert(t, err != nil, string(out))
+}
+
+func Test_Repository_Bare(t *testing.T) {
+	ctx := context.Background()
+	src := gitInit(t)
+	dir := filepath.Join(t.TempDir(), "bare.git")
+	out, err := exec.Command("git", "clone", "--bare", src, dir).CombinedOutput()
+	assert.NilError(t, err, string(out))
+
+	repo, err := Open(ctx, dir)
+	assert.NilError(t, err)
+	assert.Assert(t, repo.bare)
+	assert.Equal(t, repo.BaseBranch(), "main")
+
+	br, err := repo.OpenBranch(ctx, "test-branch", backend.Identity{
+		Author:   backend.Signature{Name: "Curator", Email: "curator@example.com"},
+		Trailers: []backend.Trailer{backend.CoAuthor(backend.Signature{Name: "Friend", Email: "friend@example.com"})},
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, br.CreateFile(ctx, "adding file", "file1.md", "file1"))
+	assert.NilError(t, br.CommitFiles(ctx, "adding files", []backend.File{
+		{Path: "file1.md", Content: "file1 updated"},
+		{Path: "dir/file2.md", Content: "file2"},
+	}))
+	assert.NilError(t, br.Publish(ctx, "title", "body", true))
+
+	content, _, err := repo.GetContent(ctx, "file1.md")
+	assert.NilError(t, err)
+	assert.Equal(t, content, "file1 updated")
+
+	content, _, err = repo.GetContent(ctx, "dir/file2.md")
+	assert.NilError(t, err)
+	assert.Equal(t, content, "file2")
+
+	out, err = exec.Command("git", "-C", dir, "rev-list", "--count", "main").CombinedOutput()
+	assert.NilError(t, err, string(out))
+	assert.Equal(t, string(out), "3\n")
+
+	out, err = exec.Command("git", "-C", dir, "log", "-1", "--format=%an <%ae>|%cn <%ce>|%B", "main").CombinedOutput()
+	assert.NilError(t, err, string(out))
+	assert.Equal(t, string(out), "Curator <curator@example.com>|Curator <curator@example.com>|adding files\n\nCo-authored-by: Friend <friend@example.com>\n\n")
+}
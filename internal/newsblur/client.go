@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/net/publicsuffix"
@@ -27,23 +29,51 @@ type LoginResponse struct {
 type StoriesInfoResponse struct {
 	Authenticated bool     `json:"authenticated"`
 	Stories       []*Story `json:"stories"`
+	// Feeds are the feeds of the stories, by feed ID.
+	Feeds map[string]*Feed `json:"feeds"`
+}
+
+// Feed is the site a story was published on.
+type Feed struct {
+	ID    int    `json:"id"`
+	Title string `json:"feed_title"`
+	Link  string `json:"feed_link"`
 }
 
 type Story struct {
-	ID         string
-	Title      string
-	Permalink  string
+	ID        string
+	Hash      string
+	Title     string
+	Permalink string
+	Authors   string
+	FeedID    int
+	// FeedTitle and FeedLink are set from the feed of the story when the response includes it.
+	FeedTitle string
+	FeedLink  string
+	// Tags are the tags of the story in its feed, UserTags the tags added when sharing it.
+	Tags     []string
+	UserTags []string
+	// Content is the HTML content of the story.
+	Content    string
+	ImageURLs  []string
 	Comment    string
 	SharedDate time.Time
 }
 
 func (s *Story) UnmarshalJSON(bytes []byte) error {
 	type storyJSON struct {
-		ID            string `json:"id"`
-		Title         string `json:"story_title"`
-		Permalink     string `json:"story_permalink"`
-		Comment       string `json:"comments"`
-		SharedDateStr string `json:"shared_date"`
+		ID            string   `json:"id"`
+		Hash          string   `json:"story_hash"`
+		Title         string   `json:"story_title"`
+		Permalink     string   `json:"story_permalink"`
+		Authors       string   `json:"story_authors"`
+		FeedID        int      `json:"story_feed_id"`
+		Tags          []string `json:"story_tags"`
+		UserTags      []string `json:"user_tags"`
+		Content       string   `json:"story_content"`
+		ImageURLs     []string `json:"image_urls"`
+		Comment       string   `json:"comments"`
+		SharedDateStr string   `json:"shared_date"`
 	}
 
 	var stJSON storyJSON
@@ -53,9 +83,16 @@ func (s *Story) UnmarshalJSON(bytes []byte) error {
 	}
 
 	st := Story{
-		ID:        stJSON.Title,
+		ID:        stJSON.ID,
+		Hash:      stJSON.Hash,
 		Title:     stJSON.Title,
 		Permalink: stJSON.Permalink,
+		Authors:   stJSON.Authors,
+		FeedID:    stJSON.FeedID,
+		Tags:      stJSON.Tags,
+		UserTags:  stJSON.UserTags,
+		Content:   stJSON.Content,
+		ImageURLs: stJSON.ImageURLs,
 		Comment:   stJSON.Comment,
 	}
 	st.SharedDate, err = time.Parse(newsblurTimeFormat, stJSON.SharedDateStr)
@@ -67,13 +104,47 @@ func (s *Story) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
+// DefaultBaseURL is the URL of the NewsBlur API.
+const DefaultBaseURL = "https://newsblur.com"
+
 type Client struct {
 	client    *http.Client
 	apiTicker *time.Ticker
 	loginInfo *LoginResponse
+	baseURL   string
+	rateLimit time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL sets the URL of the NewsBlur API, such as a fake.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for API requests.
+// A cookie jar is added to keep the session when the client has none.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		cl := *hc
+		if cl.Jar == nil {
+			cl.Jar = c.client.Jar
+		}
+		c.client = &cl
+	}
+}
+
+// WithRateLimit sets the minimum delay between API requests.
+func WithRateLimit(d time.Duration) Option {
+	return func(c *Client) {
+		c.rateLimit = d
+	}
 }
 
-func New(ctx context.Context, username string, password string) (*Client, error) {
+func New(ctx context.Context, username string, password string, opts ...Option) (*Client, error) {
 	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
 	if err != nil {
 		return nil, fmt.Errorf("creating cookiejar: %w", err)
@@ -85,8 +156,14 @@ func New(ctx context.Context, username string, password string) (*Client, error)
 
 	c := &Client{
 		client:    client,
-		apiTicker: time.NewTicker(apiRequestRateLimit),
+		baseURL:   DefaultBaseURL,
+		rateLimit: apiRequestRateLimit,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.apiTicker = time.NewTicker(c.rateLimit)
+
 	lr, err := c.login(ctx, username, password)
 	if err != nil {
 		return nil, fmt.Errorf("login error: %w", err)
@@ -101,9 +178,15 @@ func (c *Client) login(ctx context.Context, username string, password string) (*
 	values.Set("username", username)
 	values.Set("password", password)
 
-	loginURL := "https://newsblur.com/api/login"
+	loginURL := c.baseURL + "/api/login"
 
-	loginRes, err := c.client.PostForm(loginURL, values)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, loginURL, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("creating login request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	loginRes, err := c.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("post login form: %w", err)
 	}
@@ -124,21 +207,32 @@ func (c *Client) login(ctx context.Context, username string, password string) (*
 	if err := json.Unmarshal(data, &login); err != nil {
 		return nil, fmt.Errorf("unmarshaling login response body: %w", err)
 	}
+	if !login.Authenticated {
+		return nil, fmt.Errorf("login failure: not authenticated")
+	}
 
 	return &login, nil
 }
 
 func (c *Client) GetSharedStories(ctx context.Context, pageNum int) ([]*Story, error) {
 	log.Printf("newsblur:GetSharedStories: page=%d\n", pageNum)
-	storiesURL := fmt.Sprintf("https://newsblur.com/social/stories/%d/?page=%d&&order=newest&read_filter=all", c.loginInfo.UserID, pageNum)
+	storiesURL := fmt.Sprintf("%s/social/stories/%d/?page=%d&&order=newest&read_filter=all", c.baseURL, c.loginInfo.UserID, pageNum)
 
 	<-c.apiTicker.C
-	resp, err := c.client.Get(storiesURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, storiesURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating stories request: %w", err)
+	}
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("getting stories response body: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting stories: unexpected status %s", resp.Status)
+	}
+
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Println(string(data))
@@ -152,6 +246,13 @@ func (c *Client) GetSharedStories(ctx context.Context, pageNum int) ([]*Story, e
 		return nil, fmt.Errorf("unmarshaling stories response body: %w", err)
 	}
 
+	for _, st := range storiesResponse.Stories {
+		if feed, ok := storiesResponse.Feeds[strconv.Itoa(st.FeedID)]; ok && feed != nil {
+			st.FeedTitle = feed.Title
+			st.FeedLink = feed.Link
+		}
+	}
+
 	return storiesResponse.Stories, nil
 }
 
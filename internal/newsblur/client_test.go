@@ -0,0 +1,177 @@
+package newsblur
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/newsblur/newsblurtest"
+	"gotest.tools/v3/assert"
+)
+
+func newTestClient(t *testing.T, srv *newsblurtest.Server) *Client {
+	t.Helper()
+	c, err := New(context.Background(), srv.Username, srv.Password,
+		WithBaseURL(srv.URL),
+		WithHTTPClient(srv.Client()),
+		WithRateLimit(time.Millisecond),
+	)
+	assert.NilError(t, err)
+	return c
+}
+
+func fixtureServer(t *testing.T) *newsblurtest.Server {
+	t.Helper()
+	pages, err := newsblurtest.LoadPages("testdata/shared")
+	assert.NilError(t, err)
+	srv := newsblurtest.NewServer("user", "secret", pages...)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func collectTitles(t *testing.T, it *SharedStoriesIterator) []string {
+	t.Helper()
+	var titles []string
+	for {
+		st, err := it.Next(context.Background())
+		if err == io.EOF {
+			return titles
+		}
+		assert.NilError(t, err)
+		titles = append(titles, st.Title)
+	}
+}
+
+func Test_SharedStoriesIterator_Pages(t *testing.T) {
+	srv := fixtureServer(t)
+	c := newTestClient(t, srv)
+
+	it, err := c.SharedStoriesIterator(context.Background(), time.Time{})
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, collectTitles(t, it), []string{
+		"Go Generics in Practice",
+		"Errors Are Values",
+		"Context Cancellation",
+		"Raft Explained",
+		"End-to-End Arguments",
+	})
+	// The iteration ends on the first empty page.
+	assert.DeepEqual(t, srv.RequestedPages(), []int{1, 2, 3})
+}
+
+func Test_SharedStoriesIterator_NewerThan(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		newerThan time.Time
+		titles    []string
+		pages     []int
+	}{
+		{
+			name:      "cutoff on second page",
+			newerThan: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC),
+			titles:    []string{"Go Generics in Practice", "Errors Are Values", "Context Cancellation", "Raft Explained"},
+			pages:     []int{1, 2},
+		},
+		{
+			name:      "cutoff equal to a shared date is exclusive",
+			newerThan: time.Date(2022, 3, 5, 7, 0, 0, 0, time.UTC),
+			titles:    []string{"Go Generics in Practice"},
+			pages:     []int{1},
+		},
+		{
+			name:      "fractional seconds are compared",
+			newerThan: time.Date(2022, 3, 2, 10, 0, 0, 0, time.UTC),
+			titles:    []string{"Go Generics in Practice", "Errors Are Values", "Context Cancellation", "Raft Explained"},
+			pages:     []int{1, 2},
+		},
+		{
+			name:      "nothing new",
+			newerThan: time.Date(2022, 3, 7, 0, 0, 0, 0, time.UTC),
+			pages:     []int{1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := fixtureServer(t)
+			c := newTestClient(t, srv)
+
+			it, err := c.SharedStoriesIterator(context.Background(), tc.newerThan)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, collectTitles(t, it), tc.titles)
+			assert.DeepEqual(t, srv.RequestedPages(), tc.pages)
+		})
+	}
+}
+
+func Test_Story_Metadata(t *testing.T) {
+	srv := fixtureServer(t)
+	c := newTestClient(t, srv)
+
+	stories, err := c.GetSharedStories(context.Background(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(stories), 3)
+
+	st := stories[0]
+	assert.Equal(t, st.ID, "https://blog.example.com/generics")
+	assert.Equal(t, st.Hash, "1001:a1b2c3")
+	assert.Equal(t, st.Authors, "Jane Doe")
+	assert.Equal(t, st.FeedID, 1001)
+	assert.Equal(t, st.FeedTitle, "Example Blog")
+	assert.Equal(t, st.FeedLink, "https://blog.example.com/")
+	assert.DeepEqual(t, st.Tags, []string{"go", "generics"})
+	assert.DeepEqual(t, st.UserTags, []string{"golang", "go"})
+	assert.DeepEqual(t, stories[1].ImageURLs, []string{"https://blog.example.com/errors.png"})
+
+	srv = newsblurtest.NewServer("user", "secret", newsblurtest.Page(
+		newsblurtest.Story{Title: "no feed", FeedID: 7, Content: "<p>Hello</p>", SharedDate: "2022-03-02 10:00:00"},
+	))
+	defer srv.Close()
+	c = newTestClient(t, srv)
+
+	stories, err = c.GetSharedStories(context.Background(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, stories[0].FeedID, 7)
+	assert.Equal(t, stories[0].FeedTitle, "")
+	assert.Equal(t, stories[0].Content, "<p>Hello</p>")
+}
+
+func Test_Story_SharedDate(t *testing.T) {
+	srv := newsblurtest.NewServer("user", "secret", newsblurtest.Page(
+		newsblurtest.Story{Title: "no fraction", SharedDate: "2022-03-02 10:00:00"},
+		newsblurtest.Story{Title: "micro", SharedDate: "2022-03-02 10:00:00.123456"},
+		newsblurtest.Story{Title: "tenth", SharedDate: "2022-03-02 10:00:00.1"},
+	))
+	defer srv.Close()
+	c := newTestClient(t, srv)
+
+	stories, err := c.GetSharedStories(context.Background(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(stories), 3)
+	assert.Equal(t, stories[0].SharedDate, time.Date(2022, 3, 2, 10, 0, 0, 0, time.UTC))
+	assert.Equal(t, stories[1].SharedDate, time.Date(2022, 3, 2, 10, 0, 0, 123456000, time.UTC))
+	assert.Equal(t, stories[2].SharedDate, time.Date(2022, 3, 2, 10, 0, 0, 100000000, time.UTC))
+
+	for _, date := range []string{"2022-03-02T10:00:00Z", ""} {
+		srv := newsblurtest.NewServer("user", "secret", newsblurtest.Page(
+			newsblurtest.Story{Title: "bad", SharedDate: date},
+		))
+		c := newTestClient(t, srv)
+		_, err := c.GetSharedStories(context.Background(), 1)
+		assert.ErrorContains(t, err, "error parsing date of story")
+		srv.Close()
+	}
+}
+
+func Test_Login(t *testing.T) {
+	srv := newsblurtest.NewServer("user", "secret")
+	defer srv.Close()
+
+	_, err := New(context.Background(), "user", "wrong", WithBaseURL(srv.URL), WithRateLimit(time.Millisecond))
+	assert.ErrorContains(t, err, "not authenticated")
+
+	c := newTestClient(t, srv)
+	stories, err := c.GetSharedStories(context.Background(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(stories), 0)
+}
@@ -2,14 +2,19 @@ package newsblur
 
 import (
 	"context"
+	"flag"
 	"io"
+	"net/http"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/seriousben/positronic-blogger/internal/newsblur/newsblurtest"
 	"gotest.tools/v3/assert"
 )
 
+var record = flag.String("record", "", "directory to record sanitized fixtures of the real NewsBlur to")
+
 const (
 	envNewsblurUsername = "POSITRONIC_TEST_NEWSBLUR_USERNAME"
 	envNewsblurPassword = "POSITRONIC_TEST_NEWSBLUR_PASSWORD"
@@ -43,3 +48,34 @@ func Test_Client(t *testing.T) {
 		assert.NilError(t, err)
 	}
 }
+
+// Test_Record records fixtures from a real account:
+//
+//	go test ./internal/newsblur -run Test_Record -record testdata/recorded
+func Test_Record(t *testing.T) {
+	var (
+		nbUsername = os.Getenv(envNewsblurUsername)
+		nbPassword = os.Getenv(envNewsblurPassword)
+	)
+
+	if *record == "" || nbUsername == "" || nbPassword == "" {
+		t.Skipf("Skipping, missing -record, %s or %s", envNewsblurUsername, envNewsblurPassword)
+	}
+
+	ctx := context.Background()
+	cl, err := New(ctx, nbUsername, nbPassword, WithHTTPClient(&http.Client{
+		Transport: &newsblurtest.Recorder{Dir: *record},
+	}))
+	assert.NilError(t, err)
+
+	it, err := cl.SharedStoriesIterator(ctx, time.Now().Add(-1*3*30*24*time.Hour))
+	assert.NilError(t, err)
+
+	for {
+		_, err := it.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		assert.NilError(t, err)
+	}
+}
@@ -0,0 +1,128 @@
+package newsblurtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// storyFields are the story fields kept in fixtures.
+// Everything else, such as user profiles and story content, is dropped.
+var storyFields = []string{
+	"id",
+	"story_hash",
+	"story_title",
+	"story_permalink",
+	"story_authors",
+	"story_feed_id",
+	"story_tags",
+	"story_date",
+	"image_urls",
+	"user_tags",
+	"comments",
+	"shared_date",
+}
+
+// feedFields are the fields of the feeds of stories kept in fixtures.
+var feedFields = []string{
+	"id",
+	"feed_title",
+	"feed_link",
+}
+
+// Sanitize returns the social/stories response body data
+// without the personal information and content it holds.
+func Sanitize(data []byte) ([]byte, error) {
+	var resp struct {
+		Stories []map[string]json.RawMessage `json:"stories"`
+		Feeds   map[string]json.RawMessage   `json:"feeds"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("decoding stories response: %w", err)
+	}
+
+	out := map[string]any{
+		"authenticated": true,
+		"stories":       pick(resp.Stories, storyFields),
+	}
+
+	var feeds []map[string]json.RawMessage
+	for _, raw := range resp.Feeds {
+		var feed map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &feed); err == nil {
+			feeds = append(feeds, feed)
+		}
+	}
+	if len(feeds) > 0 {
+		byID := map[string]any{}
+		for _, f := range pick(feeds, feedFields) {
+			byID[strings.Trim(string(f["id"]), `"`)] = f
+		}
+		out["feeds"] = byID
+	}
+
+	return json.MarshalIndent(out, "", "  ")
+}
+
+func pick(objs []map[string]json.RawMessage, fields []string) []map[string]json.RawMessage {
+	picked := make([]map[string]json.RawMessage, 0, len(objs))
+	for _, obj := range objs {
+		p := map[string]json.RawMessage{}
+		for _, f := range fields {
+			if v, ok := obj[f]; ok {
+				p[f] = v
+			}
+		}
+		picked = append(picked, p)
+	}
+	return picked
+}
+
+// Recorder is an http.RoundTripper writing the social/stories responses
+// it transports to sanitized page-<n>.json fixtures in Dir.
+type Recorder struct {
+	Dir string
+	// Transport makes the requests, http.DefaultTransport is used when nil.
+	Transport http.RoundTripper
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || !strings.HasPrefix(req.URL.Path, "/social/stories/") {
+		return resp, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading stories response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	page, err := strconv.Atoi(req.URL.Query().Get("page"))
+	if err != nil {
+		page = 1
+	}
+	sanitized, err := Sanitize(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating fixture directory: %w", err)
+	}
+	name := filepath.Join(r.Dir, fmt.Sprintf("page-%d.json", page))
+	if err := os.WriteFile(name, append(sanitized, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("writing fixture: %w", err)
+	}
+	return resp, nil
+}
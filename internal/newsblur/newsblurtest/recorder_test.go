@@ -0,0 +1,69 @@
+package newsblurtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const realResponse = `{
+  "authenticated": true,
+  "user_profiles": [{"user_id": 42, "username": "someone", "photo_url": "https://example.com/me.png"}],
+  "feeds": {"7": {"id": 7, "feed_title": "Feed", "feed_link": "https://example.com", "subscribers": 12}},
+  "stories": [{
+    "id": "https://example.com/post",
+    "story_title": "Post",
+    "story_permalink": "https://example.com/post",
+    "story_content": "<p>the whole article</p>",
+    "shared_date": "2022-03-02 10:00:00",
+    "comments": "nice",
+    "share_user_ids": [42],
+    "friend_user_ids": [43]
+  }]
+}`
+
+func Test_Recorder(t *testing.T) {
+	srv := NewServer("user", "secret", []byte(realResponse))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	client := &http.Client{Transport: &Recorder{Dir: dir}}
+
+	resp, err := client.PostForm(srv.URL+"/api/login", map[string][]string{"username": {"user"}, "password": {"secret"}})
+	assert.NilError(t, err)
+	resp.Body.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/social/stories/42/?page=1", nil)
+	assert.NilError(t, err)
+	req.AddCookie(resp.Cookies()[0])
+	resp, err = client.Do(req)
+	assert.NilError(t, err)
+	resp.Body.Close()
+
+	b, err := os.ReadFile(filepath.Join(dir, "page-1.json"))
+	assert.NilError(t, err)
+
+	var fixture map[string]any
+	assert.NilError(t, json.Unmarshal(b, &fixture))
+	assert.DeepEqual(t, fixture, map[string]any{
+		"authenticated": true,
+		"feeds": map[string]any{
+			"7": map[string]any{"id": float64(7), "feed_title": "Feed", "feed_link": "https://example.com"},
+		},
+		"stories": []any{map[string]any{
+			"id":              "https://example.com/post",
+			"story_title":     "Post",
+			"story_permalink": "https://example.com/post",
+			"shared_date":     "2022-03-02 10:00:00",
+			"comments":        "nice",
+		}},
+	})
+
+	pages, err := LoadPages(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, len(pages), 1)
+}
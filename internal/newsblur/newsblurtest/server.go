@@ -0,0 +1,169 @@
+// Package newsblurtest provides a fake NewsBlur serving shared stories from fixtures
+// and a recorder capturing fixtures from the real NewsBlur.
+package newsblurtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const sessionCookie = "newsblur_sessionid"
+
+// Server is a fake NewsBlur serving the shared stories of a single user.
+// Each page is the raw JSON body of a social/stories response,
+// pages past the last one have no stories.
+type Server struct {
+	*httptest.Server
+
+	Username, Password string
+	UserID             int
+
+	mu        sync.Mutex
+	pages     [][]byte
+	requested []int
+}
+
+// NewServer starts a fake accepting username and password and serving pages, page 1 first.
+// The server must be closed by the caller.
+func NewServer(username, password string, pages ...[]byte) *Server {
+	s := &Server{
+		Username: username,
+		Password: password,
+		UserID:   42,
+		pages:    pages,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/login", s.login)
+	mux.HandleFunc("/social/stories/", s.stories)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// RequestedPages returns the pages requested so far, in order.
+func (s *Server) RequestedPages() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.requested...)
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.PostFormValue("username") != s.Username || r.PostFormValue("password") != s.Password {
+		fmt.Fprint(w, `{"code": -1, "authenticated": false, "errors": {"__all__": ["Whoopsy-daisy, wrong password. Try again."]}}`)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "session", Path: "/"})
+	fmt.Fprintf(w, `{"code": 1, "authenticated": true, "user_id": %d}`, s.UserID)
+}
+
+func (s *Server) stories(w http.ResponseWriter, r *http.Request) {
+	if _, err := r.Cookie(sessionCookie); err != nil {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if strings.Trim(strings.TrimPrefix(r.URL.Path, "/social/stories/"), "/") != strconv.Itoa(s.UserID) {
+		http.NotFound(w, r)
+		return
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	s.mu.Lock()
+	s.requested = append(s.requested, page)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if page > len(s.pages) {
+		fmt.Fprint(w, `{"authenticated": true, "stories": []}`)
+		return
+	}
+	_, _ = w.Write(s.pages[page-1])
+}
+
+// Story is a shared story of a Page.
+type Story struct {
+	ID         string   `json:"id"`
+	Title      string   `json:"story_title"`
+	Permalink  string   `json:"story_permalink"`
+	Authors    string   `json:"story_authors,omitempty"`
+	FeedID     int      `json:"story_feed_id,omitempty"`
+	Tags       []string `json:"story_tags,omitempty"`
+	UserTags   []string `json:"user_tags,omitempty"`
+	Content    string   `json:"story_content,omitempty"`
+	ImageURLs  []string `json:"image_urls,omitempty"`
+	Comments   string   `json:"comments"`
+	SharedDate string   `json:"shared_date"`
+}
+
+// Feed is the feed of stories of a Page.
+type Feed struct {
+	ID    int    `json:"id"`
+	Title string `json:"feed_title"`
+	Link  string `json:"feed_link"`
+}
+
+// Page returns the body of a social/stories response holding stories.
+func Page(stories ...Story) []byte {
+	return PageWithFeeds(nil, stories...)
+}
+
+// PageWithFeeds returns the body of a social/stories response holding stories and their feeds.
+func PageWithFeeds(feeds []Feed, stories ...Story) []byte {
+	if stories == nil {
+		stories = []Story{}
+	}
+	resp := map[string]any{"authenticated": true, "stories": stories}
+	if len(feeds) > 0 {
+		byID := map[string]Feed{}
+		for _, f := range feeds {
+			byID[strconv.Itoa(f.ID)] = f
+		}
+		resp["feeds"] = byID
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// LoadPages reads the page-<n>.json fixtures of dir, page 1 first.
+func LoadPages(dir string) ([][]byte, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "page-*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	pageNum := func(p string) int {
+		n, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(p), "page-"), ".json"))
+		return n
+	}
+	sort.Slice(paths, func(i, j int) bool { return pageNum(paths[i]) < pageNum(paths[j]) })
+
+	var pages [][]byte
+	for i, p := range paths {
+		if pageNum(p) != i+1 {
+			return nil, fmt.Errorf("missing fixture page-%d.json in %s", i+1, dir)
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("reading fixture: %w", err)
+		}
+		pages = append(pages, b)
+	}
+	return pages, nil
+}
@@ -2,187 +2,131 @@ package newsblurposter
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
-	"log"
-	"path"
-	"regexp"
 	"strings"
 	"time"
 
-	"github.com/seriousben/positronic-blogger/internal/github"
+	"github.com/seriousben/positronic-blogger/internal/archive"
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"github.com/seriousben/positronic-blogger/internal/canonical"
+	"github.com/seriousben/positronic-blogger/internal/dedup"
+	"github.com/seriousben/positronic-blogger/internal/htmlmd"
+	"github.com/seriousben/positronic-blogger/internal/linkpreview"
 	"github.com/seriousben/positronic-blogger/internal/newsblur"
+	"github.com/seriousben/positronic-blogger/internal/poster"
+	"github.com/seriousben/positronic-blogger/internal/source"
 	"github.com/seriousben/positronic-blogger/internal/template"
 )
 
-var (
-	newsblurDupLinkRegex = regexp.MustCompile(`<a href="(.*)">.*</a>`)
-)
-
-func newsblurStoryToBlogPost(story *newsblur.Story) (template.Post, error) {
-	comment := newsblurDupLinkRegex.ReplaceAllString(
-		story.Comment,
-		"$1",
-	)
-	return template.Post{
+func newsblurStoryToItem(story *newsblur.Story) (*source.Item, error) {
+	comment := htmlmd.Convert(story.Comment)
+	item := &source.Item{
+		ID:      story.ID,
 		Title:   story.Title,
 		URL:     story.Permalink,
 		Comment: comment,
 		Date:    story.SharedDate,
-	}, nil
-}
-
-type Config struct {
-	GithubClient              *github.Client
-	NewsblurClient            *newsblur.Client
-	NewsblurContentPath       string
-	NewsblurCheckpointPath    string
-	InitialNewsblurCheckpoint time.Time
-	SkipMerge                 bool
-	GithubPrefix              string
-}
-
-type Poster struct {
-	Config
-}
-
-func New(cfg Config) (*Poster, error) {
-	return &Poster{
-		Config: cfg,
-	}, nil
-}
-
-func (b *Poster) Run(ctx context.Context) error {
-	checkpoint, checkpointSHA, err := b.getCheckpoint(ctx)
-	if err != nil {
-		return err
-	}
-
-	if checkpoint.Before(b.InitialNewsblurCheckpoint) {
-		checkpoint = b.InitialNewsblurCheckpoint
+		Author:  story.Authors,
+		Site:    story.FeedTitle,
+		SiteURL: story.FeedLink,
+		Tags:    storyTags(story),
+		Content: story.Content,
 	}
-
-	it, err := b.NewsblurClient.SharedStoriesIterator(ctx, checkpoint)
-	if err != nil {
-		return err
-	}
-
-	var brc *github.BranchClient
-	lastCheckpointAt := checkpoint
-
-	for {
-		st, err := it.Next(ctx)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
-
-		post, err := newsblurStoryToBlogPost(st)
-		if err != nil {
-			return err
-		}
-
-		// Safety check to make sure posts returned from
-		// content providers are newer than passed in checkpoint.
-		if post.Date.After(lastCheckpointAt) {
-			lastCheckpointAt = post.Date
-		}
-
-		// start branch on first new content.
-		// Use second-precision timestamp to avoid collisions when retrying failed runs.
-		if brc == nil {
-			brc, err = b.GithubClient.StartBranch(ctx, fmt.Sprintf("%s%s-positronic-blogger", b.GithubPrefix, checkpoint.Format("2006-01-02T150405")))
-			if err != nil {
-				return err
-			}
-		}
-		fileName := post.FileName()
-		commit := fmt.Sprintf("auto: new short post %s [skip ci]", fileName)
-
-		buf, err := post.ToMarkdown()
-		if err != nil {
-			return err
-		}
-
-		err = brc.CreateFile(ctx, commit, path.Join(b.NewsblurContentPath, fileName), buf.String())
-		if err != nil {
-			return err
-		}
+	if len(story.ImageURLs) > 0 {
+		item.Image = story.ImageURLs[0]
 	}
+	return item, nil
+}
 
-	if brc != nil {
-		if err = b.setCheckpoint(ctx, brc, lastCheckpointAt, checkpointSHA); err != nil {
-			return err
-		}
-		pr, err := brc.PullRequest(
-			ctx,
-			fmt.Sprintf("%s%s-positronic-blogger", b.GithubPrefix, checkpoint.Format(time.RFC3339)),
-			"Auto blogging done from https://github.com/seriousben/positronic-blogger",
-		)
-		if err != nil {
-			return err
-		}
-		if !b.Config.SkipMerge {
-			err = brc.WaitAndMerge(ctx, pr)
-			if err != nil {
-				return err
-			}
+// storyTags returns the tags added when sharing the story followed by the tags of the story,
+// without duplicates.
+func storyTags(story *newsblur.Story) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, tag := range append(append([]string(nil), story.UserTags...), story.Tags...) {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
 		}
+		seen[key] = true
+		tags = append(tags, tag)
 	}
-	return nil
+	return tags
 }
 
-func (b *Poster) getCheckpoint(ctx context.Context) (time.Time, string, error) {
-	checkpointStr, checkpointSHA, err := b.GithubClient.GetContent(ctx, b.NewsblurCheckpointPath)
-	if err != nil && !errors.Is(err, github.ErrFileNotFound) {
-		return time.Time{}, "", err
-	}
-
-	if checkpointStr == "" {
-		return time.Time{}, "", nil
-	}
-
-	var checkpoint time.Time
-	err = json.Unmarshal([]byte(checkpointStr), &checkpoint)
-	if err != nil {
-		log.Printf("checkpoint is not JSON: %v", err)
-	} else {
-		return checkpoint, checkpointSHA, nil
-	}
+// Source exposes NewsBlur shared stories as a source.Source.
+type Source struct {
+	Client *newsblur.Client
+}
 
-	// fallback on legacy newsblur time format.
-	checkpoint, err = time.Parse("2006-01-02 15:04:05.999999", strings.Trim(checkpointStr, "\r\n"))
+func (s *Source) Iterator(ctx context.Context, newerThan time.Time) (source.Iterator, error) {
+	it, err := s.Client.SharedStoriesIterator(ctx, newerThan)
 	if err != nil {
-		return time.Time{}, "", fmt.Errorf("error parsing checkpoint: %w", err)
+		return nil, err
 	}
+	return &iterator{it: it}, nil
+}
 
-	return checkpoint, checkpointSHA, nil
+type iterator struct {
+	it *newsblur.SharedStoriesIterator
 }
 
-func (b *Poster) setCheckpoint(ctx context.Context, gh *github.BranchClient, checkpoint time.Time, checkpointSHA string) error {
-	checkpointJSON, err := json.Marshal(checkpoint)
+func (i *iterator) Next(ctx context.Context) (*source.Item, error) {
+	st, err := i.it.Next(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return newsblurStoryToItem(st)
+}
 
-	commit := "auto: checkpoint"
+type Config struct {
+	// GithubClient is the repository where posts are published.
+	// Any backend.Repository can be used, such as a local git repository.
+	GithubClient              backend.Repository
+	NewsblurClient            *newsblur.Client
+	NewsblurContentPath       string
+	NewsblurCheckpointPath    string
+	InitialNewsblurCheckpoint time.Time
+	SkipMerge                 bool
+	GithubPrefix              string
+	Batch                     bool
+	Template                  *template.Template
+	Identity                  backend.Identity
+	Duplicates                dedup.Policy
+	Canonicalizer             *canonical.Canonicalizer
+	Previewer                 *linkpreview.Fetcher
+	Archiver                  *archive.Archiver
+}
 
-	if checkpointSHA == "" {
-		err = gh.CreateFile(ctx, commit, b.NewsblurCheckpointPath, string(checkpointJSON))
-		if err != nil {
-			return err
-		}
-		return nil
-	}
+// Poster publishes NewsBlur shared stories using the generic poster pipeline.
+type Poster struct {
+	*poster.Poster
+}
 
-	err = gh.UpdateFile(ctx, commit, b.NewsblurCheckpointPath, checkpointSHA, string(checkpointJSON))
+func New(cfg Config) (*Poster, error) {
+	p, err := poster.New(poster.Config{
+		Repository: cfg.GithubClient,
+		Sources: []poster.SourceConfig{
+			{
+				Name:              "newsblur",
+				Source:            &Source{Client: cfg.NewsblurClient},
+				ContentPath:       cfg.NewsblurContentPath,
+				CheckpointPath:    cfg.NewsblurCheckpointPath,
+				InitialCheckpoint: cfg.InitialNewsblurCheckpoint,
+			},
+		},
+		SkipMerge:     cfg.SkipMerge,
+		GithubPrefix:  cfg.GithubPrefix,
+		Batch:         cfg.Batch,
+		Template:      cfg.Template,
+		Identity:      cfg.Identity,
+		Duplicates:    cfg.Duplicates,
+		Canonicalizer: cfg.Canonicalizer,
+		Previewer:     cfg.Previewer,
+		Archiver:      cfg.Archiver,
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return nil
+	return &Poster{Poster: p}, nil
 }
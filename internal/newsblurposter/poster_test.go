@@ -0,0 +1,94 @@
+package newsblurposter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/github"
+	"github.com/seriousben/positronic-blogger/internal/github/githubtest"
+	"github.com/seriousben/positronic-blogger/internal/newsblur"
+	"github.com/seriousben/positronic-blogger/internal/newsblur/newsblurtest"
+	"gotest.tools/v3/assert"
+)
+
+func Test_Poster_Fake(t *testing.T) {
+	ctx := context.Background()
+
+	nbSrv := newsblurtest.NewServer("user", "secret",
+		newsblurtest.PageWithFeeds(
+			[]newsblurtest.Feed{{ID: 1, Title: "Example", Link: "https://example.com/"}},
+			newsblurtest.Story{
+				Title:      "Newest",
+				Permalink:  "https://example.com/newest",
+				Authors:    "Jane Doe",
+				FeedID:     1,
+				Tags:       []string{"Go", "web"},
+				UserTags:   []string{"go"},
+				ImageURLs:  []string{"https://example.com/newest.png", "https://example.com/other.png"},
+				Comments:   `See <a href="https://example.com/related">https://example.com/related</a>`,
+				SharedDate: "2022-03-03 10:00:00.5",
+			},
+			newsblurtest.Story{
+				Title:      "Older",
+				Permalink:  "https://example.com/older",
+				Comments:   `Tips &amp; <a href="https://a.example/">tricks</a>, <a href="https://b.example/">more</a>`,
+				SharedDate: "2022-03-02 10:00:00",
+			},
+		),
+		newsblurtest.Page(
+			newsblurtest.Story{
+				Title:      "Already Posted",
+				Permalink:  "https://example.com/posted",
+				SharedDate: "2022-03-01 10:00:00",
+			},
+		),
+	)
+	defer nbSrv.Close()
+
+	ghSrv := githubtest.NewServer("owner", "blog")
+	defer ghSrv.Close()
+	ghSrv.SetFile("main", "content/links/checkpoint", `"2022-03-01T10:00:00Z"`)
+
+	nbClient, err := newsblur.New(ctx, "user", "secret",
+		newsblur.WithBaseURL(nbSrv.URL),
+		newsblur.WithHTTPClient(nbSrv.Client()),
+		newsblur.WithRateLimit(time.Millisecond),
+	)
+	assert.NilError(t, err)
+
+	ghClient, err := github.New(ctx, "token", "owner", "blog",
+		github.WithBaseURL(ghSrv.BaseURL()),
+		github.WithHTTPClient(ghSrv.Client()),
+		github.WithRateLimit(time.Millisecond),
+	)
+	assert.NilError(t, err)
+
+	bl, err := New(Config{
+		GithubClient:           ghClient,
+		NewsblurClient:         nbClient,
+		NewsblurContentPath:    "content/links",
+		NewsblurCheckpointPath: "content/links/checkpoint",
+	})
+	assert.NilError(t, err)
+
+	assert.NilError(t, bl.Run(ctx))
+
+	files := ghSrv.Files("main")
+	assert.Equal(t, len(files), 3)
+	assert.Equal(t, files["content/links/checkpoint"], `"2022-03-03T10:00:00.5Z"`)
+	newest := files["content/links/2022-03-03-newest.md"]
+	assert.Assert(t, strings.Contains(newest, "See https://example.com/related"), newest)
+	assert.Assert(t, strings.Contains(newest, `author = "Jane Doe"
+site = "Example"
+siteUrl = "https://example.com/"
+image = "https://example.com/newest.png"
+tags = ["go", "web"]
++++`), newest)
+	older := files["content/links/2022-03-02-older.md"]
+	assert.Assert(t, strings.Contains(older, "\nTips & [tricks](https://a.example/), [more](https://b.example/)\n"), older)
+
+	// The iteration stops at the story shared at the checkpoint, on the second page.
+	assert.DeepEqual(t, nbSrv.RequestedPages(), []int{1, 2})
+}
@@ -0,0 +1,435 @@
+package poster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/archive"
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"github.com/seriousben/positronic-blogger/internal/canonical"
+	"github.com/seriousben/positronic-blogger/internal/dedup"
+	"github.com/seriousben/positronic-blogger/internal/linkpreview"
+	"github.com/seriousben/positronic-blogger/internal/source"
+	"github.com/seriousben/positronic-blogger/internal/template"
+)
+
+func itemToBlogPost(it *source.Item) template.Post {
+	return template.Post{
+		Title:         it.Title,
+		URL:           it.URL,
+		Comment:       it.Comment,
+		Date:          it.Date,
+		Author:        it.Author,
+		Site:          it.Site,
+		SiteURL:       it.SiteURL,
+		Tags:          it.Tags,
+		Content:       it.Content,
+		Image:         it.Image,
+		DiscussionURL: it.DiscussionURL,
+	}
+}
+
+// SourceConfig describes where the content of a source is stored in the repository.
+type SourceConfig struct {
+	Name              string
+	Source            source.Source
+	ContentPath       string
+	CheckpointPath    string
+	InitialCheckpoint time.Time
+}
+
+type Config struct {
+	Repository   backend.Repository
+	Sources      []SourceConfig
+	SkipMerge    bool
+	GithubPrefix string
+	// Identity is who the commits are attributed to.
+	Identity backend.Identity
+	// Template renders posts, template.Default is used when nil.
+	Template *template.Template
+	// Canonicalizer cleans up the links of items, links are kept as they are when nil.
+	Canonicalizer *canonical.Canonicalizer
+	// Previewer enriches posts with the metadata of the page they link to, when not nil.
+	Previewer *linkpreview.Fetcher
+	// Archiver archives the pages posts link to, when not nil.
+	Archiver *archive.Archiver
+	// Duplicates is what to do with items whose URL was already posted,
+	// under the content path of any source or LookupPaths.
+	Duplicates dedup.Policy
+	// LookupPaths are other paths where existing posts are looked up,
+	// such as the content paths of the other commands.
+	LookupPaths []string
+	// Batch stages all new posts and checkpoints and commits them at once
+	// at the end of the run instead of creating one commit per file.
+	Batch bool
+}
+
+type Poster struct {
+	Config
+}
+
+func New(cfg Config) (*Poster, error) {
+	if cfg.Repository == nil {
+		return nil, errors.New("missing repository")
+	}
+	for i, src := range cfg.Sources {
+		if src.Source == nil {
+			return nil, fmt.Errorf("source %d (%s) has no source", i, src.Name)
+		}
+		if src.ContentPath == "" || src.CheckpointPath == "" {
+			return nil, fmt.Errorf("source %d (%s) is missing a content or checkpoint path", i, src.Name)
+		}
+	}
+	if cfg.Template == nil {
+		cfg.Template = template.Default
+	}
+	return &Poster{
+		Config: cfg,
+	}, nil
+}
+
+// run holds the state shared by all sources during a single Run.
+type run struct {
+	brc        backend.Branch
+	started    bool
+	startedAt  time.Time
+	posts      int
+	duplicates int
+	files      []backend.File
+	index      *dedup.Index
+}
+
+// Summary describes what a run published.
+type Summary struct {
+	// Posts is the number of posts created or updated.
+	Posts int
+	// Duplicates is the number of items skipped as already posted.
+	Duplicates int
+	// Published is whether a branch was published.
+	Published bool
+}
+
+func (b *Poster) Run(ctx context.Context) error {
+	_, err := b.RunWithSummary(ctx)
+	return err
+}
+
+// RunWithSummary runs like Run and returns what the run published, even when it fails.
+func (b *Poster) RunWithSummary(ctx context.Context) (Summary, error) {
+	r := &run{}
+	err := b.run(ctx, r)
+	return Summary{
+		Posts:      r.posts,
+		Duplicates: r.duplicates,
+		Published:  err == nil && r.started,
+	}, err
+}
+
+func (b *Poster) run(ctx context.Context, r *run) error {
+	for _, src := range b.Sources {
+		if err := b.runSource(ctx, r, src); err != nil {
+			return fmt.Errorf("source %s: %w", src.Name, err)
+		}
+	}
+
+	if !r.started {
+		return nil
+	}
+
+	if b.Batch {
+		if err := b.openBranch(ctx, r); err != nil {
+			return err
+		}
+		commit := "auto: checkpoint [skip ci]"
+		switch {
+		case r.posts == 1:
+			commit = "auto: 1 new short post [skip ci]"
+		case r.posts > 1:
+			commit = fmt.Sprintf("auto: %d new short posts [skip ci]", r.posts)
+		}
+		if err := r.brc.CommitFiles(ctx, commit, r.files); err != nil {
+			return err
+		}
+	}
+
+	body := "Auto blogging done from https://github.com/seriousben/positronic-blogger"
+	if r.posts == 0 {
+		// All new items were already posted, only the checkpoints move.
+		body = "Checkpoint update from https://github.com/seriousben/positronic-blogger, all new items were already posted"
+	}
+	return r.brc.Publish(
+		ctx,
+		fmt.Sprintf("%s%s-positronic-blogger", b.GithubPrefix, r.startedAt.Format(time.RFC3339)),
+		body,
+		!b.SkipMerge,
+	)
+}
+
+func (b *Poster) runSource(ctx context.Context, r *run, src SourceConfig) error {
+	checkpoint, state, checkpointSHA, err := b.getCheckpoint(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	if checkpoint.Before(src.InitialCheckpoint) {
+		checkpoint = src.InitialCheckpoint
+	}
+
+	stateful, _ := src.Source.(source.Stateful)
+	if stateful != nil {
+		if err := stateful.SetState(state); err != nil {
+			return fmt.Errorf("restoring state: %w", err)
+		}
+	}
+
+	it, err := src.Source.Iterator(ctx, checkpoint)
+	if err != nil {
+		return err
+	}
+
+	lastCheckpointAt := checkpoint
+	hasContent := false
+
+	for {
+		item, err := it.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		post := itemToBlogPost(item)
+		if b.Canonicalizer != nil {
+			post.URL = b.Canonicalizer.Canonicalize(ctx, post.URL)
+		}
+
+		// Safety check to make sure posts returned from
+		// content providers are newer than passed in checkpoint.
+		if post.Date.After(lastCheckpointAt) {
+			lastCheckpointAt = post.Date
+		}
+
+		// start branch on first new content.
+		if !r.started {
+			r.started = true
+			r.startedAt = checkpoint
+		}
+		hasContent = true
+
+		if err := b.writePost(ctx, r, src, post); err != nil {
+			return err
+		}
+	}
+
+	if !hasContent {
+		return nil
+	}
+
+	state = nil
+	if stateful != nil {
+		if state, err = stateful.State(); err != nil {
+			return err
+		}
+	}
+
+	return b.setCheckpoint(ctx, r, src, lastCheckpointAt, state, checkpointSHA)
+}
+
+// index returns the index of the posts under the content paths of all sources and LookupPaths,
+// a link posted by any source is a duplicate for the others.
+func (b *Poster) index(ctx context.Context, r *run) (*dedup.Index, error) {
+	if r.index != nil {
+		return r.index, nil
+	}
+	candidates := slices.Clone(b.LookupPaths)
+	for _, src := range b.Sources {
+		candidates = append(candidates, src.ContentPath)
+	}
+	var dirs []string
+	for _, dir := range candidates {
+		if dir != "" && !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	idx, err := dedup.LoadAll(ctx, b.Repository, dirs)
+	if err != nil {
+		return nil, err
+	}
+	r.index = idx
+	return idx, nil
+}
+
+func (b *Poster) writePost(ctx context.Context, r *run, src SourceConfig, post template.Post) error {
+	var (
+		fileName = b.Template.FileName(post)
+		filePath = path.Join(src.ContentPath, fileName)
+		existing dedup.Entry
+		update   bool
+	)
+
+	if b.Duplicates != "" && b.Duplicates != dedup.PolicyOff {
+		idx, err := b.index(ctx, r)
+		if err != nil {
+			return err
+		}
+		if e, ok := idx.Lookup(post.URL); ok {
+			// Posts created during this run are never updated.
+			if b.Duplicates == dedup.PolicySkip || e.SHA == "" {
+				log.Printf("skipping %s, already posted in %s", post.URL, e.Path)
+				r.duplicates++
+				return nil
+			}
+			existing, update = e, true
+			filePath = e.Path
+			fileName = path.Base(e.Path)
+		}
+		idx.Add(post.URL, dedup.Entry{Path: filePath})
+	}
+
+	r.posts++
+
+	if b.Previewer != nil {
+		b.Previewer.Enrich(ctx, &post)
+	}
+
+	var archived *backend.File
+	if b.Archiver != nil {
+		f, err := b.Archiver.Archive(ctx, b.Repository, &post, fileName, b.Template.Format())
+		if err != nil {
+			return err
+		}
+		archived = f
+	}
+
+	buf, err := b.Template.Render(post)
+	if err != nil {
+		return err
+	}
+
+	if b.Batch {
+		if archived != nil {
+			r.files = append(r.files, *archived)
+		}
+		r.files = append(r.files, backend.File{Path: filePath, Content: buf.String()})
+		return nil
+	}
+
+	if err := b.openBranch(ctx, r); err != nil {
+		return err
+	}
+	if archived != nil {
+		commit := fmt.Sprintf("auto: archive of short post %s [skip ci]", fileName)
+		if err := r.brc.CreateFile(ctx, commit, archived.Path, archived.Content); err != nil {
+			return err
+		}
+	}
+	if update {
+		commit := fmt.Sprintf("auto: update short post %s [skip ci]", fileName)
+		return r.brc.UpdateFile(ctx, commit, filePath, existing.SHA, buf.String())
+	}
+	commit := fmt.Sprintf("auto: new short post %s [skip ci]", fileName)
+	return r.brc.CreateFile(ctx, commit, filePath, buf.String())
+}
+
+// openBranch opens the branch of the run if it is not opened yet.
+// Use second-precision timestamp to avoid collisions when retrying failed runs.
+func (b *Poster) openBranch(ctx context.Context, r *run) error {
+	if r.brc != nil {
+		return nil
+	}
+	brc, err := b.Repository.OpenBranch(ctx, fmt.Sprintf("%s%s-positronic-blogger", b.GithubPrefix, r.startedAt.Format("2006-01-02T150405")), b.Identity)
+	if err != nil {
+		return err
+	}
+	r.brc = brc
+	return nil
+}
+
+// checkpointState is a checkpoint stored along with the state of a source.Stateful source.
+// Checkpoints of other sources are stored as a bare JSON time.
+type checkpointState struct {
+	Checkpoint time.Time       `json:"checkpoint"`
+	State      json.RawMessage `json:"state"`
+}
+
+func (b *Poster) getCheckpoint(ctx context.Context, src SourceConfig) (time.Time, json.RawMessage, string, error) {
+	checkpointStr, checkpointSHA, err := b.Repository.GetContent(ctx, src.CheckpointPath)
+	if err != nil && !errors.Is(err, backend.ErrFileNotFound) {
+		return time.Time{}, nil, "", err
+	}
+
+	if checkpointStr == "" {
+		return time.Time{}, nil, "", nil
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(checkpointStr), "{") {
+		var cs checkpointState
+		if err := json.Unmarshal([]byte(checkpointStr), &cs); err != nil {
+			return time.Time{}, nil, "", fmt.Errorf("error parsing checkpoint: %w", err)
+		}
+		return cs.Checkpoint, cs.State, checkpointSHA, nil
+	}
+
+	var checkpoint time.Time
+	err = json.Unmarshal([]byte(checkpointStr), &checkpoint)
+	if err != nil {
+		log.Printf("checkpoint is not JSON: %v", err)
+	} else {
+		return checkpoint, nil, checkpointSHA, nil
+	}
+
+	// fallback on legacy newsblur time format.
+	checkpoint, err = time.Parse("2006-01-02 15:04:05.999999", strings.Trim(checkpointStr, "\r\n"))
+	if err != nil {
+		return time.Time{}, nil, "", fmt.Errorf("error parsing checkpoint: %w", err)
+	}
+
+	return checkpoint, nil, checkpointSHA, nil
+}
+
+func (b *Poster) setCheckpoint(ctx context.Context, r *run, src SourceConfig, checkpoint time.Time, state json.RawMessage, checkpointSHA string) error {
+	var v any = checkpoint
+	if state != nil {
+		v = checkpointState{Checkpoint: checkpoint, State: state}
+	}
+	checkpointJSON, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if b.Batch {
+		r.files = append(r.files, backend.File{Path: src.CheckpointPath, Content: string(checkpointJSON)})
+		return nil
+	}
+
+	if err := b.openBranch(ctx, r); err != nil {
+		return err
+	}
+	gh := r.brc
+
+	commit := "auto: checkpoint"
+
+	if checkpointSHA == "" {
+		err = gh.CreateFile(ctx, commit, src.CheckpointPath, string(checkpointJSON))
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	err = gh.UpdateFile(ctx, commit, src.CheckpointPath, checkpointSHA, string(checkpointJSON))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,332 @@
+package poster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/archive"
+	"github.com/seriousben/positronic-blogger/internal/canonical"
+	"github.com/seriousben/positronic-blogger/internal/dedup"
+	"github.com/seriousben/positronic-blogger/internal/dryrun"
+	"github.com/seriousben/positronic-blogger/internal/github"
+	"github.com/seriousben/positronic-blogger/internal/github/githubtest"
+	"github.com/seriousben/positronic-blogger/internal/linkpreview"
+	"github.com/seriousben/positronic-blogger/internal/source"
+	"gotest.tools/v3/assert"
+)
+
+// sliceSource is a source of fixed items.
+type sliceSource []source.Item
+
+func (s sliceSource) Iterator(ctx context.Context, newerThan time.Time) (source.Iterator, error) {
+	var items []source.Item
+	for _, it := range s {
+		if it.Date.After(newerThan) {
+			items = append(items, it)
+		}
+	}
+	return &sliceIterator{items: items}, nil
+}
+
+type sliceIterator struct {
+	items []source.Item
+}
+
+func (it *sliceIterator) Next(ctx context.Context) (*source.Item, error) {
+	if len(it.items) == 0 {
+		return nil, io.EOF
+	}
+	item := it.items[0]
+	it.items = it.items[1:]
+	return &item, nil
+}
+
+func newTestRepository(t *testing.T, srv *githubtest.Server) *github.Client {
+	t.Helper()
+	c, err := github.New(context.Background(), "token", srv.Owner, srv.Repo,
+		github.WithBaseURL(srv.BaseURL()),
+		github.WithHTTPClient(srv.Client()),
+		github.WithRateLimit(time.Millisecond),
+	)
+	assert.NilError(t, err)
+	return c
+}
+
+var testItems = sliceSource{
+	{
+		ID:      "1",
+		Title:   "First",
+		URL:     "https://example.com/first?utm_source=rss",
+		Comment: "first thoughts",
+		Date:    time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC),
+	},
+	{
+		ID:      "2",
+		Title:   "Second",
+		URL:     "https://example.com/second",
+		Comment: "second thoughts",
+		Date:    time.Date(2022, 3, 2, 10, 0, 0, 0, time.UTC),
+	},
+}
+
+func Test_Poster_Run(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+
+	p, err := New(Config{
+		Repository: newTestRepository(t, srv),
+		Sources: []SourceConfig{{
+			Name:           "test",
+			Source:         testItems,
+			ContentPath:    "content/links",
+			CheckpointPath: "content/links/checkpoint",
+		}},
+		Canonicalizer: canonical.New(),
+	})
+	assert.NilError(t, err)
+
+	assert.NilError(t, p.Run(ctx))
+
+	files := srv.Files("main")
+	assert.Equal(t, len(files), 3)
+	assert.Equal(t, files["content/links/checkpoint"], `"2022-03-02T10:00:00Z"`)
+	first := files["content/links/2022-03-01-first.md"]
+	assert.Assert(t, first != "")
+	assert.Assert(t, !strings.Contains(first, "utm_source"), first)
+
+	prs := srv.PullRequests()
+	assert.Equal(t, len(prs), 1)
+	assert.Assert(t, prs[0].Merged)
+	assert.DeepEqual(t, srv.Branches(), []string{"main"})
+
+	// Nothing is published once the checkpoint is up to date.
+	assert.NilError(t, p.Run(ctx))
+	assert.Equal(t, len(srv.PullRequests()), 1)
+}
+
+func Test_Poster_Run_Batch(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+	srv.SetFile("main", "content/links/2022-01-01-old.md", "+++\noriginalUrl = \"https://example.com/second\"\n+++\n")
+
+	p, err := New(Config{
+		Repository: newTestRepository(t, srv),
+		Sources: []SourceConfig{{
+			Name:           "test",
+			Source:         testItems,
+			ContentPath:    "content/links",
+			CheckpointPath: "content/links/checkpoint",
+		}},
+		SkipMerge:  true,
+		Batch:      true,
+		Duplicates: dedup.PolicySkip,
+	})
+	assert.NilError(t, err)
+
+	sum, err := p.RunWithSummary(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, sum, Summary{Posts: 1, Duplicates: 1, Published: true})
+
+	prs := srv.PullRequests()
+	assert.Equal(t, len(prs), 1)
+	assert.Assert(t, !prs[0].Merged)
+
+	commits := srv.Commits(prs[0].Head)
+	assert.Equal(t, commits[0].Message, "auto: 1 new short post [skip ci]")
+	files := srv.Files(prs[0].Head)
+	assert.Equal(t, len(files), 3)
+	assert.Equal(t, files["content/links/checkpoint"], `"2022-03-02T10:00:00Z"`)
+}
+
+func Test_Poster_Run_Batch_Duplicates(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+	srv.SetFile("main", "content/links/2022-01-01-first.md", "+++\noriginalUrl = \"https://example.com/first\"\n+++\n")
+	srv.SetFile("main", "content/links/2022-01-02-second.md", "+++\noriginalUrl = \"https://example.com/second\"\n+++\n")
+
+	p, err := New(Config{
+		Repository: newTestRepository(t, srv),
+		Sources: []SourceConfig{{
+			Name:           "test",
+			Source:         testItems,
+			ContentPath:    "content/links",
+			CheckpointPath: "content/links/checkpoint",
+		}},
+		SkipMerge:     true,
+		Batch:         true,
+		Duplicates:    dedup.PolicySkip,
+		Canonicalizer: canonical.New(),
+	})
+	assert.NilError(t, err)
+
+	sum, err := p.RunWithSummary(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, sum, Summary{Posts: 0, Duplicates: 2, Published: true})
+
+	// Only the checkpoint moves.
+	prs := srv.PullRequests()
+	assert.Equal(t, len(prs), 1)
+	assert.Assert(t, strings.HasPrefix(prs[0].Body, "Checkpoint update"), prs[0].Body)
+	commits := srv.Commits(prs[0].Head)
+	assert.Equal(t, commits[0].Message, "auto: checkpoint [skip ci]")
+	assert.Equal(t, srv.Files(prs[0].Head)["content/links/checkpoint"], `"2022-03-02T10:00:00Z"`)
+}
+
+func Test_Poster_Run_Duplicates_OtherSources(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+	srv.SetFile("main", "content/notes/2022-01-01-first.md", "+++\noriginalUrl = \"https://example.com/first\"\n+++\n")
+
+	p, err := New(Config{
+		Repository: newTestRepository(t, srv),
+		Sources: []SourceConfig{
+			{
+				Name:           "test",
+				Source:         testItems,
+				ContentPath:    "content/links",
+				CheckpointPath: "content/links/checkpoint",
+			},
+			{
+				Name: "other",
+				Source: sliceSource{{
+					Title: "Second again",
+					URL:   "https://example.com/second",
+					Date:  time.Date(2022, 3, 3, 10, 0, 0, 0, time.UTC),
+				}},
+				ContentPath:    "content/other",
+				CheckpointPath: "content/other/checkpoint",
+			},
+		},
+		LookupPaths:   []string{"content/notes"},
+		Batch:         true,
+		Duplicates:    dedup.PolicySkip,
+		Canonicalizer: canonical.New(),
+	})
+	assert.NilError(t, err)
+
+	// Links posted under the content path of another source, or of a lookup path, are duplicates.
+	sum, err := p.RunWithSummary(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, sum, Summary{Posts: 1, Duplicates: 2, Published: true})
+	files := srv.Files("main")
+	assert.Assert(t, files["content/links/2022-03-02-second.md"] != "")
+	assert.Equal(t, files["content/links/2022-03-01-first.md"], "")
+	assert.Equal(t, files["content/other/2022-03-03-second-again.md"], "")
+}
+
+func Test_Poster_Run_DryRun(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+
+	repo := dryrun.New(newTestRepository(t, srv))
+	p, err := New(Config{
+		Repository: repo,
+		Sources: []SourceConfig{{
+			Name:           "test",
+			Source:         testItems,
+			ContentPath:    "content/links",
+			CheckpointPath: "content/links/checkpoint",
+		}},
+	})
+	assert.NilError(t, err)
+
+	assert.NilError(t, p.Run(ctx))
+
+	// Nothing is written to the repository.
+	assert.DeepEqual(t, srv.Branches(), []string{"main"})
+	assert.Equal(t, len(srv.PullRequests()), 0)
+
+	var paths []string
+	for _, c := range repo.Changes() {
+		paths = append(paths, c.Path)
+	}
+	assert.DeepEqual(t, paths, []string{
+		"content/links/2022-03-01-first.md",
+		"content/links/2022-03-02-second.md",
+		"content/links/checkpoint",
+	})
+}
+
+func Test_Poster_Run_Previewer(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html lang="en"><head>
+<meta property="og:site_name" content="Example">
+<meta property="og:description" content="What the page is about.">
+</head></html>`)
+	}))
+	defer page.Close()
+
+	p, err := New(Config{
+		Repository: newTestRepository(t, srv),
+		Sources: []SourceConfig{{
+			Name: "test",
+			Source: sliceSource{{
+				Title: "Previewed",
+				URL:   page.URL + "/article",
+				Date:  time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC),
+			}},
+			ContentPath:    "content/links",
+			CheckpointPath: "content/links/checkpoint",
+		}},
+		Previewer: linkpreview.New(linkpreview.WithHTTPClient(page.Client())),
+	})
+	assert.NilError(t, err)
+
+	assert.NilError(t, p.Run(ctx))
+
+	post := srv.Files("main")["content/links/2022-03-01-previewed.md"]
+	assert.Assert(t, strings.Contains(post, `site = "Example"
+description = "What the page is about."
+language = "en"
++++`), post)
+}
+
+func Test_Poster_Run_Archiver(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><nav>Menu</nav><article><p>The article.</p></article></body></html>`)
+	}))
+	defer page.Close()
+
+	p, err := New(Config{
+		Repository: newTestRepository(t, srv),
+		Sources: []SourceConfig{{
+			Name: "test",
+			Source: sliceSource{{
+				Title: "Archived",
+				URL:   page.URL + "/article",
+				Date:  time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC),
+			}},
+			ContentPath:    "content/links",
+			CheckpointPath: "content/links/checkpoint",
+		}},
+		Archiver: archive.New(archive.WithCopy("content/mirror"), archive.WithHTTPClient(page.Client())),
+	})
+	assert.NilError(t, err)
+
+	assert.NilError(t, p.Run(ctx))
+
+	files := srv.Files("main")
+	post := files["content/links/2022-03-01-archived.md"]
+	assert.Assert(t, strings.Contains(post, `archivePath = "content/mirror/2022-03-01-archived.md"`), post)
+	mirror := files["content/mirror/2022-03-01-archived.md"]
+	assert.Assert(t, strings.HasSuffix(mirror, "+++\n\nThe article.\n"), mirror)
+}
@@ -0,0 +1,41 @@
+// Package ratelimit spaces out the requests made to a site or an API.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter spaces out requests by a minimum delay.
+type Limiter struct {
+	mu    sync.Mutex
+	delay time.Duration
+	next  time.Time
+}
+
+// New returns a limiter allowing a request every delay, the first one right away.
+func New(delay time.Duration) *Limiter {
+	return &Limiter{delay: delay}
+}
+
+// Wait blocks until another request can be made, or until ctx is done.
+// The time of the request is reserved before waiting, so that concurrent callers
+// wait for their own turn instead of for each other.
+func (l *Limiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.delay)
+	l.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(at)):
+		return nil
+	}
+}
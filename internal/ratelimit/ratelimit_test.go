@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func Test_Limiter_Wait(t *testing.T) {
+	ctx := context.Background()
+	l := New(20 * time.Millisecond)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NilError(t, l.Wait(ctx))
+		}()
+	}
+	wg.Wait()
+	// The first request is made right away, the others are spaced by the delay.
+	assert.Assert(t, time.Since(start) >= 40*time.Millisecond, time.Since(start))
+}
+
+func Test_Limiter_Wait_Canceled(t *testing.T) {
+	l := New(time.Hour)
+	assert.NilError(t, l.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+	assert.Assert(t, time.Since(start) < time.Minute)
+
+	// A canceled wait does not hold up other callers.
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+}
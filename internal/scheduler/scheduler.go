@@ -0,0 +1,139 @@
+// Package scheduler runs a job periodically, on a fixed interval or a cron schedule.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule returns the next time a job runs after t.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// Every returns a schedule running a job every d.
+func Every(d time.Duration) Schedule {
+	return every(d)
+}
+
+// Parse parses a Go duration, such as 15m, or a standard five fields cron expression,
+// such as */15 * * * * or @hourly.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, err := time.ParseDuration(spec); err == nil {
+		if d <= 0 {
+			return nil, fmt.Errorf("schedule interval %s must be positive", d)
+		}
+		return Every(d), nil
+	}
+	s, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("schedule %q is neither a duration nor a cron expression: %w", spec, err)
+	}
+	return s, nil
+}
+
+// Job is a scheduled job. Its error is logged and does not stop the scheduler.
+type Job func(ctx context.Context) error
+
+// Scheduler runs a job on a schedule.
+// Runs never overlap: the runs missed while a job is running are skipped.
+type Scheduler struct {
+	Schedule Schedule
+	// Jitter delays each run by a random duration up to Jitter,
+	// spreading the load of many instances sharing a schedule.
+	Jitter time.Duration
+	// RunOnStart runs the job immediately instead of waiting for the first scheduled time.
+	RunOnStart bool
+	Job        Job
+
+	now   func() time.Time
+	after func(d time.Duration) <-chan time.Time
+}
+
+func (s *Scheduler) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+func (s *Scheduler) wait(d time.Duration) <-chan time.Time {
+	if s.after != nil {
+		return s.after(d)
+	}
+	return time.After(d)
+}
+
+func (s *Scheduler) jitter() time.Duration {
+	if s.Jitter <= 0 {
+		return 0
+	}
+	return rand.N(s.Jitter)
+}
+
+// Run runs the job on schedule until ctx is done.
+// A run in progress when ctx is done completes before Run returns,
+// so a sync is never interrupted halfway through.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if s.Schedule == nil || s.Job == nil {
+		return fmt.Errorf("scheduler needs a schedule and a job")
+	}
+
+	var (
+		runs int
+		next = s.clock()
+	)
+	if !s.RunOnStart {
+		next = s.Schedule.Next(next)
+	}
+
+	for {
+		if ctx.Err() != nil {
+			log.Printf("scheduler: stopping after %d runs", runs)
+			return nil
+		}
+
+		at := next.Add(s.jitter())
+		log.Printf("scheduler: next run at %s", at.Format(time.RFC3339))
+
+		select {
+		case <-ctx.Done():
+			continue
+		case <-s.wait(at.Sub(s.clock())):
+		}
+
+		runs++
+		start := s.clock()
+		err := s.Job(context.WithoutCancel(ctx))
+		if err != nil {
+			log.Printf("scheduler: run %d failed after %s: %v", runs, s.clock().Sub(start).Round(time.Millisecond), err)
+		} else {
+			log.Printf("scheduler: run %d succeeded in %s", runs, s.clock().Sub(start).Round(time.Millisecond))
+		}
+
+		// Skip the runs missed while the job was running.
+		now := s.clock()
+		next = s.Schedule.Next(next)
+		skipped := 0
+		for !next.After(now) {
+			next = s.Schedule.Next(next)
+			skipped++
+		}
+		if skipped > 0 {
+			log.Printf("scheduler: run %d overran the schedule, skipped %d runs", runs, skipped)
+		}
+	}
+}
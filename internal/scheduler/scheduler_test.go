@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// fakeClock advances instantly to the end of every wait.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func Test_Scheduler(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var starts []time.Time
+	s := &Scheduler{
+		Schedule: Every(10 * time.Minute),
+		Job: func(jobCtx context.Context) error {
+			starts = append(starts, clock.now)
+			switch len(starts) {
+			case 2:
+				// Overrun two scheduled runs.
+				clock.now = clock.now.Add(25 * time.Minute)
+				return errors.New("boom")
+			case 4:
+				// Runs in progress complete when the scheduler is stopped.
+				cancel()
+				assert.NilError(t, jobCtx.Err())
+			}
+			return nil
+		},
+		now:   clock.Now,
+		after: clock.After,
+	}
+
+	assert.NilError(t, s.Run(ctx))
+
+	base := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.DeepEqual(t, starts, []time.Time{
+		base.Add(10 * time.Minute),
+		base.Add(20 * time.Minute),
+		base.Add(50 * time.Minute),
+		base.Add(60 * time.Minute),
+	})
+}
+
+func Test_Scheduler_RunOnStartJitter(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var starts []time.Time
+	s := &Scheduler{
+		Schedule:   Every(time.Hour),
+		Jitter:     time.Minute,
+		RunOnStart: true,
+		Job: func(context.Context) error {
+			starts = append(starts, clock.now)
+			if len(starts) == 3 {
+				cancel()
+			}
+			return nil
+		},
+		now:   clock.Now,
+		after: clock.After,
+	}
+
+	assert.NilError(t, s.Run(ctx))
+
+	base := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, start := range starts {
+		scheduled := base.Add(time.Duration(i) * time.Hour)
+		assert.Assert(t, !start.Before(scheduled) && start.Before(scheduled.Add(time.Minute)), "run %d at %s", i, start)
+	}
+}
+
+func Test_Parse(t *testing.T) {
+	from := time.Date(2022, 1, 1, 10, 7, 0, 0, time.UTC)
+
+	s, err := Parse("15m")
+	assert.NilError(t, err)
+	assert.Equal(t, s.Next(from), from.Add(15*time.Minute))
+
+	s, err = Parse("*/15 * * * *")
+	assert.NilError(t, err)
+	assert.Equal(t, s.Next(from), time.Date(2022, 1, 1, 10, 15, 0, 0, time.UTC))
+
+	s, err = Parse("@hourly")
+	assert.NilError(t, err)
+	assert.Equal(t, s.Next(from), time.Date(2022, 1, 1, 11, 0, 0, 0, time.UTC))
+
+	_, err = Parse("-1m")
+	assert.ErrorContains(t, err, "must be positive")
+	_, err = Parse("every day")
+	assert.ErrorContains(t, err, "neither a duration nor a cron expression")
+}
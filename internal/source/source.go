@@ -0,0 +1,53 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Item is a source-neutral piece of content that can be turned into a post.
+type Item struct {
+	ID      string
+	Title   string
+	URL     string
+	Comment string
+	Date    time.Time
+
+	// The following metadata is optional, sources fill what they know.
+
+	Author string
+	// Site and SiteURL are the name and home page of the site the item comes from.
+	Site    string
+	SiteURL string
+	Tags    []string
+	// Content is the HTML content of the item.
+	Content string
+	// Image is the URL of an image illustrating the item.
+	Image string
+	// DiscussionURL is the URL of a discussion of the item, such as a Hacker News thread.
+	DiscussionURL string
+}
+
+// Iterator walks the items of a source, newest first.
+// Next returns io.EOF once there are no more items to process.
+type Iterator interface {
+	Next(ctx context.Context) (*Item, error)
+}
+
+// Source is a provider of content items, such as NewsBlur shared stories.
+type Source interface {
+	// Iterator returns an iterator over the items strictly newer than newerThan.
+	Iterator(ctx context.Context, newerThan time.Time) (Iterator, error)
+}
+
+// Stateful is implemented by sources keeping state across runs, such as the validators
+// of a feed or the last item they returned. The state is stored along with the checkpoint of the source.
+type Stateful interface {
+	// SetState restores the state stored by a previous run before Iterator is called.
+	// The state is nil when none was stored.
+	SetState(state json.RawMessage) error
+	// State returns the state to store once the items of the last iterator are posted,
+	// nil when there is none.
+	State() (json.RawMessage, error)
+}
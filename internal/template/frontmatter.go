@@ -0,0 +1,321 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a front matter serialization format.
+type Format string
+
+const (
+	FormatTOML Format = "toml"
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatTOML, FormatYAML, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown front matter format %q, expected one of toml, yaml or json", s)
+	}
+}
+
+// Field is a front matter key and its value.
+// Values are either a string, a time.Time or a []string.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// FrontMatter returns the ordered front matter fields of the post.
+// Optional metadata fields are only included when set.
+func (p Post) FrontMatter() []Field {
+	fields := []Field{
+		{Key: "date", Value: p.Date},
+		{Key: "publishDate", Value: p.Date},
+		{Key: "title", Value: p.Title},
+		{Key: "originalUrl", Value: p.URL},
+		{Key: "comment", Value: p.Comment},
+	}
+	for _, f := range []Field{
+		{Key: "author", Value: p.Author},
+		{Key: "site", Value: p.Site},
+		{Key: "siteUrl", Value: p.SiteURL},
+		{Key: "image", Value: p.Image},
+		{Key: "description", Value: p.Description},
+		{Key: "language", Value: p.Language},
+		{Key: "archiveUrl", Value: p.ArchiveURL},
+		{Key: "archivePath", Value: p.ArchivePath},
+		{Key: "discussionUrl", Value: p.DiscussionURL},
+	} {
+		if f.Value != "" {
+			fields = append(fields, f)
+		}
+	}
+	if !p.Published.IsZero() {
+		fields = append(fields, Field{Key: "published", Value: p.Published})
+	}
+	if len(p.Tags) > 0 {
+		fields = append(fields, Field{Key: "tags", Value: p.Tags})
+	}
+	return fields
+}
+
+// Encode serializes fields, including the format's delimiters.
+func (f Format) Encode(fields []Field) (string, error) {
+	buf := new(bytes.Buffer)
+
+	switch f {
+	case FormatTOML:
+		buf.WriteString("+++\n")
+		for _, fi := range fields {
+			v, err := encodeValue(fi.Value)
+			if err != nil {
+				return "", fmt.Errorf("encoding %s: %w", fi.Key, err)
+			}
+			fmt.Fprintf(buf, "%s = %s\n", fi.Key, v)
+		}
+		buf.WriteString("+++")
+	case FormatYAML:
+		buf.WriteString("---\n")
+		for _, fi := range fields {
+			v, err := encodeValue(fi.Value)
+			if err != nil {
+				return "", fmt.Errorf("encoding %s: %w", fi.Key, err)
+			}
+			fmt.Fprintf(buf, "%s: %s\n", fi.Key, v)
+		}
+		buf.WriteString("---")
+	case FormatJSON:
+		buf.WriteString("{\n")
+		for i, fi := range fields {
+			k, err := encodeValue(fi.Key)
+			if err != nil {
+				return "", err
+			}
+			v, err := encodeValue(fi.Value)
+			if err != nil {
+				return "", fmt.Errorf("encoding %s: %w", fi.Key, err)
+			}
+			sep := ","
+			if i == len(fields)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(buf, "  %s: %s%s\n", k, v, sep)
+		}
+		buf.WriteString("}")
+	default:
+		return "", fmt.Errorf("unknown front matter format %q", f)
+	}
+
+	return buf.String(), nil
+}
+
+// encodeValue encodes v as a JSON string or array of strings.
+// JSON string escapes are all valid escapes in TOML basic strings
+// and YAML double-quoted scalars, making the output valid for every format.
+func encodeValue(v any) (string, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return quoteString(v.Format(time.RFC3339Nano)), nil
+	case string:
+		return quoteString(v), nil
+	case []string:
+		elems := make([]string, 0, len(v))
+		for _, s := range v {
+			elems = append(elems, quoteString(s))
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	default:
+		return "", fmt.Errorf("unsupported front matter value type %T", v)
+	}
+}
+
+func quoteString(s string) string {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	// Encoding a string cannot fail.
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// ParseFrontMatter decodes the front matter of a markdown document
+// in any of the supported formats.
+func ParseFrontMatter(content string) (Format, map[string]any, error) {
+	fm := map[string]any{}
+	content = strings.TrimPrefix(content, "\ufeff")
+
+	switch {
+	case strings.HasPrefix(content, "+++"):
+		raw, err := delimited(content, "+++")
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := toml.Decode(raw, &fm); err != nil {
+			return "", nil, fmt.Errorf("decoding TOML front matter: %w", err)
+		}
+		return FormatTOML, fm, nil
+	case strings.HasPrefix(content, "---"):
+		raw, err := delimited(content, "---")
+		if err != nil {
+			return "", nil, err
+		}
+		if err := yaml.Unmarshal([]byte(raw), &fm); err != nil {
+			return "", nil, fmt.Errorf("decoding YAML front matter: %w", err)
+		}
+		return FormatYAML, fm, nil
+	case strings.HasPrefix(content, "{"):
+		dec := json.NewDecoder(strings.NewReader(content))
+		if err := dec.Decode(&fm); err != nil {
+			return "", nil, fmt.Errorf("decoding JSON front matter: %w", err)
+		}
+		return FormatJSON, fm, nil
+	default:
+		return "", nil, errors.New("no front matter")
+	}
+}
+
+// SetFrontMatterField sets the string field key of the front matter of a document,
+// adding it when missing. The rest of the document is kept as it is.
+func SetFrontMatterField(content, key, value string) (string, error) {
+	format, _, err := ParseFrontMatter(content)
+	if err != nil {
+		return "", err
+	}
+	v := quoteString(value)
+
+	if format == FormatJSON {
+		return setJSONField(content, key, v)
+	}
+
+	delim, sep := "+++", " = "
+	if format == FormatYAML {
+		delim, sep = "---", ": "
+	}
+	keyRegex := regexp.MustCompile(`^` + regexp.QuoteMeta(key) + `\s*` + regexp.QuoteMeta(strings.TrimSpace(sep)))
+
+	lines := strings.SplitAfter(content, "\n")
+	// The first line is the opening delimiter.
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		eol := lines[i][len(line):]
+		if keyRegex.MatchString(line) {
+			lines[i] = key + sep + v + eol
+			return strings.Join(lines, ""), nil
+		}
+		// Top level TOML keys must come before the first table.
+		if line == delim || (format == FormatTOML && strings.HasPrefix(line, "[")) {
+			lines = slices.Insert(lines, i, key+sep+v+"\n")
+			return strings.Join(lines, ""), nil
+		}
+	}
+	return "", fmt.Errorf("unterminated %s front matter", delim)
+}
+
+func setJSONField(content, key, v string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(content))
+	var obj json.RawMessage
+	if err := dec.Decode(&obj); err != nil {
+		return "", fmt.Errorf("decoding JSON front matter: %w", err)
+	}
+	end := int(dec.InputOffset())
+	fm, rest := content[:end], content[end:]
+
+	k := quoteString(key)
+	fieldRegex := regexp.MustCompile(regexp.QuoteMeta(k) + `\s*:\s*"(?:[^"\\]|\\.)*"`)
+	if loc := fieldRegex.FindStringIndex(fm); loc != nil {
+		return fm[:loc[0]] + k + ": " + v + fm[loc[1]:] + rest, nil
+	}
+
+	closing := strings.LastIndex(fm, "}")
+	body := strings.TrimRight(fm[:closing], " \t\r\n")
+	sep := ","
+	if strings.HasSuffix(body, "{") {
+		sep = ""
+	}
+	return body + sep + "\n  " + k + ": " + v + "\n" + fm[closing:] + rest, nil
+}
+
+// delimited returns the front matter between the opening and closing delim lines.
+func delimited(content, delim string) (string, error) {
+	_, rest, ok := strings.Cut(content, "\n")
+	if !ok {
+		return "", fmt.Errorf("unterminated %s front matter", delim)
+	}
+	rest = strings.ReplaceAll(rest, "\r\n", "\n")
+	if strings.HasPrefix(rest, delim) {
+		return "", nil
+	}
+	raw, _, ok := strings.Cut(rest, "\n"+delim)
+	if !ok {
+		return "", fmt.Errorf("unterminated %s front matter", delim)
+	}
+	return raw, nil
+}
+
+// Generator describes the conventions of a static site generator.
+type Generator struct {
+	Name        string
+	Format      Format
+	ContentPath string
+	FileName    func(p Post) string
+}
+
+func datedFileName(p Post) string {
+	return p.FileName()
+}
+
+func slugFileName(p Post) string {
+	return fmt.Sprintf("%s.md", p.Slug())
+}
+
+// Generators are the presets of supported static site generators.
+var Generators = map[string]Generator{
+	"hugo": {
+		Name:        "hugo",
+		Format:      FormatTOML,
+		ContentPath: "content/links",
+		FileName:    datedFileName,
+	},
+	"jekyll": {
+		Name:        "jekyll",
+		Format:      FormatYAML,
+		ContentPath: "_posts",
+		FileName:    datedFileName,
+	},
+	"eleventy": {
+		Name:        "eleventy",
+		Format:      FormatYAML,
+		ContentPath: "posts",
+		FileName:    datedFileName,
+	},
+	"astro": {
+		Name:        "astro",
+		Format:      FormatYAML,
+		ContentPath: "src/content/links",
+		FileName:    slugFileName,
+	},
+}
+
+// LookupGenerator returns the preset of the generator named name.
+func LookupGenerator(name string) (Generator, error) {
+	g, ok := Generators[strings.ToLower(name)]
+	if !ok {
+		return Generator{}, fmt.Errorf("unknown generator %q, expected one of hugo, jekyll, eleventy or astro", name)
+	}
+	return g, nil
+}
@@ -0,0 +1,166 @@
+package template
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func Test_Format_Encode(t *testing.T) {
+	p := testPost
+	p.Title = "Tabs\tand \\ backslashes: é"
+
+	yaml, err := FormatYAML.Encode(p.FrontMatter())
+	assert.NilError(t, err)
+	assert.Equal(t, yaml, `---
+date: "2022-03-04T05:06:07Z"
+publishDate: "2022-03-04T05:06:07Z"
+title: "Tabs\tand \\ backslashes: é"
+originalUrl: "https://example.com/article"
+comment: "Great read.\nSecond line."
+---`)
+
+	js, err := FormatJSON.Encode(p.FrontMatter())
+	assert.NilError(t, err)
+	var decoded map[string]string
+	assert.NilError(t, json.Unmarshal([]byte(js), &decoded))
+	assert.Equal(t, decoded["title"], p.Title)
+	assert.Equal(t, decoded["comment"], p.Comment)
+
+	list, err := FormatTOML.Encode([]Field{{Key: "tags", Value: []string{"go", `"quoted"`}}})
+	assert.NilError(t, err)
+	assert.Equal(t, list, "+++\ntags = [\"go\", \"\\\"quoted\\\"\"]\n+++")
+
+	_, err = ParseFormat("xml")
+	assert.ErrorContains(t, err, "unknown front matter format")
+}
+
+func Test_FrontMatter_Metadata(t *testing.T) {
+	p := testPost
+	p.Author = "Jane Doe"
+	p.Site = "Example Blog"
+	p.SiteURL = "https://example.com/"
+	p.Tags = []string{"go", "generics"}
+	p.Image = "https://example.com/hero.png"
+	p.Content = "<p>Not in the front matter.</p>"
+	p.DiscussionURL = "https://news.ycombinator.com/item?id=1"
+
+	fm, err := FormatTOML.Encode(p.FrontMatter())
+	assert.NilError(t, err)
+	assert.Equal(t, fm, `+++
+date = "2022-03-04T05:06:07Z"
+publishDate = "2022-03-04T05:06:07Z"
+title = "A \"quoted\" title"
+originalUrl = "https://example.com/article"
+comment = "Great read.\nSecond line."
+author = "Jane Doe"
+site = "Example Blog"
+siteUrl = "https://example.com/"
+image = "https://example.com/hero.png"
+discussionUrl = "https://news.ycombinator.com/item?id=1"
+tags = ["go", "generics"]
++++`)
+}
+
+func Test_Template_WithGenerator(t *testing.T) {
+	g, err := LookupGenerator("jekyll")
+	assert.NilError(t, err)
+
+	tmpl, err := Default.WithGenerator(g)
+	assert.NilError(t, err)
+	assert.Equal(t, tmpl.Format(), FormatYAML)
+	assert.Equal(t, tmpl.FileName(testPost), "2022-03-04-a-quoted-title.md")
+
+	buf, err := tmpl.Render(testPost)
+	assert.NilError(t, err)
+	assert.Equal(t, buf.String()[:4], "---\n")
+
+	// Default is left untouched.
+	buf, err = Default.Render(testPost)
+	assert.NilError(t, err)
+	assert.Equal(t, buf.String()[:4], "+++\n")
+
+	g, err = LookupGenerator("astro")
+	assert.NilError(t, err)
+	tmpl, err = Default.WithGenerator(g)
+	assert.NilError(t, err)
+	assert.Equal(t, tmpl.FileName(testPost), "a-quoted-title.md")
+
+	_, err = LookupGenerator("gatsby")
+	assert.ErrorContains(t, err, "unknown generator")
+}
+
+func Test_ParseFrontMatter(t *testing.T) {
+	for _, f := range []Format{FormatTOML, FormatYAML, FormatJSON} {
+		tmpl, err := Default.WithFormat(f)
+		assert.NilError(t, err)
+		buf, err := tmpl.Render(testPost)
+		assert.NilError(t, err)
+
+		format, fm, err := ParseFrontMatter(buf.String())
+		assert.NilError(t, err)
+		assert.Equal(t, format, f)
+		assert.Equal(t, fm["originalUrl"], testPost.URL)
+		assert.Equal(t, fm["title"], testPost.Title)
+	}
+
+	_, _, err := ParseFrontMatter("# No front matter")
+	assert.ErrorContains(t, err, "no front matter")
+
+	_, _, err = ParseFrontMatter("+++\ntitle = \"unterminated\"\n")
+	assert.ErrorContains(t, err, "unterminated")
+}
+
+func Test_SetFrontMatterField(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		key     string
+		want    string
+	}{
+		{
+			name:    "toml replace",
+			content: "+++\ntitle = \"T\"\noriginalUrl = \"http://old\"\n+++\n\nbody http://old\n",
+			key:     "originalUrl",
+			want:    "+++\ntitle = \"T\"\noriginalUrl = \"https://new\"\n+++\n\nbody http://old\n",
+		},
+		{
+			name:    "toml add before tables",
+			content: "+++\ntitle = \"T\"\n[params]\nx = 1\n+++\nbody\n",
+			key:     "archiveUrl",
+			want:    "+++\ntitle = \"T\"\narchiveUrl = \"https://new\"\n[params]\nx = 1\n+++\nbody\n",
+		},
+		{
+			name:    "yaml add",
+			content: "---\ntitle: T\r\noriginalUrlExtra: x\r\n---\r\nbody\n",
+			key:     "originalUrl",
+			want:    "---\ntitle: T\r\noriginalUrlExtra: x\r\noriginalUrl: \"https://new\"\n---\r\nbody\n",
+		},
+		{
+			name:    "json replace",
+			content: "{\n  \"title\": \"T\",\n  \"originalUrl\": \"http://o\\\"ld\"\n}\n\nbody\n",
+			key:     "originalUrl",
+			want:    "{\n  \"title\": \"T\",\n  \"originalUrl\": \"https://new\"\n}\n\nbody\n",
+		},
+		{
+			name:    "json add",
+			content: "{\n  \"title\": \"T\"\n}\n\nbody\n",
+			key:     "archiveUrl",
+			want:    "{\n  \"title\": \"T\",\n  \"archiveUrl\": \"https://new\"\n}\n\nbody\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := SetFrontMatterField(tc.content, tc.key, "https://new")
+			assert.NilError(t, err)
+			assert.Equal(t, got, tc.want)
+
+			_, fm, err := ParseFrontMatter(got)
+			assert.NilError(t, err)
+			assert.Equal(t, fm[tc.key], "https://new")
+		})
+	}
+
+	_, err := SetFrontMatterField("no front matter", "title", "x")
+	assert.ErrorContains(t, err, "no front matter")
+}
@@ -2,12 +2,16 @@ package template
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
 	"github.com/gosimple/slug"
+	"github.com/seriousben/positronic-blogger/internal/backend"
 )
 
 const (
@@ -15,43 +19,179 @@ const (
 )
 
 var (
-	postTemplate = `+++
-date = "{{ .Date | timeFormat }}"
-publishDate = "{{ .Date | timeFormat }}"
-title = {{ .Title | quote }}
-originalUrl = "{{.URL}}"
-comment = {{.Comment | quote}}
-+++
+	postTemplate = `{{ frontMatter . }}
 
 ### My thoughts
 
 {{.Comment}}
 
 Read the article: [{{.Title}}]({{.URL}})
+{{- with .ArchiveURL }} ([mirror]({{ . }})){{ end }}
 `
-	tmpl = template.Must(template.New("short").Funcs(template.FuncMap{
-		"quote": strconv.Quote,
-		"timeFormat": func(t time.Time) string {
-			return t.Format(time.RFC3339Nano)
-		},
-	}).Parse(postTemplate))
+	// Default is the template used when no custom template is configured.
+	Default = Must(Parse("short", postTemplate))
 )
 
+// Funcs are the helpers available to post templates.
+var Funcs = template.FuncMap{
+	"quote": strconv.Quote,
+	"timeFormat": func(t time.Time) string {
+		return t.Format(time.RFC3339Nano)
+	},
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"slug":      slug.Make,
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"trim":      strings.TrimSpace,
+	"replace":   func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"contains":  func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix": func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"join":      func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	"default": func(def string, s string) string {
+		if s == "" {
+			return def
+		}
+		return s
+	},
+	// indent prefixes every line of s, for example to render a blockquote.
+	"indent": func(prefix, s string) string {
+		return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+	},
+	// frontMatter renders the front matter of a post in the format of the template.
+	"frontMatter": frontMatterFunc(FormatTOML),
+}
+
+func frontMatterFunc(f Format) func(p Post) (string, error) {
+	return func(p Post) (string, error) {
+		return f.Encode(p.FrontMatter())
+	}
+}
+
+// Template renders posts to markdown using a Go text/template.
+type Template struct {
+	tmpl     *template.Template
+	format   Format
+	fileName func(p Post) string
+}
+
+// Parse parses text as a post template. All Funcs are available to the template.
+func Parse(name, text string) (*Template, error) {
+	tmpl, err := template.New(name).Funcs(Funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %s: %w", name, err)
+	}
+	return &Template{tmpl: tmpl, format: FormatTOML}, nil
+}
+
+// WithFormat returns a copy of the template rendering front matter in format f.
+func (t *Template) WithFormat(f Format) (*Template, error) {
+	tmpl, err := t.tmpl.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("cloning template: %w", err)
+	}
+	tmpl.Funcs(template.FuncMap{"frontMatter": frontMatterFunc(f)})
+	return &Template{tmpl: tmpl, format: f, fileName: t.fileName}, nil
+}
+
+// WithGenerator returns a copy of the template following the front matter format
+// and file naming conventions of generator g.
+func (t *Template) WithGenerator(g Generator) (*Template, error) {
+	nt, err := t.WithFormat(g.Format)
+	if err != nil {
+		return nil, err
+	}
+	nt.fileName = g.FileName
+	return nt, nil
+}
+
+// Format returns the front matter format of the template.
+func (t *Template) Format() Format {
+	return t.format
+}
+
+// FileName returns the file name of the post following the template's generator conventions.
+func (t *Template) FileName(p Post) string {
+	if t.fileName == nil {
+		return p.FileName()
+	}
+	return t.fileName(p)
+}
+
+// Must panics when err is not nil.
+func Must(t *Template, err error) *Template {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Load parses the template stored in a local file.
+func Load(path string) (*Template, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading template: %w", err)
+	}
+	return Parse(path, string(b))
+}
+
+// Fetch parses the template stored at path in the repository.
+func Fetch(ctx context.Context, repo backend.Repository, path string) (*Template, error) {
+	content, _, err := repo.GetContent(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("fetching template: %w", err)
+	}
+	return Parse(path, content)
+}
+
+func (t *Template) Render(p Post) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	if err := t.tmpl.Execute(buf, p); err != nil {
+		return nil, fmt.Errorf("executing template: %w", err)
+	}
+	return buf, nil
+}
+
 type Post struct {
 	Title   string
 	URL     string
 	Comment string
 	Date    time.Time
+
+	// Optional metadata about the linked article, empty when the source does not provide it.
+
+	Author string
+	// Site and SiteURL are the name and home page of the site publishing the article.
+	Site    string
+	SiteURL string
+	Tags    []string
+	// Content is the HTML content of the article.
+	Content string
+	// Image is the URL of the hero image of the article.
+	Image       string
+	Description string
+	// Published is when the article was published, zero when unknown.
+	Published time.Time
+	// Language is the BCP 47 language tag of the article, such as en or en-US.
+	Language string
+	// ArchiveURL is the URL of a snapshot of the article, its mirror when it disappears.
+	ArchiveURL string
+	// ArchivePath is the path in the repository of a copy of the article.
+	ArchivePath string
+	// DiscussionURL is the URL of a discussion of the article, such as a Hacker News thread.
+	DiscussionURL string
 }
 
+// ToMarkdown renders the post using the Default template.
 func (p Post) ToMarkdown() (*bytes.Buffer, error) {
-	buf := new(bytes.Buffer)
-	if err := tmpl.Execute(buf, p); err != nil {
-		return nil, fmt.Errorf("executing template: %w", err)
-	}
-	return buf, nil
+	return Default.Render(p)
 }
 
 func (p Post) FileName() string {
-	return fmt.Sprintf("%s-%s.md", p.Date.Format(postTimeFormat), slug.Make(p.Title))
+	return fmt.Sprintf("%s-%s.md", p.Date.Format(postTimeFormat), p.Slug())
+}
+
+func (p Post) Slug() string {
+	return slug.Make(p.Title)
 }
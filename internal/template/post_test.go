@@ -0,0 +1,74 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+var testPost = Post{
+	Title:   `A "quoted" title`,
+	URL:     "https://example.com/article",
+	Comment: "Great read.\nSecond line.",
+	Date:    time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC),
+}
+
+func Test_Post_ToMarkdown(t *testing.T) {
+	buf, err := testPost.ToMarkdown()
+	assert.NilError(t, err)
+	assert.Equal(t, buf.String(), `+++
+date = "2022-03-04T05:06:07Z"
+publishDate = "2022-03-04T05:06:07Z"
+title = "A \"quoted\" title"
+originalUrl = "https://example.com/article"
+comment = "Great read.\nSecond line."
++++
+
+### My thoughts
+
+Great read.
+Second line.
+
+Read the article: [A "quoted" title](https://example.com/article)
+`)
+	assert.Equal(t, testPost.FileName(), "2022-03-04-a-quoted-title.md")
+
+	archived := testPost
+	archived.ArchiveURL = "https://web.archive.org/web/20220304050607/https://example.com/article"
+	buf, err = archived.ToMarkdown()
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasSuffix(buf.String(), `
+Read the article: [A "quoted" title](https://example.com/article) ([mirror](https://web.archive.org/web/20220304050607/https://example.com/article))
+`), buf.String())
+}
+
+func Test_Template(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "post.tmpl")
+	err := os.WriteFile(path, []byte(`# {{ .Title | upper }}
+{{ date "Jan 2, 2006" .Date }} {{ .Title | slug }}
+
+{{ .Comment | indent "> " }}
+{{ "" | default "no comment" }}
+`), 0o644)
+	assert.NilError(t, err)
+
+	tmpl, err := Load(path)
+	assert.NilError(t, err)
+
+	buf, err := tmpl.Render(testPost)
+	assert.NilError(t, err)
+	assert.Equal(t, buf.String(), `# A "QUOTED" TITLE
+Mar 4, 2022 a-quoted-title
+
+> Great read.
+> Second line.
+no comment
+`)
+
+	_, err = Parse("invalid", "{{ .Title ")
+	assert.ErrorContains(t, err, "parsing template invalid")
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/seriousben/positronic-blogger/internal/linkpreview"
+)
+
+const (
+	// prefillTimeout bounds the fetch of the linked page,
+	// Discord expects the modal within 3 seconds of the command.
+	prefillTimeout = 2 * time.Second
+
+	maxTitleLength       = 300
+	maxURLLength         = 2000
+	maxPlaceholderLength = 100
+)
+
+// prefill is what the post modal is prefilled with.
+type prefill struct {
+	Title       string
+	URL         string
+	Description string
+}
+
+// newPrefillFetcher returns the fetcher of the pages linked from the slash command.
+func newPrefillFetcher() *linkpreview.Fetcher {
+	return linkpreview.New(linkpreview.WithTimeout(prefillTimeout))
+}
+
+// lookupPrefill fetches the title and description of the page at rawURL.
+// The title is left empty, for the user to type it, when the page cannot be fetched.
+func lookupPrefill(ctx context.Context, f *linkpreview.Fetcher, rawURL string) prefill {
+	pf := prefill{URL: strings.TrimSpace(rawURL)}
+	p, err := f.Fetch(ctx, pf.URL)
+	if err != nil {
+		log.Printf("error prefilling %s: %v", pf.URL, err)
+		return pf
+	}
+	pf.Title = p.Title
+	pf.Description = p.Description
+	return pf
+}
+
+// postModal returns the modal asking for the link to post, prefilled with pf.
+func postModal(pf prefill) *discordgo.InteractionResponse {
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: "serious-post",
+			Title:    "Post a new curated link",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:  "title",
+							Label:     "Title of article",
+							Style:     discordgo.TextInputShort,
+							Value:     truncate(pf.Title, maxTitleLength),
+							Required:  true,
+							MaxLength: maxTitleLength,
+							MinLength: 1,
+						},
+					},
+				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:  "URL",
+							Label:     "URL of article",
+							Style:     discordgo.TextInputShort,
+							Value:     urlValue(pf.URL),
+							Required:  true,
+							MaxLength: maxURLLength,
+							MinLength: 1,
+						},
+					},
+				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "thoughts",
+							Label:       "Thoughts about the article",
+							Style:       discordgo.TextInputParagraph,
+							Placeholder: truncate(pf.Description, maxPlaceholderLength),
+							Required:    false,
+							MaxLength:   2000,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// urlValue returns the prefilled URL, a truncated URL being worse than none.
+func urlValue(u string) string {
+	if len([]rune(u)) > maxURLLength {
+		return ""
+	}
+	return u
+}
+
+// truncate shortens s to at most n characters, ending it with an ellipsis when shortened.
+func truncate(s string, n int) string {
+	r := []rune(strings.Join(strings.Fields(s), " "))
+	if len(r) <= n {
+		return string(r)
+	}
+	return string(r[:n-1]) + "…"
+}
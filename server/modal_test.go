@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/seriousben/positronic-blogger/internal/linkpreview"
+	"gotest.tools/v3/assert"
+)
+
+// modalInputs returns the text inputs of a modal by custom ID.
+func modalInputs(resp *discordgo.InteractionResponse) map[string]discordgo.TextInput {
+	inputs := map[string]discordgo.TextInput{}
+	for _, c := range resp.Data.Components {
+		for _, c := range c.(discordgo.ActionsRow).Components {
+			ti := c.(discordgo.TextInput)
+			inputs[ti.CustomID] = ti
+		}
+	}
+	return inputs
+}
+
+func Test_lookupPrefill(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			fmt.Fprint(w, `<html><head><title>An   Article</title>
+<meta name="description" content="What it is about."></head></html>`)
+		case "/slow":
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer page.Close()
+
+	ctx := context.Background()
+	f := linkpreview.New(linkpreview.WithHTTPClient(page.Client()), linkpreview.WithTimeout(50*time.Millisecond))
+
+	pf := lookupPrefill(ctx, f, " "+page.URL+"/article ")
+	assert.DeepEqual(t, pf, prefill{Title: "An Article", URL: page.URL + "/article", Description: "What it is about."})
+
+	// Pages that cannot be fetched only prefill the URL.
+	for _, path := range []string{"/missing", "/slow"} {
+		pf = lookupPrefill(ctx, f, page.URL+path)
+		assert.DeepEqual(t, pf, prefill{URL: page.URL + path})
+	}
+}
+
+func Test_postModal(t *testing.T) {
+	inputs := modalInputs(postModal(prefill{}))
+	assert.Equal(t, inputs["title"].Value, "")
+	assert.Equal(t, inputs["URL"].Value, "")
+	assert.Equal(t, inputs["thoughts"].Placeholder, "")
+
+	inputs = modalInputs(postModal(prefill{
+		Title:       strings.Repeat("é", 400),
+		URL:         "https://example.com/article",
+		Description: "A description\nspanning lines, " + strings.Repeat("long ", 30),
+	}))
+	assert.Equal(t, len([]rune(inputs["title"].Value)), maxTitleLength)
+	assert.Assert(t, strings.HasSuffix(inputs["title"].Value, "…"))
+	assert.Equal(t, inputs["URL"].Value, "https://example.com/article")
+	// The description of the page is a hint, it is never posted as the thoughts of the author.
+	assert.Equal(t, inputs["thoughts"].Value, "")
+	assert.Equal(t, len([]rune(inputs["thoughts"].Placeholder)), maxPlaceholderLength)
+	assert.Assert(t, strings.HasPrefix(inputs["thoughts"].Placeholder, "A description spanning lines, long"))
+
+	inputs = modalInputs(postModal(prefill{URL: "https://example.com/" + strings.Repeat("a", maxURLLength)}))
+	assert.Equal(t, inputs["URL"].Value, "")
+}
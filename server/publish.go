@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/archive"
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"github.com/seriousben/positronic-blogger/internal/canonical"
+	"github.com/seriousben/positronic-blogger/internal/dedup"
+	"github.com/seriousben/positronic-blogger/internal/linkpreview"
+	"github.com/seriousben/positronic-blogger/internal/template"
+)
+
+// link is a curated link submitted from Discord.
+type link struct {
+	UserID   string
+	Title    string
+	URL      string
+	Thoughts string
+	Date     time.Time
+}
+
+// publisher publishes the links submitted from Discord to the blog repository.
+type publisher struct {
+	repo          backend.Repository
+	tmpl          *template.Template
+	contentPath   string
+	duplicates    dedup.Policy
+	canonicalizer *canonical.Canonicalizer
+	previewer     *linkpreview.Fetcher
+	archiver      *archive.Archiver
+	// identity returns who the commits of a Discord user are attributed to.
+	identity func(userID string) backend.Identity
+	merge    bool
+}
+
+// published is the outcome of publishing a link.
+type published struct {
+	Post     template.Post
+	Markdown string
+	FileName string
+	// Duplicate is the existing post of a link skipped as a duplicate.
+	Duplicate *dedup.Entry
+}
+
+// dedupEnabled reports whether links already posted are looked up before being published.
+func (pb *publisher) dedupEnabled() bool {
+	return pb.duplicates != "" && pb.duplicates != dedup.PolicyOff
+}
+
+// warm reads the existing posts ahead of the first submission,
+// the repository keeps them for the lookups of the following submissions.
+func (pb *publisher) warm(ctx context.Context) {
+	if !pb.dedupEnabled() {
+		return
+	}
+	if _, err := dedup.Load(ctx, pb.repo, pb.contentPath); err != nil {
+		log.Printf("error indexing posts: %v", err)
+	}
+}
+
+func (pb *publisher) publish(ctx context.Context, l link) (*published, error) {
+	if pb.canonicalizer != nil {
+		l.URL = pb.canonicalizer.Canonicalize(ctx, l.URL)
+	}
+	p := template.Post{
+		Title:   l.Title,
+		URL:     l.URL,
+		Comment: l.Thoughts,
+		Date:    l.Date,
+	}
+
+	var existing *dedup.Entry
+	if pb.dedupEnabled() {
+		idx, err := dedup.Load(ctx, pb.repo, pb.contentPath)
+		if err != nil {
+			return nil, fmt.Errorf("indexing posts: %w", err)
+		}
+		if e, ok := idx.Lookup(p.URL); ok {
+			if pb.duplicates == dedup.PolicySkip {
+				return &published{Post: p, FileName: pb.tmpl.FileName(p), Duplicate: &e}, nil
+			}
+			existing = &e
+		}
+	}
+
+	if pb.previewer != nil {
+		pb.previewer.Enrich(ctx, &p)
+	}
+
+	fileName := pb.tmpl.FileName(p)
+	if existing != nil {
+		fileName = path.Base(existing.Path)
+	}
+	var archived *backend.File
+	if pb.archiver != nil {
+		var err error
+		archived, err = pb.archiver.Archive(ctx, pb.repo, &p, fileName, pb.tmpl.Format())
+		if err != nil {
+			return nil, fmt.Errorf("archiving link: %w", err)
+		}
+	}
+
+	buf, err := pb.tmpl.Render(p)
+	if err != nil {
+		return nil, fmt.Errorf("generating markdown: %w", err)
+	}
+
+	res := &published{
+		Post:     p,
+		Markdown: buf.String(),
+		FileName: fileName,
+	}
+	filePath := path.Join(pb.contentPath, res.FileName)
+	if existing != nil {
+		filePath = existing.Path
+	}
+
+	var id backend.Identity
+	if pb.identity != nil {
+		id = pb.identity(l.UserID)
+	}
+
+	// start branch on first new content.
+	brc, err := pb.repo.OpenBranch(ctx, fmt.Sprintf("%s-positronic-blogger", l.Date.Format("2006-01-02T1504")), id)
+	if err != nil {
+		return nil, fmt.Errorf("creating branch: %w", err)
+	}
+
+	if archived != nil {
+		commit := fmt.Sprintf("auto: archive of curated link %s", res.FileName)
+		if err := brc.CreateFile(ctx, commit, archived.Path, archived.Content); err != nil {
+			return nil, fmt.Errorf("creating archive in branch: %w", err)
+		}
+	}
+
+	if existing != nil {
+		commit := fmt.Sprintf("auto: update curated link %s", res.FileName)
+		err = brc.UpdateFile(ctx, commit, filePath, existing.SHA, res.Markdown)
+	} else {
+		commit := fmt.Sprintf("auto: new curated link %s", res.FileName)
+		err = brc.CreateFile(ctx, commit, filePath, res.Markdown)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating file in branch: %w", err)
+	}
+
+	err = brc.Publish(
+		ctx,
+		fmt.Sprintf("%s-positronic-blogger", l.Date.Format(time.RFC3339)),
+		"Auto blogging done from https://github.com/seriousben/positronic-blogger",
+		pb.merge,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("publishing branch: %w", err)
+	}
+
+	return res, nil
+}
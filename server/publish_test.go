@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seriousben/positronic-blogger/internal/backend"
+	"github.com/seriousben/positronic-blogger/internal/canonical"
+	"github.com/seriousben/positronic-blogger/internal/dedup"
+	"github.com/seriousben/positronic-blogger/internal/github"
+	"github.com/seriousben/positronic-blogger/internal/github/githubtest"
+	"github.com/seriousben/positronic-blogger/internal/template"
+	"gotest.tools/v3/assert"
+)
+
+func newTestPublisher(t *testing.T, srv *githubtest.Server) *publisher {
+	t.Helper()
+	repo, err := github.New(context.Background(), "token", srv.Owner, srv.Repo,
+		github.WithBaseURL(srv.BaseURL()),
+		github.WithHTTPClient(srv.Client()),
+		github.WithRateLimit(time.Millisecond),
+	)
+	assert.NilError(t, err)
+	return &publisher{
+		repo:          repo,
+		tmpl:          template.Default,
+		contentPath:   "content/links",
+		duplicates:    dedup.PolicySkip,
+		canonicalizer: canonical.New(),
+		identity: func(userID string) backend.Identity {
+			return backend.Identity{Author: backend.Signature{Name: "user " + userID, Email: userID + "@example.com"}}
+		},
+		merge: true,
+	}
+}
+
+func Test_Publish(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+	pub := newTestPublisher(t, srv)
+
+	l := link{
+		UserID:   "42",
+		Title:    "An Article",
+		URL:      "https://example.com/article?fbclid=abc",
+		Thoughts: "Worth reading.",
+		Date:     time.Date(2022, 4, 1, 12, 0, 0, 0, time.UTC),
+	}
+	res, err := pub.publish(ctx, l)
+	assert.NilError(t, err)
+	assert.Assert(t, res.Duplicate == nil)
+	assert.Equal(t, res.FileName, "2022-04-01-an-article.md")
+	assert.Equal(t, res.Post.URL, "https://example.com/article")
+
+	content, ok := srv.File("main", "content/links/2022-04-01-an-article.md")
+	assert.Assert(t, ok)
+	assert.Equal(t, content, res.Markdown)
+
+	// The pull request is merged with a merge commit.
+	merge := srv.Commits("main")[0]
+	assert.Equal(t, len(merge.Parents), 2)
+	commit, ok := srv.Commit(merge.Parents[1])
+	assert.Assert(t, ok)
+	assert.Equal(t, commit.Message, "auto: new curated link 2022-04-01-an-article.md")
+	assert.Equal(t, commit.Author, githubtest.Signature{Name: "user 42", Email: "42@example.com"})
+
+	// Submitting the same link again is skipped.
+	l.Title = "Same Article"
+	l.Date = l.Date.Add(time.Hour)
+	res, err = pub.publish(ctx, l)
+	assert.NilError(t, err)
+	assert.Assert(t, res.Duplicate != nil)
+	assert.Equal(t, res.Duplicate.Path, "content/links/2022-04-01-an-article.md")
+	assert.Equal(t, len(srv.PullRequests()), 1)
+}
+
+func Test_Publish_DryRun(t *testing.T) {
+	ctx := context.Background()
+	srv := githubtest.NewServer("owner", "blog")
+	defer srv.Close()
+	pub := newTestPublisher(t, srv)
+	pub.merge = false
+
+	_, err := pub.publish(ctx, link{
+		Title: "An Article",
+		URL:   "https://example.com/article",
+		Date:  time.Date(2022, 4, 1, 12, 0, 0, 0, time.UTC),
+	})
+	assert.NilError(t, err)
+
+	_, ok := srv.File("main", "content/links/2022-04-01-an-article.md")
+	assert.Assert(t, !ok)
+	prs := srv.PullRequests()
+	assert.Equal(t, len(prs), 1)
+	assert.Equal(t, prs[0].State, "open")
+	assert.Equal(t, prs[0].Head, "2022-04-01T1200-positronic-blogger")
+}
@@ -2,123 +2,106 @@ package server
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"path"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/seriousben/positronic-blogger/internal/github"
-	"github.com/seriousben/positronic-blogger/internal/template"
+	"github.com/seriousben/positronic-blogger/internal/config"
+	"github.com/seriousben/positronic-blogger/internal/linkpreview"
 )
 
-var (
-	commands = []discordgo.ApplicationCommand{
-		{
-			Name:        "serious-post",
-			Description: "Post a new Curated Link to seriousben.com",
+var commands = []discordgo.ApplicationCommand{
+	{
+		Name:        "serious-post",
+		Description: "Post a new Curated Link to seriousben.com",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "url",
+				Description: "URL of the article, its title is filled in for you",
+				Required:    false,
+			},
 		},
-	}
-	commandsHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
+	},
+}
+
+// commandsHandlers returns the handlers of the slash commands.
+// Links given to /serious-post are fetched with prefills to fill in the modal.
+func commandsHandlers(ctx context.Context, prefills *linkpreview.Fetcher) map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	return map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
 		"serious-post": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseModal,
-				Data: &discordgo.InteractionResponseData{
-					CustomID: "serious-post",
-					Title:    "Post a new curated link",
-					Components: []discordgo.MessageComponent{
-						discordgo.ActionsRow{
-							Components: []discordgo.MessageComponent{
-								discordgo.TextInput{
-									CustomID:  "title",
-									Label:     "Title of article",
-									Style:     discordgo.TextInputShort,
-									Required:  true,
-									MaxLength: 300,
-									MinLength: 1,
-								},
-							},
-						},
-						discordgo.ActionsRow{
-							Components: []discordgo.MessageComponent{
-								discordgo.TextInput{
-									CustomID:  "URL",
-									Label:     "URL of article",
-									Style:     discordgo.TextInputShort,
-									Required:  true,
-									MaxLength: 300,
-									MinLength: 1,
-								},
-							},
-						},
-						discordgo.ActionsRow{
-							Components: []discordgo.MessageComponent{
-								discordgo.TextInput{
-									CustomID:  "thoughts",
-									Label:     "Thoughts about the article",
-									Style:     discordgo.TextInputParagraph,
-									Required:  false,
-									MaxLength: 2000,
-								},
-							},
-						},
-					},
-				},
-			})
+			var pf prefill
+			for _, opt := range i.ApplicationCommandData().Options {
+				if opt.Name == "url" && opt.Type == discordgo.ApplicationCommandOptionString {
+					pf = lookupPrefill(ctx, prefills, opt.StringValue())
+				}
+			}
+			err := s.InteractionRespond(i.Interaction, postModal(pf))
 			if err != nil {
-				panic(err)
+				log.Printf("error responding with modal: %v\n", err)
 			}
 		},
 	}
-)
-
-const (
-	envDryRun          = "POSITRONIC_DRY_RUN"
-	envGithubRepo      = "POSITRONIC_GITHUB_REPO"
-	envGithubToken     = "POSITRONIC_GITHUB_TOKEN"
-	envDiscordGuildID  = "POSITRONIC_DISCORD_GUILDID"
-	envDiscordToken    = "POSITRONIC_DISCORD_TOKEN"
-	envDiscordAppID    = "POSITRONIC_DISCORD_APPID"
-	envBlogContentPath = "POSITRONIC_BLOG_CONTENT_PATH"
-)
+}
 
 func Main() {
 	var (
-		ctx            = context.Background()
-		dryRun         = os.Getenv(envDryRun) == "true"
-		ghToken        = os.Getenv(envGithubToken)
-		ghRepoFull     = os.Getenv(envGithubRepo)
-		discordGuildID = os.Getenv(envDiscordGuildID)
-		discordToken   = os.Getenv(envDiscordToken)
-		discordAppID   = os.Getenv(envDiscordAppID)
-		contentPath    = "content/links"
-		ghOwner        string
-		ghRepo         string
+		ctx        = context.Background()
+		configFile = flag.String("config", os.Getenv(config.EnvConfigFile), "path to a TOML or YAML config file")
 	)
+	flag.Parse()
 
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
 	defer cancel()
 
-	if ghToken == "" || ghRepoFull == "" {
-		log.Fatalf("missing %s or %s", envGithubRepo, envGithubToken)
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("error loading config: %v", err)
+	}
+
+	if err := cfg.ValidateServer(); err != nil {
+		log.Fatal(err)
 	}
 
-	if ghRepoFullSplit := strings.Split(ghRepoFull, "/"); len(ghRepoFullSplit) == 2 {
-		ghOwner = ghRepoFullSplit[0]
-		ghRepo = ghRepoFullSplit[1]
-	} else {
-		log.Fatalf("malformed %s (%s) - expected format to be owner/repo", envGithubRepo, ghRepoFull)
+	var (
+		dryRun         = cfg.Server.DryRun
+		discordGuildID = cfg.Server.Discord.GuildID
+		discordToken   = cfg.Server.Discord.Token
+		discordAppID   = cfg.Server.Discord.AppID
+		allowedUsers   = map[string]bool{}
+	)
+	for _, id := range cfg.Server.Discord.AllowedUsers {
+		allowedUsers[id] = true
+	}
+
+	repo, err := cfg.OpenRepository(ctx)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	ghClient, err := github.New(ctx, ghToken, ghOwner, ghRepo)
+	tmpl, err := cfg.LoadTemplate(ctx, repo)
 	if err != nil {
-		log.Fatalf("error instantiating github client: %v", err)
+		log.Fatalf("error loading template: %v", err)
 	}
 
+	pub := &publisher{
+		repo:          repo,
+		tmpl:          tmpl,
+		contentPath:   cfg.Server.ContentPath,
+		duplicates:    cfg.ServerDuplicates(),
+		canonicalizer: cfg.Canonicalizer(),
+		previewer:     cfg.Previewer(),
+		archiver:      cfg.Archiver(),
+		identity:      cfg.DiscordIdentity,
+		merge:         !dryRun,
+	}
+	go pub.warm(ctx)
+
 	s, err := discordgo.New("Bot " + discordToken)
 	if err != nil {
 		log.Fatalf("Invalid bot parameters: %v", err)
@@ -128,16 +111,18 @@ func Main() {
 		log.Println("Bot is up!")
 	})
 
+	handlers := commandsHandlers(ctx, newPrefillFetcher())
+
 	s.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		user := i.Member.User
-		if user.ID != "905167870403702884" {
+		if !allowedUsers[user.ID] {
 			log.Printf("user %s not allowed: %+v", user.ID, user)
 			return
 		}
 
 		switch i.Type {
 		case discordgo.InteractionApplicationCommand:
-			if h, ok := commandsHandlers[i.ApplicationCommandData().Name]; ok {
+			if h, ok := handlers[i.ApplicationCommandData().Name]; ok {
 				h(s, i)
 			}
 		case discordgo.InteractionModalSubmit:
@@ -155,21 +140,7 @@ func Main() {
 					}
 				}
 
-				now := time.Now()
-				p := template.Post{
-					Title:   inputByID["title"].(*discordgo.TextInput).Value,
-					URL:     inputByID["URL"].(*discordgo.TextInput).Value,
-					Comment: inputByID["thoughts"].(*discordgo.TextInput).Value,
-					Date:    now,
-				}
-
-				buf, err := p.ToMarkdown()
-				if err != nil {
-					log.Printf("error generating markdown: %v\n", err)
-					return
-				}
-
-				err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 					Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
 					Data: &discordgo.InteractionResponseData{},
 				})
@@ -178,40 +149,30 @@ func Main() {
 					return
 				}
 
-				// start branch on first new content.
-				brc, err := ghClient.StartBranch(ctx, fmt.Sprintf("%s-positronic-blogger", now.Format("2006-01-02T1504")))
-				if err != nil {
-					log.Printf("error creating branch: %v\n", err)
-					return
-				}
-
-				fileName := p.FileName()
-				commit := fmt.Sprintf("auto: new curated link %s", fileName)
-
-				err = brc.CreateFile(ctx, commit, path.Join(contentPath, fileName), buf.String())
+				res, err := pub.publish(ctx, link{
+					UserID:   user.ID,
+					Title:    inputByID["title"].(*discordgo.TextInput).Value,
+					URL:      inputByID["URL"].(*discordgo.TextInput).Value,
+					Thoughts: inputByID["thoughts"].(*discordgo.TextInput).Value,
+					Date:     time.Now(),
+				})
 				if err != nil {
-					log.Printf("error creating file in branch: %v\n", err)
+					log.Printf("error publishing link: %v\n", err)
 					return
 				}
 
-				pr, err := brc.PullRequest(
-					ctx,
-					fmt.Sprintf("%s-positronic-blogger", now.Format(time.RFC3339)),
-					"Auto blogging done from https://github.com/seriousben/positronic-blogger",
-				)
-				if err != nil {
-					log.Printf("error creating pull request: %v\n", err)
-					return
-				}
-				if !dryRun {
-					err = brc.WaitAndMerge(ctx, pr)
+				if res.Duplicate != nil {
+					content := fmt.Sprintf("%s was already posted in %s", res.Post.URL, res.Duplicate.Path)
+					_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+						Content: &content,
+					})
 					if err != nil {
-						log.Printf("error waiting and merging: %v\n", err)
-						return
+						log.Printf("error responding to duplicate: %v\n", err)
 					}
+					return
 				}
 
-				content := p.Title + " posted successfully\n\n" + buf.String()
+				content := res.Post.Title + " posted successfully\n\n" + res.Markdown
 				_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
 					Content: &content,
 					Components: &[]discordgo.MessageComponent{
@@ -231,7 +192,7 @@ func Main() {
 									},
 									Label: "View",
 									Style: discordgo.LinkButton,
-									URL:   "https://seriousben.com/links/" + p.FileName(),
+									URL:   cfg.Server.PostURL + res.FileName,
 								},
 							},
 						},